@@ -0,0 +1,91 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+// Accessors are named after the record of the message, e.g.
+// get_book_title/1 or which_review_source/1. Fields of oneofs have their own
+// accessors: getters return undefined if the oneof is set to another case,
+// and setters select the case of the field.
+var erlAccessorTemplateContent = `
+{{- define "erl_message_accessors" }}
+{{- $mt := . }}
+-spec new_{{ .ErlName }}() -> {{ .ErlTypeName }}().
+new_{{ .ErlName }}() ->
+  #{{ .ErlName }}{}.
+
+%% Create a message from a list or a map of field values. Keys are the names
+%% of record fields, or of fields of oneofs. Deprecated fields cannot be set.
+-spec new_{{ .ErlName }}([{atom(), term()}] | #{atom() => term()}) ->
+        {{ .ErlTypeName }}().
+new_{{ .ErlName }}(Fields) when is_map(Fields) ->
+  new_{{ .ErlName }}(maps:to_list(Fields));
+new_{{ .ErlName }}(Fields) ->
+  lists:foldl(fun ({Name, Value}, Message) ->
+                  set_{{ .ErlName }}_field(Name, Value, Message)
+              end, #{{ .ErlName }}{}, Fields).
+
+-spec set_{{ .ErlName }}_field(atom(), term(), {{ .ErlTypeName }}()) ->
+        {{ .ErlTypeName }}().
+{{- range .Fields }}
+{{- if not .Deprecated }}
+set_{{ $mt.ErlName }}_field({{ .ErlName }}, Value, Message) ->
+  set_{{ $mt.ErlName }}_{{ .ErlName }}(Value, Message);
+{{- end }}
+{{- end }}
+{{- range .Oneofs }}
+set_{{ $mt.ErlName }}_field({{ .ErlName }}, Value, Message) ->
+  Message#{{ $mt.ErlName }}{ {{- .ErlName }} = Value};
+{{- end }}
+set_{{ .ErlName }}_field(Name, _, _) ->
+  error({unknown_field, {{ .ErlName }}, Name}).
+{{- range .Fields }}
+{{- if .OneofType }}
+
+-spec get_{{ $mt.ErlName }}_{{ .ErlName }}({{ $mt.ErlTypeName }}()) ->
+        {{ .ErlValueTypeSpec }} | undefined.
+get_{{ $mt.ErlName }}_{{ .ErlName }}(#{{ $mt.ErlName }}{ {{- .OneofType.ErlName }} = {{ "{" }}{{ .ErlName }}, Value}}) ->
+  Value;
+get_{{ $mt.ErlName }}_{{ .ErlName }}(#{{ $mt.ErlName }}{}) ->
+  undefined.
+
+-spec set_{{ $mt.ErlName }}_{{ .ErlName }}({{ .ErlValueTypeSpec }}, {{ $mt.ErlTypeName }}()) ->
+        {{ $mt.ErlTypeName }}().
+set_{{ $mt.ErlName }}_{{ .ErlName }}(Value, Message) ->
+  Message#{{ $mt.ErlName }}{ {{- .OneofType.ErlName }} = {{ "{" }}{{ .ErlName }}, Value}}.
+{{- else }}
+
+-spec get_{{ $mt.ErlName }}_{{ .ErlName }}({{ $mt.ErlTypeName }}()) ->
+        {{ .ErlTypeSpec }}.
+get_{{ $mt.ErlName }}_{{ .ErlName }}(#{{ $mt.ErlName }}{ {{- .ErlName }} = Value}) ->
+  Value.
+
+-spec set_{{ $mt.ErlName }}_{{ .ErlName }}({{ .ErlTypeSpec }}, {{ $mt.ErlTypeName }}()) ->
+        {{ $mt.ErlTypeName }}().
+set_{{ $mt.ErlName }}_{{ .ErlName }}(Value, Message) ->
+  Message#{{ $mt.ErlName }}{ {{- .ErlName }} = Value}.
+{{- end }}
+{{- end }}
+{{- range .Oneofs }}
+
+%% Return the case of the oneof which is currently set.
+-spec which_{{ $mt.ErlName }}_{{ .ErlName }}({{ $mt.ErlTypeName }}()) ->
+        {{ range .Fields }}{{ .ErlName }} | {{ end }}undefined.
+which_{{ $mt.ErlName }}_{{ .ErlName }}(#{{ $mt.ErlName }}{ {{- .ErlName }} = {Case, _}}) ->
+  Case;
+which_{{ $mt.ErlName }}_{{ .ErlName }}(#{{ $mt.ErlName }}{}) ->
+  undefined.
+{{- end }}
+{{- end }}
+`
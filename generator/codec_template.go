@@ -0,0 +1,841 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+// Encoding and size functions share the list of fields of a record returned
+// by codec_fields_<msg>/1; decoding functions use a table returned by
+// decoding_fields_<msg>/0 associating field numbers to record positions.
+// Encoding functions write the items returned by the pass computing the
+// size of the message, so that sizes always match the encoded data and the
+// size of each nested message is only computed once. All are driven by type terms built by FieldType.ErlCodecType, so that all
+// the logic lives in a small set of support functions.
+//
+// Nested messages, including messages of other packages, are decoded with
+// the decoding table of their type by the support functions of the module of
+// the top-level message, which can therefore track the depth and the path of
+// decoded values to enforce the limits passed to decode_<msg>/2.
+//
+// Decoding errors are returned with the offset and path of the invalid field
+// by decode_<msg>/2, and signaled with error({decode_error, Error}) by
+// decode_<msg>/1 with the same error term. Unknown enum values are represented by their number,
+// or signaled as errors if the closed_enums decoding option is set.
+// Non-finite floating point values are represented by the infinity,
+// '-infinity' and nan atoms.
+//
+// Unknown fields are stored in the last field of records, '$unknown', as a
+// list of field numbers and encoded fields including their key, in the
+// order they were decoded. Encoding functions write them back after known
+// fields.
+//
+// With the deterministic encoding option, fields are written in field number
+// order and map entries are sorted by key, including in nested messages, so
+// that equal messages always have the same encoding. Unknown fields are
+// sorted by field number, keeping the order of fields with the same number.
+var erlCodecTemplateContent = `
+{{- define "erl_message_codec" }}
+-spec encode_{{ .ErlName }}({{ .ErlTypeName }}()) -> binary().
+{{- $mt := . }}
+encode_{{ .ErlName }}(Message) ->
+  encode_{{ .ErlName }}(Message, []).
+
+%% Encode a message. Unknown fields are written after known fields. With the
+%% deterministic option, fields are written in field number order, map
+%% entries are sorted by key and unknown fields are sorted by field number.
+-spec encode_{{ .ErlName }}({{ .ErlTypeName }}(), [encode_option()]) ->
+        binary().
+encode_{{ .ErlName }}(Message, Options) ->
+{{- if .VerifyOnEncode }}
+  verify_before_encode({{ .ErlName }}, verify_{{ .ErlName }}(Message)),
+{{- end }}
+  {_, Items} = pb_message_size(codec_fields_{{ .ErlName }}(Message), Options),
+  pb_encode_items(Items, <<>>).
+
+%% Return the size of the encoded message in bytes.
+-spec encoded_size_{{ .ErlName }}({{ .ErlTypeName }}()) -> non_neg_integer().
+encoded_size_{{ .ErlName }}(Message) ->
+  {Size, _} = pb_message_size(codec_fields_{{ .ErlName }}(Message), []),
+  Size.
+
+%% Return the list of fields used to encode a message, including when it is
+%% nested in messages of other modules.
+-spec codec_fields_{{ .ErlName }}({{ .ErlTypeName }}()) -> list().
+codec_fields_{{ .ErlName }}(Message) ->
+  [
+    {{- $first := true }}
+    {{- range .Fields }}
+    {{- if not .OneofType }}
+    {{- if $first }}{{ $first = false }}{{ else }},
+   {{ end }}
+    {{- "" }}{ {{- .Number }}, Message#{{ $mt.ErlName }}.{{ .ErlName }},
+    {{ .ErlCodecType }}, {{ .ErlEncodeMode }}}
+    {{- else if .IsFirstOneofField }}
+    {{- if $first }}{{ $first = false }}{{ else }},
+   {{ end }}
+    {{- "" }}{oneof, Message#{{ $mt.ErlName }}.{{ .OneofType.ErlName }},
+    {{ .OneofType.ErlCodecCases }}}
+    {{- end }}
+    {{- end }}
+    {{- if not $first }},
+   {{ end }}
+    {{- "" }}{unknown, Message#{{ $mt.ErlName }}.'$unknown'}].
+
+-spec decode_{{ .ErlName }}(iodata()) -> {{ "{" }}{{ .ErlTypeName }}(), <<>>}.
+decode_{{ .ErlName }}(Data) ->
+  case decode_{{ .ErlName }}(Data, []) of
+    {ok, Message} ->
+      {Message, <<>>};
+    {error, Reason} ->
+      error({decode_error, Reason})
+  end.
+
+%% Decode a message, enforcing the limits set in the options. Errors carry
+%% the offset of the field which could not be decoded and its path, e.g.
+%% <<"book.publications[2].status">>.
+-spec decode_{{ .ErlName }}(iodata(), [decode_option()]) ->
+        {ok, {{ .ErlTypeName }}()} | {error, decode_error()}.
+decode_{{ .ErlName }}(Data, Options) ->
+  try
+    Bin = iolist_to_binary(Data),
+    Context = pb_decode_context(<<"{{ .ErlName }}">>, Bin, Options),
+    {ok, pb_decode_message(Bin, decoding_fields_{{ .ErlName }}(), Context)}
+  catch
+    error:{decode_error, {limit_exceeded, _, _} = Reason} ->
+      {error, Reason};
+    error:{decode_error, {limit_exceeded, _, _} = Reason, _, _} ->
+      {error, Reason};
+    error:{decode_error, Reason, Offset, Path} ->
+      {error, {invalid_data, Reason, Offset, Path}}
+  end.
+
+%% Return the empty record and the table of fields used to decode messages,
+%% including when they are nested in messages of other modules.
+-spec decoding_fields_{{ .ErlName }}() ->
+        {{ "{" }}{{ .ErlTypeName }}(), #{pos_integer() => tuple()}}.
+decoding_fields_{{ .ErlName }}() ->
+  {#{{ .ErlName }}{},
+   #{
+   {{- range $i, $f := .Fields }}
+   {{- if gt $i 0 }},
+     {{ end }}
+   {{- "" }}{{ $f.Number }} =>
+       {#{{ $mt.ErlName }}.{{ if $f.OneofType }}{{ $f.OneofType.ErlName }}{{ else }}{{ $f.ErlName }}{{ end }}, <<"{{ $f.Name }}">>,
+        {{ $f.ErlCodecType }}, {{ $f.ErlDecodeMode }}}
+   {{- end }}}}.
+{{- end }}
+
+{{- define "erl_codec_support" }}
+-type encode_option() :: deterministic.
+
+%% Messages are encoded in two passes. The size pass returns the size of the
+%% fields of a message and the list of items to write, in encoding order;
+%% nested messages and map entries are items containing their own size and
+%% items, so that the length prefix of each of them is computed once. The
+%% encoding pass then writes all items to a single binary.
+-spec pb_message_size(list(), [encode_option()]) ->
+        {non_neg_integer(), list()}.
+pb_message_size(Fields, Options) ->
+  Fields2 = case lists:member(deterministic, Options) of
+              true ->
+                Numbers = [{pb_field_number(Field), Field} || Field <- Fields],
+                [Field || {_, Field} <- lists:keysort(1, Numbers)];
+              false ->
+                Fields
+            end,
+  lists:foldr(fun (Field, {Size, Items}) ->
+                  {FieldSize, FieldItems} = pb_field_size(Field, Options),
+                  {Size + FieldSize, FieldItems ++ Items}
+              end, {0, []}, Fields2).
+
+%% Return the number used to order fields in deterministic mode; unset oneofs
+%% are not encoded, so their position does not matter.
+-spec pb_field_number(tuple()) -> non_neg_integer().
+pb_field_number({unknown, _}) ->
+  %% Unknown fields are written after all known fields, whose numbers are
+  %% lower than 2^29
+  16#20000000;
+pb_field_number({oneof, {Case, _}, Cases}) ->
+  case lists:keyfind(Case, 1, Cases) of
+    {Case, Number, _} ->
+      Number;
+    false ->
+      0
+  end;
+pb_field_number({oneof, _, _}) ->
+  0;
+pb_field_number({Number, _, _, _}) ->
+  Number.
+
+-spec pb_field_size(tuple(), [encode_option()]) ->
+        {non_neg_integer(), list()}.
+pb_field_size({unknown, Fields}, Options) ->
+  Fields2 = case lists:member(deterministic, Options) of
+              true -> lists:keysort(1, Fields);
+              false -> Fields
+            end,
+  {lists:sum([byte_size(Data) || {_, Data} <- Fields2]),
+   [{unknown, Data} || {_, Data} <- Fields2]};
+pb_field_size({oneof, undefined, _}, _) ->
+  {0, []};
+pb_field_size({oneof, {Case, Value}, Cases}, Options) ->
+  case lists:keyfind(Case, 1, Cases) of
+    {Case, Number, Type} ->
+      pb_values_size(Number, [Value], Type, Options);
+    false ->
+      error({invalid_oneof_case, Case})
+  end;
+pb_field_size({Number, undefined, {message, _, _}, required}, _) ->
+  error({missing_required_field, Number});
+pb_field_size({Number, Value, Type, required}, Options) ->
+  pb_values_size(Number, [Value], Type, Options);
+pb_field_size({Number, Value, Type, {optional, Default}}, Options) ->
+  case pb_is_default(Value, Type, Default) of
+    true ->
+      {0, []};
+    false ->
+      pb_values_size(Number, [Value], Type, Options)
+  end;
+pb_field_size({Number, Values, Type, repeated}, Options) ->
+  pb_values_size(Number, Values, Type, Options);
+pb_field_size({_, [], _, packed}, _) ->
+  {0, []};
+pb_field_size({Number, Values, Type, packed}, _) ->
+  Size = lists:sum([pb_raw_size(Value, Type) || Value <- Values]),
+  {pb_key_size(Number) + pb_bytes_size(Size),
+   [{packed, Number, Size, Values, Type}]};
+pb_field_size({Number, Entries, Type, {map_entries, KeyType}}, Options) ->
+  %% Entries are records whose first field is the key
+  Entries2 = case lists:member(deterministic, Options) of
+               true -> pb_sort_map_entries(Entries, 2, KeyType);
+               false -> Entries
+             end,
+  pb_values_size(Number, Entries2, Type, Options);
+pb_field_size({Number, Map, Type = {map, KeyType, _}, map}, Options) ->
+  Entries = case lists:member(deterministic, Options) of
+              true -> pb_sort_map_entries(maps:to_list(Map), 1, KeyType);
+              false -> maps:to_list(Map)
+            end,
+  pb_values_size(Number, Entries, Type, Options).
+
+%% Sort map entries by the key stored at position N of each entry. String
+%% keys are compared as binaries, so that keys with the same content have the
+%% same order whatever their representation.
+-spec pb_sort_map_entries([tuple()], pos_integer(), term()) -> [tuple()].
+pb_sort_map_entries(Entries, N, {string, Repr, _}) ->
+  Entries2 = [{iolist_to_binary(pb_string_data(element(N, Entry), Repr)),
+               Entry} || Entry <- Entries],
+  [Entry || {_, Entry} <- lists:keysort(1, Entries2)];
+pb_sort_map_entries(Entries, N, _) ->
+  lists:keysort(N, Entries).
+
+-spec pb_values_size(pos_integer(), list(), term(), [encode_option()]) ->
+        {non_neg_integer(), list()}.
+pb_values_size(Number, Values, Type, Options) ->
+  KeySize = pb_key_size(Number),
+  lists:foldr(fun (Value, {Size, Items}) ->
+                  {ValueSize, Item} = pb_value_size(Number, Value, Type,
+                                                    Options),
+                  {Size + KeySize + ValueSize, [Item | Items]}
+              end, {0, []}, Values).
+
+%% Return the size of a value without its key, and the item used to write
+%% it.
+-spec pb_value_size(pos_integer(), term(), term(), [encode_option()]) ->
+        {non_neg_integer(), tuple()}.
+pb_value_size(Number, Value, {message, CodecFields, _}, Options) ->
+  {Size, Items} = pb_message_size(CodecFields(Value), Options),
+  {pb_bytes_size(Size), {message, Number, Size, Items}};
+pb_value_size(Number, {Key, Value}, {map, KeyType, ValueType}, Options) ->
+  {KeySize, KeyItem} = pb_value_size(1, Key, KeyType, Options),
+  {ValueSize, ValueItem} = pb_value_size(2, Value, ValueType, Options),
+  Size = pb_key_size(1) + KeySize + pb_key_size(2) + ValueSize,
+  {pb_bytes_size(Size), {message, Number, Size, [KeyItem, ValueItem]}};
+pb_value_size(Number, Value, Type, _) ->
+  {pb_raw_size(Value, Type), {Number, Value, Type}}.
+
+-spec pb_raw_size(term(), term()) -> non_neg_integer().
+pb_raw_size(Value, {integer, varint, _, _}) ->
+  pb_varint_size(Value band 16#ffffffffffffffff);
+pb_raw_size(Value, {integer, zigzag, _, _}) ->
+  pb_varint_size(pb_zigzag(Value));
+pb_raw_size(_, {integer, fixed, Bits, _}) ->
+  Bits div 8;
+pb_raw_size(_, bool) ->
+  1;
+pb_raw_size(Value, {enum, _, _}) when is_integer(Value) ->
+  pb_varint_size(Value band 16#ffffffffffffffff);
+pb_raw_size(Value, {enum, ToInt, _}) ->
+  pb_varint_size(ToInt(Value) band 16#ffffffffffffffff);
+pb_raw_size(_, float) ->
+  4;
+pb_raw_size(_, double) ->
+  8;
+pb_raw_size(Value, {string, Repr, _}) ->
+  pb_bytes_size(iolist_size(pb_string_data(Value, Repr)));
+pb_raw_size(Value, bytes) ->
+  pb_bytes_size(iolist_size(Value)).
+
+-spec pb_bytes_size(non_neg_integer()) -> non_neg_integer().
+pb_bytes_size(Length) ->
+  pb_varint_size(Length) + Length.
+
+-spec pb_key_size(pos_integer()) -> pos_integer().
+pb_key_size(Number) ->
+  pb_varint_size(Number bsl 3).
+
+-spec pb_varint_size(non_neg_integer()) -> pos_integer().
+pb_varint_size(Value) when Value < 128 ->
+  1;
+pb_varint_size(Value) ->
+  1 + pb_varint_size(Value bsr 7).
+
+-spec pb_encode_items(list(), binary()) -> binary().
+pb_encode_items([], Acc) ->
+  Acc;
+pb_encode_items([{message, Number, Size, Items} | Rest], Acc) ->
+  Acc2 = pb_encode_varint(Size, pb_encode_key(Number, 2, Acc)),
+  pb_encode_items(Rest, pb_encode_items(Items, Acc2));
+pb_encode_items([{unknown, Data} | Rest], Acc) ->
+  pb_encode_items(Rest, <<Acc/binary, Data/binary>>);
+pb_encode_items([{packed, Number, Size, Values, Type} | Rest], Acc) ->
+  Acc2 = pb_encode_varint(Size, pb_encode_key(Number, 2, Acc)),
+  Acc3 = lists:foldl(fun (Value, Bin) ->
+                         pb_encode_raw(Value, Type, Bin)
+                     end, Acc2, Values),
+  pb_encode_items(Rest, Acc3);
+pb_encode_items([{Number, Value, Type} | Rest], Acc) ->
+  Acc2 = pb_encode_key(Number, pb_wire_type(Type), Acc),
+  pb_encode_items(Rest, pb_encode_raw(Value, Type, Acc2)).
+
+-spec pb_encode_raw(term(), term(), binary()) -> binary().
+pb_encode_raw(Value, {integer, varint, _, _}, Acc) ->
+  pb_encode_varint(Value band 16#ffffffffffffffff, Acc);
+pb_encode_raw(Value, {integer, zigzag, _, _}, Acc) ->
+  pb_encode_varint(pb_zigzag(Value), Acc);
+pb_encode_raw(Value, {integer, fixed, Bits, _}, Acc) ->
+  <<Acc/binary, Value:Bits/little>>;
+pb_encode_raw(true, bool, Acc) ->
+  <<Acc/binary, 1>>;
+pb_encode_raw(false, bool, Acc) ->
+  <<Acc/binary, 0>>;
+pb_encode_raw(Value, {enum, _, _}, Acc) when is_integer(Value) ->
+  pb_encode_varint(Value band 16#ffffffffffffffff, Acc);
+pb_encode_raw(Value, {enum, ToInt, _}, Acc) ->
+  pb_encode_varint(ToInt(Value) band 16#ffffffffffffffff, Acc);
+pb_encode_raw(Value, float, Acc) when is_number(Value) ->
+  <<Acc/binary, Value:32/little-float>>;
+pb_encode_raw(Value, float, Acc) ->
+  <<Acc/binary, (pb_special_float_bits(Value, 32)):32/little>>;
+pb_encode_raw(Value, double, Acc) when is_number(Value) ->
+  <<Acc/binary, Value:64/little-float>>;
+pb_encode_raw(Value, double, Acc) ->
+  <<Acc/binary, (pb_special_float_bits(Value, 64)):64/little>>;
+pb_encode_raw(Value, {string, Repr, _}, Acc) ->
+  pb_encode_bytes(pb_string_data(Value, Repr), Acc);
+pb_encode_raw(Value, bytes, Acc) ->
+  pb_encode_bytes(Value, Acc).
+
+-spec pb_encode_bytes(iodata(), binary()) -> binary().
+pb_encode_bytes(Data, Acc) ->
+  Bin = iolist_to_binary(Data),
+  <<(pb_encode_varint(byte_size(Bin), Acc))/binary, Bin/binary>>.
+
+-spec pb_encode_key(pos_integer(), 0..5, binary()) -> binary().
+pb_encode_key(Number, WireType, Acc) ->
+  pb_encode_varint((Number bsl 3) bor WireType, Acc).
+
+-spec pb_encode_varint(non_neg_integer(), binary()) -> binary().
+pb_encode_varint(Value, Acc) when Value < 128 ->
+  <<Acc/binary, Value>>;
+pb_encode_varint(Value, Acc) ->
+  pb_encode_varint(Value bsr 7, <<Acc/binary, 1:1, (Value band 127):7>>).
+
+-spec pb_zigzag(integer()) -> non_neg_integer().
+pb_zigzag(Value) when Value >= 0 ->
+  Value bsl 1;
+pb_zigzag(Value) ->
+  -(Value bsl 1) - 1.
+
+-spec pb_special_float_bits(infinity | '-infinity' | nan, 32 | 64) ->
+        non_neg_integer().
+pb_special_float_bits(infinity, 32) -> 16#7f800000;
+pb_special_float_bits('-infinity', 32) -> 16#ff800000;
+pb_special_float_bits(nan, 32) -> 16#7fc00000;
+pb_special_float_bits(infinity, 64) -> 16#7ff0000000000000;
+pb_special_float_bits('-infinity', 64) -> 16#fff0000000000000;
+pb_special_float_bits(nan, 64) -> 16#7ff8000000000000.
+
+-spec pb_string_data(term(), iodata | binary | chardata | list) -> iodata().
+pb_string_data(Value, Repr) when Repr =:= iodata; Repr =:= binary ->
+  Value;
+pb_string_data(Value, _) ->
+  case unicode:characters_to_binary(Value) of
+    Data when is_binary(Data) ->
+      Data;
+    _ ->
+      error({invalid_string, Value})
+  end.
+
+-spec pb_is_default(term(), term(), term()) -> boolean().
+pb_is_default(Value, {string, Repr, _}, Default) ->
+  iolist_to_binary(pb_string_data(Value, Repr)) =:=
+    iolist_to_binary(pb_string_data(Default, Repr));
+pb_is_default(Value, bytes, Default) ->
+  iolist_to_binary(Value) =:= iolist_to_binary(Default);
+pb_is_default(Value, Type, Default) when Type =:= float; Type =:= double ->
+  Value == Default;
+pb_is_default(Value, _, Default) ->
+  Value =:= Default.
+
+-spec pb_wire_type(term()) -> 0..5.
+pb_wire_type({integer, fixed, 32, _}) ->
+  5;
+pb_wire_type({integer, fixed, 64, _}) ->
+  1;
+pb_wire_type({integer, _, _, _}) ->
+  0;
+pb_wire_type(bool) ->
+  0;
+pb_wire_type({enum, _, _}) ->
+  0;
+pb_wire_type(float) ->
+  5;
+pb_wire_type(double) ->
+  1;
+pb_wire_type(_) ->
+  2.
+
+-type decode_option() :: {max_depth, non_neg_integer()}
+                       | {max_size, non_neg_integer()}
+                       | {max_repeated, non_neg_integer()}
+                       | {max_string_length, non_neg_integer()}
+                       | closed_enums.
+
+-type decode_limit() :: max_depth | max_size | max_repeated
+                      | max_string_length.
+
+-type decode_error_reason() :: truncated_data
+                             | truncated_varint
+                             | invalid_varint
+                             | {invalid_wire_type, 0..7}
+                             | {invalid_utf8_string, binary()}
+                             | {unknown_enum_value, integer()}.
+
+-type decode_error() :: {limit_exceeded, decode_limit(), binary()}
+                      | {invalid_data, decode_error_reason(),
+                         non_neg_integer(), binary()}.
+
+%% The decoding context contains options and the path of the value being
+%% decoded, stored in reverse order. Path elements are field names, or
+%% {Name, Index} for values of repeated and map fields; the first element of
+%% the path is the name of the top-level message. The end offset is the
+%% offset of the end of the data of the message being decoded in the
+%% top-level message, used to compute the offset of errors.
+-spec pb_decode_context(binary(), binary(), [decode_option()]) -> map().
+pb_decode_context(Name, Data, Options) ->
+  Context = #{path => [Name],
+              depth => 0,
+              end_offset => byte_size(Data),
+              closed_enums => proplists:get_bool(closed_enums, Options),
+              max_depth => proplists:get_value(max_depth, Options, infinity),
+              max_size => proplists:get_value(max_size, Options, infinity),
+              max_repeated =>
+                proplists:get_value(max_repeated, Options, infinity),
+              max_string_length =>
+                proplists:get_value(max_string_length, Options, infinity)},
+  case byte_size(Data) > maps:get(max_size, Context) of
+    true ->
+      pb_limit_exceeded(max_size, Context);
+    false ->
+      Context
+  end.
+
+%% Return the context used to decode a value of a field. Values of repeated
+%% and map fields are identified by their index.
+-spec pb_field_context(binary(), term(), non_neg_integer(), map()) -> map().
+pb_field_context(Name, Mode, Count, Context = #{path := Path}) ->
+  Context2 = Context#{path => [pb_path_element(Name, Mode, Count) | Path]},
+  case Count >= maps:get(max_repeated, Context) of
+    true when Mode =:= repeated; Mode =:= map ->
+      pb_limit_exceeded(max_repeated, Context2);
+    _ ->
+      Context2
+  end.
+
+-spec pb_path_element(binary(), term(), non_neg_integer()) ->
+        binary() | {binary(), non_neg_integer()}.
+pb_path_element(Name, Mode, Count) when Mode =:= repeated; Mode =:= map ->
+  {Name, Count};
+pb_path_element(Name, _, _) ->
+  Name.
+
+-spec pb_limit_exceeded(decode_limit(), map()) -> no_return().
+pb_limit_exceeded(Limit, #{path := Path}) ->
+  error({decode_error, {limit_exceeded, Limit, pb_field_path(Path)}}).
+
+-spec pb_field_path(list()) -> binary().
+pb_field_path(Path) ->
+  Elements = [case Element of
+                {Name, Index} ->
+                  [Name, $[, integer_to_binary(Index), $]];
+                Name ->
+                  Name
+              end || Element <- lists:reverse(Path)],
+  iolist_to_binary(lists:join(<<".">>, Elements)).
+
+-spec pb_decode_message(binary(), {tuple(), #{pos_integer() => tuple()}},
+                        map()) ->
+        tuple().
+pb_decode_message(Data, {Message, Fields}, Context) ->
+  Message2 = pb_decode_fields(Data, Message, Fields, Context, #{}),
+  %% Repeated values and unknown fields are accumulated in reverse order
+  UnknownPos = tuple_size(Message2),
+  Message3 = setelement(UnknownPos, Message2,
+                        lists:reverse(element(UnknownPos, Message2))),
+  maps:fold(fun (_, {Pos, _, _, repeated}, M) ->
+                setelement(Pos, M, lists:reverse(element(Pos, M)));
+                (_, _, M) ->
+                M
+            end, Message3, Fields).
+
+%% Counts associate record positions with the number of values decoded for
+%% the field. Errors are located by the innermost message being decoded;
+%% errors raised by decoders of nested messages are already located and do
+%% not match {decode_error, Reason}.
+-spec pb_decode_fields(binary(), tuple(), #{pos_integer() => tuple()},
+                       map(), #{pos_integer() => non_neg_integer()}) ->
+        tuple().
+pb_decode_fields(<<>>, Message, _, _, _) ->
+  Message;
+pb_decode_fields(Data, Message, Fields, Context, Counts) ->
+  {Message2, Rest, Counts2} =
+    try
+      pb_decode_field(Data, Message, Fields, Context, Counts)
+    catch
+      error:{decode_error, Reason} ->
+        pb_decode_error(Reason, Data, Fields, Context, Counts)
+    end,
+  pb_decode_fields(Rest, Message2, Fields, Context, Counts2).
+
+%% Signal an error which occurred while decoding a field, using the offset of
+%% the start of the field.
+-spec pb_decode_error(term(), binary(), #{pos_integer() => tuple()}, map(),
+                      #{pos_integer() => non_neg_integer()}) ->
+        no_return().
+pb_decode_error(Reason, Data, Fields,
+                #{path := Path, end_offset := EndOffset}, Counts) ->
+  Path2 = try pb_decode_varint(Data) of
+            {Key, _} ->
+              case maps:find(Key bsr 3, Fields) of
+                {ok, {Pos, Name, _, Mode}} ->
+                  Count = maps:get(Pos, Counts, 0),
+                  [pb_path_element(Name, Mode, Count) | Path];
+                error ->
+                  Path
+              end
+          catch
+            error:{decode_error, _} ->
+              Path
+          end,
+  Offset = EndOffset - byte_size(Data),
+  error({decode_error, Reason, Offset, pb_field_path(Path2)}).
+
+-spec pb_decode_field(binary(), tuple(), #{pos_integer() => tuple()}, map(),
+                      #{pos_integer() => non_neg_integer()}) ->
+        {tuple(), binary(), #{pos_integer() => non_neg_integer()}}.
+pb_decode_field(Data, Message, Fields, Context, Counts) ->
+  {Key, Data2} = pb_decode_varint(Data),
+  Number = Key bsr 3,
+  WireType = Key band 7,
+  case maps:find(Number, Fields) of
+    {ok, Field} ->
+      pb_decode_field_value(WireType, Data2, Message, Field, Context, Counts);
+    error ->
+      %% Unknown fields are stored with their key in the last field of the
+      %% record
+      Rest = pb_skip_value(WireType, Data2),
+      Size = byte_size(Data) - byte_size(Rest),
+      <<Field:Size/binary, _/binary>> = Data,
+      Pos = tuple_size(Message),
+      Message2 = setelement(Pos, Message,
+                            [{Number, Field} | element(Pos, Message)]),
+      {Message2, Rest, Counts}
+  end.
+
+-spec pb_decode_field_value(0..7, binary(), tuple(), tuple(), map(),
+                            #{pos_integer() => non_neg_integer()}) ->
+        {tuple(), binary(), #{pos_integer() => non_neg_integer()}}.
+pb_decode_field_value(WireType, Data, Message, {Pos, Name, Type, Mode},
+                      Context, Counts) ->
+  Count = maps:get(Pos, Counts, 0),
+  case {pb_wire_type(Type), Mode} of
+    {WireType, _} ->
+      Context2 = pb_field_context(Name, Mode, Count, Context),
+      {ok, Value, Rest} = pb_decode_value(Data, Type, Context2),
+      {pb_set_field(Message, Pos, Mode, Value), Rest,
+       Counts#{Pos => Count + 1}};
+    {PackableWireType, repeated} when WireType =:= 2,
+                                      PackableWireType =/= 2 ->
+      %% Packed and unpacked encodings are both accepted for repeated
+      %% scalar fields
+      {Bin, Rest} = pb_decode_bytes(Data),
+      {Values, Count2} = pb_decode_packed(Bin, Type, element(Pos, Message),
+                                          Name, Count, Context),
+      {setelement(Pos, Message, Values), Rest, Counts#{Pos => Count2}};
+    _ ->
+      error({decode_error, {invalid_wire_type, WireType}})
+  end.
+
+-spec pb_set_field(tuple(), pos_integer(), term(), term()) -> tuple().
+pb_set_field(Message, Pos, single, Value) ->
+  setelement(Pos, Message, Value);
+pb_set_field(Message, Pos, repeated, Value) ->
+  setelement(Pos, Message, [Value | element(Pos, Message)]);
+pb_set_field(Message, Pos, {merge, Merge}, Value) ->
+  case element(Pos, Message) of
+    undefined ->
+      setelement(Pos, Message, Value);
+    Previous ->
+      setelement(Pos, Message, Merge(Previous, Value))
+  end;
+pb_set_field(Message, Pos, {oneof, Case}, Value) ->
+  setelement(Pos, Message, {Case, Value});
+pb_set_field(Message, Pos, {oneof, Case, Merge}, Value) ->
+  case element(Pos, Message) of
+    {Case, Previous} ->
+      setelement(Pos, Message, {Case, Merge(Previous, Value)});
+    _ ->
+      setelement(Pos, Message, {Case, Value})
+  end;
+pb_set_field(Message, Pos, map, {Key, Value}) ->
+  setelement(Pos, Message, maps:put(Key, Value, element(Pos, Message))).
+
+-spec pb_decode_packed(binary(), term(), list(), binary(), non_neg_integer(),
+                       map()) ->
+        {list(), non_neg_integer()}.
+pb_decode_packed(<<>>, _, Acc, _, Count, _) ->
+  {Acc, Count};
+pb_decode_packed(Data, Type, Acc, Name, Count, Context) ->
+  Context2 = pb_field_context(Name, repeated, Count, Context),
+  {ok, Value, Rest} = pb_decode_value(Data, Type, Context2),
+  pb_decode_packed(Rest, Type, [Value | Acc], Name, Count + 1, Context).
+
+%% Decode values whose decoding depends on the context; the decoding of
+%% other values is delegated to pb_decode_value/2.
+-spec pb_decode_value(binary(), term(), map()) -> {ok, term(), binary()}.
+pb_decode_value(Data, {string, Repr, ValidateUTF8}, Context) ->
+  {Bin, Rest} = pb_decode_bytes(Data),
+  pb_check_length(Bin, Context),
+  {ok, pb_decode_string(Bin, Repr, ValidateUTF8), Rest};
+pb_decode_value(Data, bytes, Context) ->
+  {Bin, Rest} = pb_decode_bytes(Data),
+  pb_check_length(Bin, Context),
+  {ok, Bin, Rest};
+pb_decode_value(Data, {message, _, DecodingFields},
+                Context = #{depth := Depth, end_offset := EndOffset}) ->
+  {Bin, Rest} = pb_decode_bytes(Data),
+  Context2 = Context#{depth => Depth + 1,
+                      end_offset => EndOffset - byte_size(Rest)},
+  case Depth + 1 > maps:get(max_depth, Context) of
+    true ->
+      pb_limit_exceeded(max_depth, Context2);
+    false ->
+      {ok, pb_decode_message(Bin, DecodingFields(), Context2), Rest}
+  end;
+pb_decode_value(Data, {map, KeyType, ValueType},
+                Context = #{end_offset := EndOffset}) ->
+  {Bin, Rest} = pb_decode_bytes(Data),
+  Context2 = Context#{end_offset => EndOffset - byte_size(Rest)},
+  Entry = pb_decode_map_entry(Bin, KeyType, ValueType,
+                              {pb_default(KeyType), pb_default(ValueType)},
+                              Context2),
+  {ok, Entry, Rest};
+pb_decode_value(Data, {enum, _, _} = Type, #{closed_enums := true}) ->
+  case pb_decode_value(Data, Type) of
+    {ok, Value, _} when is_integer(Value) ->
+      error({decode_error, {unknown_enum_value, Value}});
+    Result ->
+      Result
+  end;
+pb_decode_value(Data, Type, _) ->
+  pb_decode_value(Data, Type).
+
+-spec pb_check_length(binary(), map()) -> ok.
+pb_check_length(Bin, Context) ->
+  case byte_size(Bin) > maps:get(max_string_length, Context) of
+    true ->
+      pb_limit_exceeded(max_string_length, Context);
+    false ->
+      ok
+  end.
+
+-spec pb_decode_value(binary(), term()) -> {ok, term(), binary()}.
+pb_decode_value(Data, {integer, fixed, Bits, Signedness}) ->
+  case Data of
+    <<Value:Bits/little, Rest/binary>> ->
+      {ok, pb_integer(Value, Bits, Signedness), Rest};
+    _ ->
+      error({decode_error, truncated_data})
+  end;
+pb_decode_value(Data, {integer, zigzag, _, _}) ->
+  {Value, Rest} = pb_decode_varint(Data),
+  {ok, (Value bsr 1) bxor -(Value band 1), Rest};
+pb_decode_value(Data, {integer, varint, Bits, Signedness}) ->
+  {Value, Rest} = pb_decode_varint(Data),
+  {ok, pb_integer(Value, Bits, Signedness), Rest};
+pb_decode_value(Data, bool) ->
+  {Value, Rest} = pb_decode_varint(Data),
+  {ok, Value =/= 0, Rest};
+pb_decode_value(Data, {enum, _, FromInt}) ->
+  %% Unknown values are represented by their number
+  {Value, Rest} = pb_decode_varint(Data),
+  Number = pb_integer(Value, 32, signed),
+  try
+    {ok, FromInt(Number), Rest}
+  catch
+    error:function_clause ->
+      {ok, Number, Rest}
+  end;
+pb_decode_value(<<Value:32/little-float, Rest/binary>>, float) ->
+  {ok, Value, Rest};
+pb_decode_value(<<Bits:32/little, Rest/binary>>, float) ->
+  {ok, pb_special_float(Bits bsr 31, Bits band 16#7fffff), Rest};
+pb_decode_value(<<Value:64/little-float, Rest/binary>>, double) ->
+  {ok, Value, Rest};
+pb_decode_value(<<Bits:64/little, Rest/binary>>, double) ->
+  {ok, pb_special_float(Bits bsr 63, Bits band 16#fffffffffffff), Rest};
+pb_decode_value(_, _) ->
+  error({decode_error, truncated_data}).
+
+%% Truncate a decoded value to the size of its type; negative 32 bit
+%% integers are encoded as 64 bit integers.
+-spec pb_integer(non_neg_integer(), 32 | 64, signed | unsigned) -> integer().
+pb_integer(Value, Bits, unsigned) ->
+  <<Integer:Bits/unsigned>> = <<Value:Bits>>,
+  Integer;
+pb_integer(Value, Bits, signed) ->
+  <<Integer:Bits/signed>> = <<Value:Bits>>,
+  Integer.
+
+-spec pb_special_float(0 | 1, non_neg_integer()) ->
+        infinity | '-infinity' | nan.
+pb_special_float(_, Mantissa) when Mantissa =/= 0 ->
+  nan;
+pb_special_float(0, 0) ->
+  infinity;
+pb_special_float(1, 0) ->
+  '-infinity'.
+
+-spec pb_decode_string(binary(), iodata | binary | chardata | list,
+                       boolean()) ->
+        unicode:chardata().
+pb_decode_string(Bin, Repr, ValidateUTF8) ->
+  case ValidateUTF8 andalso unicode:characters_to_binary(Bin) =/= Bin of
+    true ->
+      error({decode_error, {invalid_utf8_string, Bin}});
+    false when Repr =:= list ->
+      case unicode:characters_to_list(Bin) of
+        String when is_list(String) ->
+          String;
+        _ ->
+          error({decode_error, {invalid_utf8_string, Bin}})
+      end;
+    false ->
+      Bin
+  end.
+
+-spec pb_decode_map_entry(binary(), term(), term(), {term(), term()},
+                          map()) ->
+        {term(), term()}.
+pb_decode_map_entry(<<>>, _, _, Entry, _) ->
+  Entry;
+pb_decode_map_entry(Data, KeyType, ValueType, {Key, Value} = Entry,
+                    Context) ->
+  {FieldKey, Data2} = pb_decode_varint(Data),
+  WireType = FieldKey band 7,
+  {Type, Update} =
+    case FieldKey bsr 3 of
+      1 -> {KeyType, fun (K) -> {K, Value} end};
+      2 -> {ValueType, fun (V) -> {Key, V} end};
+      _ -> {undefined, undefined}
+    end,
+  case Type =/= undefined andalso pb_wire_type(Type) =:= WireType of
+    true ->
+      {ok, V, Rest} = pb_decode_value(Data2, Type, Context),
+      pb_decode_map_entry(Rest, KeyType, ValueType, Update(V), Context);
+    false ->
+      Rest = pb_skip_value(WireType, Data2),
+      pb_decode_map_entry(Rest, KeyType, ValueType, Entry, Context)
+  end.
+
+-spec pb_default(term()) -> term().
+pb_default(bool) ->
+  false;
+pb_default(Type) when Type =:= float; Type =:= double ->
+  0.0;
+pb_default({string, list, _}) ->
+  [];
+pb_default({string, _, _}) ->
+  <<>>;
+pb_default(bytes) ->
+  <<>>;
+pb_default({enum, _, FromInt}) ->
+  FromInt(0);
+pb_default({message, _, _}) ->
+  undefined;
+pb_default(_) ->
+  0.
+
+-spec pb_decode_varint(binary()) -> {non_neg_integer(), binary()}.
+pb_decode_varint(Data) ->
+  pb_decode_varint(Data, 0, 0).
+
+-spec pb_decode_varint(binary(), non_neg_integer(), non_neg_integer()) ->
+        {non_neg_integer(), binary()}.
+pb_decode_varint(<<1:1, Byte:7, Rest/binary>>, Shift, Acc) when Shift < 63 ->
+  pb_decode_varint(Rest, Shift + 7, Acc bor (Byte bsl Shift));
+pb_decode_varint(<<0:1, Byte:7, Rest/binary>>, Shift, Acc) ->
+  {Acc bor (Byte bsl Shift), Rest};
+pb_decode_varint(<<>>, _, _) ->
+  error({decode_error, truncated_varint});
+pb_decode_varint(_, _, _) ->
+  error({decode_error, invalid_varint}).
+
+-spec pb_decode_bytes(binary()) -> {binary(), binary()}.
+pb_decode_bytes(Data) ->
+  {Length, Rest} = pb_decode_varint(Data),
+  case Rest of
+    <<Bin:Length/binary, Rest2/binary>> ->
+      {Bin, Rest2};
+    _ ->
+      error({decode_error, truncated_data})
+  end.
+
+-spec pb_skip_value(0..7, binary()) -> binary().
+pb_skip_value(0, Data) ->
+  {_, Rest} = pb_decode_varint(Data),
+  Rest;
+pb_skip_value(1, <<_:64, Rest/binary>>) ->
+  Rest;
+pb_skip_value(2, Data) ->
+  {_, Rest} = pb_decode_bytes(Data),
+  Rest;
+pb_skip_value(5, <<_:32, Rest/binary>>) ->
+  Rest;
+pb_skip_value(WireType, _) when WireType =:= 1; WireType =:= 5 ->
+  error({decode_error, truncated_data});
+pb_skip_value(WireType, _) ->
+  error({decode_error, {invalid_wire_type, WireType}}).
+{{- end }}
+`
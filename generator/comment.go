@@ -0,0 +1,308 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+type DocFormat string
+
+const (
+	DocFormatEDoc       DocFormat = "edoc"
+	DocFormatAttributes DocFormat = "attributes"
+)
+
+// Comments are extracted from the source code information of file
+// descriptors, and rendered either as EDoc comments or as -doc and
+// -moduledoc attributes (OTP 27+).
+type Comment struct {
+	Lines  []string
+	Format DocFormat
+
+	// Set for elements deprecated in the protobuf schema; the comment
+	// may have no lines.
+	Deprecated bool
+}
+
+const deprecatedText = "Deprecated in the protobuf schema."
+
+// Paths are sequences of field numbers and indexes identifying an element
+// in a file descriptor, e.g. [4, 0, 2, 1] for the second field of the
+// first message. See the SourceCodeInfo message in descriptor.proto.
+const (
+	FileDescriptorMessageTypePath = 4
+	FileDescriptorEnumTypePath    = 5
+	FileDescriptorServicePath     = 6
+
+	MessageDescriptorFieldPath      = 2
+	MessageDescriptorNestedTypePath = 3
+	MessageDescriptorEnumTypePath   = 4
+
+	EnumDescriptorValuePath = 2
+
+	ServiceDescriptorMethodPath = 2
+)
+
+type Comments map[string]*Comment
+
+func CommentsFromFileDescriptor(fd *descriptor.FileDescriptorProto, format DocFormat) Comments {
+	comments := make(Comments)
+
+	for _, loc := range fd.GetSourceCodeInfo().GetLocation() {
+		lines := CommentTextLines(loc.GetLeadingComments())
+		lines = append(lines,
+			CommentTextLines(loc.GetTrailingComments())...)
+		if len(lines) == 0 {
+			continue
+		}
+
+		comments[commentPathKey(loc.Path)] = &Comment{
+			Lines:  lines,
+			Format: format,
+		}
+	}
+
+	return comments
+}
+
+func (comments Comments) Find(path ...int32) *Comment {
+	return comments[commentPathKey(path)]
+}
+
+func commentPathKey(path []int32) string {
+	parts := make([]string, len(path))
+	for i, n := range path {
+		parts[i] = strconv.Itoa(int(n))
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// CommentTextLines splits a comment into lines, removing the space
+// following comment markers and leading and trailing empty lines.
+func CommentTextLines(text string) []string {
+	var lines []string
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(strings.TrimPrefix(line, " "), " \t\r")
+		lines = append(lines, line)
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// ErlComment returns the comment as plain Erlang comment lines without
+// final newline, used for elements which cannot be documented such as
+// record fields.
+func (c *Comment) ErlComment(indent string) string {
+	var lines []string
+
+	for _, line := range c.textLines() {
+		lines = append(lines, indent+strings.TrimRight("%% "+line, " "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ErlDoc returns the documentation of a function or callback without final
+// newline. With EDoc, it is meant to follow another comment line.
+func (c *Comment) ErlDoc() string {
+	if c.Format == DocFormatAttributes {
+		return c.erlDocAttributes("doc")
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString("%%\n")
+
+	for i, line := range c.Lines {
+		prefix := "%% "
+		if i == 0 {
+			prefix = "%% @doc "
+		}
+
+		buf.WriteString(strings.TrimRight(prefix+erlEDocEscape(line), " "))
+		buf.WriteByte('\n')
+	}
+
+	if c.Deprecated {
+		buf.WriteString("%% @deprecated " + deprecatedText + "\n")
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// ErlTypeDoc returns the documentation of a type without final newline.
+// With EDoc, it is meant to follow another comment line.
+func (c *Comment) ErlTypeDoc() string {
+	if c.Format == DocFormatAttributes {
+		return c.erlDocAttributes("doc")
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString("%%\n")
+
+	for _, line := range c.textLines() {
+		buf.WriteString(strings.TrimRight("%% "+erlEDocEscape(line), " "))
+		buf.WriteByte('\n')
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// ErlModuleComment returns the documentation of a module as lines to be
+// appended to the module header comment when using EDoc.
+func (c *Comment) ErlModuleComment() string {
+	if c.Format == DocFormatAttributes {
+		return ""
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString("\n%%%")
+
+	for _, line := range c.Lines {
+		buf.WriteString(strings.TrimRight("\n%%% "+erlEDocEscape(line), " "))
+	}
+
+	if c.Deprecated {
+		buf.WriteString("\n%%% @deprecated " + deprecatedText)
+	}
+
+	return buf.String()
+}
+
+// ErlModuleDoc returns the -moduledoc attribute of a module when using
+// documentation attributes.
+func (c *Comment) ErlModuleDoc() string {
+	if c.Format != DocFormatAttributes {
+		return ""
+	}
+
+	return "\n" + c.erlDocAttributes("moduledoc")
+}
+
+// textLines returns the lines of the comment followed by a deprecation
+// notice if the element is deprecated.
+func (c *Comment) textLines() []string {
+	if !c.Deprecated {
+		return c.Lines
+	}
+
+	lines := append([]string{}, c.Lines...)
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+
+	return append(lines, deprecatedText)
+}
+
+// erlDocAttributes returns documentation attributes without final newline:
+// the documentation text if there is one, followed by deprecation metadata.
+func (c *Comment) erlDocAttributes(name string) string {
+	var attrs []string
+
+	if len(c.Lines) > 0 {
+		attrs = append(attrs,
+			strings.TrimSuffix(erlDocAttribute(name, c.Lines), "\n"))
+	}
+
+	if c.Deprecated {
+		attrs = append(attrs, fmt.Sprintf("-%s #{deprecated => %q}.",
+			name, deprecatedText))
+	}
+
+	return strings.Join(attrs, "\n")
+}
+
+// MarkDeprecated returns a copy of the comment flagged as deprecated if
+// deprecated is true, or the comment itself otherwise. The comment can be
+// nil.
+func (c *Comment) MarkDeprecated(format DocFormat, deprecated bool) *Comment {
+	if !deprecated {
+		return c
+	}
+
+	c2 := Comment{Format: format, Deprecated: true}
+	if c != nil {
+		c2.Lines = c.Lines
+	}
+
+	return &c2
+}
+
+// Append returns a new comment containing the lines of the comment
+// followed by additional lines. Both the comment and the result can be nil.
+func (c *Comment) Append(format DocFormat, lines ...string) *Comment {
+	if len(lines) == 0 {
+		return c
+	}
+
+	c2 := Comment{Format: format}
+
+	if c != nil {
+		c2.Deprecated = c.Deprecated
+		c2.Lines = append(c2.Lines, c.Lines...)
+	}
+
+	if len(c2.Lines) > 0 {
+		c2.Lines = append(c2.Lines, "")
+	}
+
+	c2.Lines = append(c2.Lines, lines...)
+
+	return &c2
+}
+
+func erlDocAttribute(name string, lines []string) string {
+	var buf strings.Builder
+
+	buf.WriteString("-" + name + " \"\"\"\n")
+
+	for _, line := range lines {
+		// Triple-quoted strings cannot contain a line starting with
+		// three double quotes.
+		if strings.HasPrefix(strings.TrimLeft(line, " \t"), `"""`) {
+			line = strings.Replace(line, `"""`, `\"""`, 1)
+		}
+
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteString("\"\"\".\n")
+
+	return buf.String()
+}
+
+func erlEDocEscape(s string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;",
+		"@", "&#64;", "{", "&#123;", "}", "&#125;")
+	return r.Replace(s)
+}
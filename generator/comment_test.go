@@ -0,0 +1,47 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommentTextLines(t *testing.T) {
+	tests := []struct {
+		text  string
+		lines []string
+	}{
+		{"", []string{}},
+		{"\n\n", []string{}},
+		{" Hello world.\n", []string{"Hello world."}},
+		{" First line.\n Second line.\n",
+			[]string{"First line.", "Second line."}},
+		{"\n First paragraph.\n\n Second paragraph.\n\n",
+			[]string{"First paragraph.", "", "Second paragraph."}},
+		{" Trailing spaces. \t\r\n", []string{"Trailing spaces."}},
+		{" Example:\n\n   foo(Bar).\n",
+			[]string{"Example:", "", "  foo(Bar)."}},
+		{"No leading space.", []string{"No leading space."}},
+	}
+
+	for _, test := range tests {
+		lines := CommentTextLines(test.text)
+		if !reflect.DeepEqual(lines, test.lines) {
+			t.Errorf("%q: lines are %q but should be %q",
+				test.text, lines, test.lines)
+		}
+	}
+}
@@ -26,6 +26,13 @@ type EnumValue struct {
 	Name   string
 	Number int
 
+	AliasOf *EnumValue // first value declared with the same number
+	Aliases EnumValues // all values sharing the same number
+
+	Deprecated bool
+
+	Comment *Comment
+
 	ErlName string
 }
 
@@ -35,16 +42,30 @@ func (enumValue *EnumValue) FromDescriptor(evd *descriptor.EnumValueDescriptorPr
 	ev := EnumValue{
 		Name:   evd.GetName(),
 		Number: int(evd.GetNumber()),
+
+		Deprecated: evd.GetOptions().GetDeprecated(),
 	}
 
-	ev.ErlName = EnumValueNameToErlAtom(ev.Name)
+	ev.ErlName = EnumValueNameToErlAtom(ev.Name, "")
 
 	*enumValue = ev
 	return nil
 }
 
-func EnumValueNameToErlAtom(name string) string {
-	return strings.ToLower(name)
+// EnumValueNameToErlAtom converts the name of an enum value to an Erlang
+// atom. If enumName is not empty, the snake-cased enum name is stripped from
+// the start of the value name when present.
+func EnumValueNameToErlAtom(name, enumName string) string {
+	atom := strings.ToLower(name)
+
+	if enumName != "" {
+		prefix := CamelCaseToSnakeCase(enumName) + "_"
+		if len(atom) > len(prefix) && strings.HasPrefix(atom, prefix) {
+			atom = atom[len(prefix):]
+		}
+	}
+
+	return atom
 }
 
 type EnumType struct {
@@ -55,10 +76,21 @@ type EnumType struct {
 	FullName     string
 	AbsoluteName string
 
-	Values EnumValues
+	AllowAlias bool
+	Deprecated bool
+
+	// The name of the enum for well-known types of the google.protobuf
+	// package, or an empty string for all other types.
+	WellKnownType string
 
-	ErlPackage string
-	ErlName    string
+	Values          EnumValues
+	CanonicalValues EnumValues // one value per number
+
+	Comment *Comment // includes the comments of values
+
+	ErlPackage  string
+	ErlName     string
+	ErlTypeName string
 }
 
 type EnumTypes []*EnumType
@@ -69,13 +101,33 @@ func (enumType *EnumType) FromDescriptor(fd *descriptor.FileDescriptorProto, ed
 
 		Package: fd.GetPackage(),
 		Name:    ed.GetName(),
+
+		AllowAlias: ed.GetOptions().GetAllowAlias(),
+		Deprecated: ed.GetOptions().GetDeprecated(),
 	}
 
 	et.FullName = EnumTypeFullName(&et)
 	et.AbsoluteName = "." + et.Package + "." + et.FullName
 
-	et.ErlPackage = ProtoPackageNameToErlModuleName(et.Package)
-	et.ErlName = EnumTypeFullNameToErlName(et.FullName)
+	if et.Package == "google.protobuf" {
+		et.WellKnownType = et.FullName
+	}
+
+	erlPackage, err := ErlFileModuleName(fd)
+	if err != nil {
+		return err
+	}
+
+	erlName, err := ErlEnumNameOption(ed.GetOptions())
+	if err != nil {
+		return err
+	} else if erlName == "" {
+		erlName = EnumTypeFullNameToErlName(et.FullName)
+	}
+
+	et.ErlPackage = erlPackage
+	et.ErlName = erlName
+	et.ErlTypeName = ErlTypeName(erlName, "enum")
 
 	if len(ed.Value) == 0 {
 		return errors.New("no value found")
@@ -91,10 +143,81 @@ func (enumType *EnumType) FromDescriptor(fd *descriptor.FileDescriptorProto, ed
 		et.Values = append(et.Values, &ev)
 	}
 
+	if err := et.resolveAliases(); err != nil {
+		return err
+	}
+
 	*enumType = et
 	return nil
 }
 
+func (et *EnumType) resolveAliases() error {
+	numberToValue := make(map[int]*EnumValue)
+
+	for _, ev := range et.Values {
+		canonical, found := numberToValue[ev.Number]
+		if !found {
+			numberToValue[ev.Number] = ev
+			et.CanonicalValues = append(et.CanonicalValues, ev)
+			continue
+		}
+
+		if !et.AllowAlias {
+			return fmt.Errorf("values %s and %s share number %d "+
+				"but aliases are not allowed",
+				canonical.Name, ev.Name, ev.Number)
+		}
+
+		ev.AliasOf = canonical
+	}
+
+	for _, ev := range et.Values {
+		canonical := ev
+		if ev.AliasOf != nil {
+			canonical = ev.AliasOf
+		}
+
+		canonical.Aliases = append(canonical.Aliases, ev)
+	}
+
+	for _, ev := range et.Values {
+		if ev.AliasOf != nil {
+			ev.Aliases = ev.AliasOf.Aliases
+		}
+	}
+
+	return nil
+}
+
+// StripValuePrefixes renames values so that their Erlang name does not
+// include the name of the enum. Values whose stripped name would not be a
+// valid unquoted atom keep their full name; if stripping would create
+// duplicate atoms, no value is renamed and the function returns false.
+func (et *EnumType) StripValuePrefixes() bool {
+	names := make([]string, len(et.Values))
+	seen := make(map[string]bool)
+
+	for i, ev := range et.Values {
+		name := EnumValueNameToErlAtom(ev.Name, et.Name)
+		if !IsErlUnquotedAtom(name) {
+			name = ev.ErlName
+		}
+
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+
+		names[i] = name
+	}
+
+	for i, ev := range et.Values {
+		ev.ErlName = names[i]
+	}
+
+	return true
+}
+
 func EnumTypeFullName(et *EnumType) string {
 	if et.Parent == nil {
 		return et.Name
@@ -0,0 +1,83 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnumTypeStripValuePrefixes(t *testing.T) {
+	tests := []struct {
+		enumName string
+		values   []string
+		stripped bool
+		names    []string
+	}{
+		{"BookType",
+			[]string{"BOOK_TYPE_UNSPECIFIED", "BOOK_TYPE_FICTION"},
+			true,
+			[]string{"unspecified", "fiction"}},
+		{"Mode",
+			[]string{"MODE_UNSPECIFIED", "READ", "MODE_WRITE"},
+			true,
+			[]string{"unspecified", "read", "write"}},
+		{"Operator",
+			[]string{"OPERATOR_AND", "OPERATOR_1", "OPERATOR_XOR_ALL"},
+			true,
+			[]string{"operator_and", "operator_1", "xor_all"}},
+		{"Level",
+			[]string{"LEVEL"},
+			true,
+			[]string{"level"}},
+		{"Shape",
+			[]string{"SHAPE_UNSPECIFIED", "SHAPE_CIRCLE", "CIRCLE"},
+			false,
+			[]string{"shape_unspecified", "shape_circle", "circle"}},
+		{"Shape",
+			[]string{"SHAPE_AND", "OPERATOR_AND"},
+			true,
+			[]string{"shape_and", "operator_and"}},
+		{"Shape",
+			[]string{"SHAPE_SHAPE_AND", "SHAPE_AND"},
+			false,
+			[]string{"shape_shape_and", "shape_and"}},
+	}
+
+	for _, test := range tests {
+		et := EnumType{Name: test.enumName}
+		for _, name := range test.values {
+			et.Values = append(et.Values, &EnumValue{
+				Name:    name,
+				ErlName: EnumValueNameToErlAtom(name, ""),
+			})
+		}
+
+		if stripped := et.StripValuePrefixes(); stripped != test.stripped {
+			t.Errorf("%s %v: stripping returned %t but should return %t",
+				test.enumName, test.values, stripped, test.stripped)
+		}
+
+		names := make([]string, len(et.Values))
+		for i, ev := range et.Values {
+			names[i] = ev.ErlName
+		}
+
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("%s %v: names are %q but should be %q",
+				test.enumName, test.values, names, test.names)
+		}
+	}
+}
@@ -14,9 +14,190 @@
 
 package generator
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
+
+type ErlMacro struct {
+	Name   string
+	Value  string
+	Source string // description of the element the macro was generated for
+}
+
+type ErlMacros []*ErlMacro
+
+type ErlFunction struct {
+	Name       string
+	Arity      int
+	Deprecated bool
+	Source     string // description of the generating element
+}
+
+type ErlFunctions []*ErlFunction
+
+func (fns *ErlFunctions) Add(name string, arity int, deprecated bool, source string) {
+	fn := ErlFunction{
+		Name:       name,
+		Arity:      arity,
+		Deprecated: deprecated,
+		Source:     source,
+	}
+
+	*fns = append(*fns, &fn)
+}
+
+var ErlReservedWords = map[string]bool{
+	"after": true, "and": true, "andalso": true, "band": true,
+	"begin": true, "bnot": true, "bor": true, "bsl": true, "bsr": true,
+	"bxor": true, "case": true, "catch": true, "cond": true, "div": true,
+	"else": true, "end": true, "fun": true, "if": true, "let": true,
+	"maybe": true, "not": true, "of": true, "or": true, "orelse": true,
+	"receive": true, "rem": true, "try": true, "when": true, "xor": true,
+}
+
+// ErlBuiltinTypes contains the names of the types predefined by Erlang; user
+// types with the same name cannot be defined.
+var ErlBuiltinTypes = map[string]bool{
+	"any": true, "arity": true, "atom": true, "binary": true,
+	"bitstring": true, "bool": true, "boolean": true, "byte": true,
+	"char": true, "dynamic": true, "float": true, "fun": true,
+	"function": true, "identifier": true, "integer": true, "iodata": true,
+	"iolist": true, "list": true, "map": true, "maybe_improper_list": true,
+	"mfa": true, "module": true, "neg_integer": true, "nil": true,
+	"no_return": true, "node": true, "non_neg_integer": true, "none": true,
+	"nonempty_binary": true, "nonempty_bitstring": true,
+	"nonempty_improper_list": true, "nonempty_list": true,
+	"nonempty_maybe_improper_list": true, "nonempty_string": true,
+	"number": true, "pid": true, "port": true, "pos_integer": true,
+	"reference": true, "string": true, "term": true, "timeout": true,
+	"tuple": true,
+}
+
+// ErlTypeName returns the name of the Erlang type generated for a message or
+// enum named name. Since builtin types cannot be redefined, suffix is
+// appended to names which conflict with them (e.g. any_message for
+// google.protobuf.Any).
+func ErlTypeName(name, suffix string) string {
+	if ErlBuiltinTypes[name] {
+		return name + "_" + suffix
+	}
+
+	return name
+}
+
+// IsErlUnquotedAtom returns true if s can be used as an Erlang atom without
+// single quotes.
+func IsErlUnquotedAtom(s string) bool {
+	if s == "" || s[0] < 'a' || s[0] > 'z' {
+		return false
+	}
+
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+		case c == '_' || c == '@':
+		default:
+			return false
+		}
+	}
+
+	return !ErlReservedWords[s]
+}
 
 func ProtoPackageNameToErlModuleName(name string) string {
 	lowerName := strings.ToLower(name)
 	return strings.ReplaceAll(lowerName, ".", "_")
 }
+
+// ErlBinaryLiteral formats data as an Erlang binary, wrapping lines and
+// indenting continuation lines with indent.
+func ErlBinaryLiteral(data []byte, indent string) string {
+	var buf strings.Builder
+
+	buf.WriteString("<<")
+
+	for i, b := range data {
+		if i > 0 {
+			buf.WriteByte(',')
+
+			if i%20 == 0 {
+				buf.WriteByte('\n')
+				buf.WriteString(indent)
+			}
+		}
+
+		buf.WriteString(strconv.Itoa(int(b)))
+	}
+
+	buf.WriteString(">>")
+
+	return buf.String()
+}
+
+// ErlFloat formats a finite floating point number as an Erlang float.
+func ErlFloat(f float64) string {
+	// Erlang float literals always contain a decimal point
+	value := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.Contains(value, ".") {
+		if i := strings.IndexByte(value, 'e'); i >= 0 {
+			value = value[:i] + ".0" + value[i:]
+		} else {
+			value += ".0"
+		}
+	}
+
+	return value
+}
+
+// ErlString formats a string as an Erlang string, i.e. a list of code
+// points, e.g. "foo".
+func ErlString(s string) string {
+	var buf strings.Builder
+
+	buf.WriteByte('"')
+
+	for _, c := range s {
+		if c == '"' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+
+		buf.WriteRune(c)
+	}
+
+	buf.WriteByte('"')
+
+	return buf.String()
+}
+
+// ErlBinaryString formats a string as an Erlang binary string, e.g.
+// <<"foo">>.
+func ErlBinaryString(s string) string {
+	var buf strings.Builder
+
+	buf.WriteString("<<\"")
+
+	ascii := true
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(c)
+		case c > 127:
+			ascii = false
+			buf.WriteRune(c)
+		default:
+			buf.WriteRune(c)
+		}
+	}
+
+	if ascii {
+		buf.WriteString("\">>")
+	} else {
+		buf.WriteString("\"/utf8>>")
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Custom options declared in erlang/options.proto.
+
+const (
+	ErlModuleOptionNumber     = 51700
+	ErlRecordNameOptionNumber = 51710
+	ErlFieldNameOptionNumber  = 51720
+	ErlStringAsOptionNumber   = 51721
+	ErlMapReprOptionNumber    = 51722
+	ErlEnumNameOptionNumber   = 51730
+)
+
+// The Erlang representation of string fields and, for StringAsIOData and
+// StringAsBinary, of bytes fields.
+type StringAs string
+
+const (
+	StringAsDefault  StringAs = "" // use the string_repr or bytes_repr option
+	StringAsIOData   StringAs = "iodata"
+	StringAsBinary   StringAs = "binary"
+	StringAsChardata StringAs = "chardata"
+	StringAsList     StringAs = "list"
+)
+
+type MapRepr string
+
+const (
+	MapReprDefault MapRepr = ""
+	MapReprMap     MapRepr = "map"
+)
+
+// ErlFileModuleName returns the name of the Erlang module generated for the
+// package of a file, using the erlang.module option if it is set.
+func ErlFileModuleName(fd *descriptor.FileDescriptorProto) (string, error) {
+	name := ProtoPackageNameToErlModuleName(fd.GetPackage())
+
+	if opts := fd.GetOptions(); opts != nil {
+		value, err := erlAtomOption(opts, ErlModuleOptionNumber,
+			"erlang.module")
+		if err != nil {
+			return "", err
+		} else if value != "" {
+			name = value
+		}
+	}
+
+	return name, nil
+}
+
+func ErlMessageRecordNameOption(opts *descriptor.MessageOptions) (string, error) {
+	if opts == nil {
+		return "", nil
+	}
+
+	return erlAtomOption(opts, ErlRecordNameOptionNumber,
+		"erlang.record_name")
+}
+
+func ErlFieldNameOption(opts *descriptor.FieldOptions) (string, error) {
+	if opts == nil {
+		return "", nil
+	}
+
+	return erlAtomOption(opts, ErlFieldNameOptionNumber,
+		"erlang.field_name")
+}
+
+func ErlEnumNameOption(opts *descriptor.EnumOptions) (string, error) {
+	if opts == nil {
+		return "", nil
+	}
+
+	return erlAtomOption(opts, ErlEnumNameOptionNumber, "erlang.enum_name")
+}
+
+func ErlStringAsOption(opts *descriptor.FieldOptions) (StringAs, error) {
+	if opts == nil {
+		return StringAsDefault, nil
+	}
+
+	value, err := erlEnumOption(opts, ErlStringAsOptionNumber,
+		"erlang.string_as")
+	if err != nil {
+		return "", err
+	}
+
+	switch value {
+	case 0:
+		return StringAsDefault, nil
+	case 1:
+		return StringAsBinary, nil
+	case 2:
+		return StringAsList, nil
+	case 3:
+		return StringAsChardata, nil
+	case 4:
+		return StringAsIOData, nil
+	default:
+		return "", fmt.Errorf("invalid value %d for option "+
+			"erlang.string_as", value)
+	}
+}
+
+func ErlMapReprOption(opts *descriptor.FieldOptions) (MapRepr, error) {
+	if opts == nil {
+		return MapReprDefault, nil
+	}
+
+	value, err := erlEnumOption(opts, ErlMapReprOptionNumber,
+		"erlang.map_repr")
+	if err != nil {
+		return "", err
+	}
+
+	switch value {
+	case 0:
+		return MapReprDefault, nil
+	case 1:
+		return MapReprMap, nil
+	default:
+		return "", fmt.Errorf("invalid value %d for option "+
+			"erlang.map_repr", value)
+	}
+}
+
+// Names set with options are used as is in generated code, so they must be
+// valid unquoted atoms.
+func erlAtomOption(msg proto.Message, number int32, name string) (string, error) {
+	fields, err := RawExtension(msg, number, name)
+	if err != nil {
+		return "", err
+	} else if fields == nil {
+		return "", nil
+	}
+
+	value := fields.String(number)
+	if !IsErlUnquotedAtom(value) {
+		return "", fmt.Errorf("invalid value %q for option %s: "+
+			"value is not a valid unquoted erlang atom", value, name)
+	}
+
+	return value, nil
+}
+
+func erlEnumOption(msg proto.Message, number int32, name string) (uint64, error) {
+	fields, err := RawExtension(msg, number, name)
+	if err != nil {
+		return 0, err
+	} else if len(fields) == 0 {
+		return 0, nil
+	}
+
+	return fields[len(fields)-1].Varint, nil
+}
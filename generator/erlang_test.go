@@ -0,0 +1,226 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// Each Erlang test suite is made of a proto file test/<name>.proto, the
+// descriptor set test/<name>.pb built from it by the test-descriptors target
+// of the makefile, and an EUnit module test/<name>_tests.erl.
+//
+// Modules are generated for the suite file and for additional files, with
+// one generator run per package. They are then compiled with the EUnit
+// module and tests are run if erlc and erl are available.
+type erlTestSuite struct {
+	Name      string
+	Parameter string
+	Files     []string // additional files of the descriptor set
+}
+
+var erlTestSuites = []erlTestSuite{
+	{Name: "golden", Parameter: "verify"},
+	{Name: "enum_aliases"},
+	{Name: "enum_prefixes", Parameter: "strip_enum_prefix"},
+	{Name: "macros", Parameter: "hrl_macros"},
+	{Name: "descriptors",
+		Files: []string{"google/protobuf/descriptor.proto"}},
+	{Name: "reflection"},
+	{Name: "json_mapping", Parameter: "json",
+		Files: []string{
+			"google/protobuf/duration.proto",
+			"google/protobuf/field_mask.proto",
+			"google/protobuf/struct.proto",
+			"google/protobuf/timestamp.proto",
+			"google/protobuf/wrappers.proto",
+		}},
+	{Name: "json_string_lists", Parameter: "json,string_repr=string"},
+	{Name: "accessors", Parameter: "accessors"},
+	{Name: "merge"},
+	{Name: "field_masks", Parameter: "field_masks"},
+	{Name: "encoding"},
+	{Name: "unknown_fields", Parameter: "verify"},
+	{Name: "decode_limits", Parameter: "json,verify"},
+	{Name: "decode_errors"},
+}
+
+const erlTestDirectory = "../test"
+
+func TestErlangSuites(t *testing.T) {
+	_, erlcErr := exec.LookPath("erlc")
+	_, erlErr := exec.LookPath("erl")
+	erlang := erlcErr == nil && erlErr == nil
+
+	for _, suite := range erlTestSuites {
+		suite := suite
+
+		t.Run(suite.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "protoc-gen-erlang-test-")
+			if err != nil {
+				t.Fatalf("cannot create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			paths := generateErlTestSuite(t, suite, dir)
+
+			if !erlang {
+				t.Skip("erlc and erl are required to run erlang tests")
+			}
+
+			runErlTestSuite(t, suite, dir, paths)
+		})
+	}
+}
+
+func readErlTestSuiteDescriptors(t *testing.T, suite erlTestSuite) []*descriptor.FileDescriptorProto {
+	filePath := filepath.Join(erlTestDirectory, suite.Name+".pb")
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("cannot read %s: %v", filePath, err)
+	}
+
+	var set descriptor.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		t.Fatalf("cannot decode %s: %v", filePath, err)
+	}
+
+	return set.File
+}
+
+func generateErlTestSuite(t *testing.T, suite erlTestSuite, dir string) []string {
+	fds := readErlTestSuiteDescriptors(t, suite)
+
+	filePackages := make(map[string]string)
+	for _, fd := range fds {
+		filePackages[fd.GetName()] = fd.GetPackage()
+	}
+
+	var packages []string
+	packageFiles := make(map[string][]string)
+
+	for _, file := range append([]string{suite.Name + ".proto"}, suite.Files...) {
+		pkg, found := filePackages[file]
+		if !found {
+			t.Fatalf("file %s not found in descriptor set", file)
+		}
+
+		if _, found := packageFiles[pkg]; !found {
+			packages = append(packages, pkg)
+		}
+
+		packageFiles[pkg] = append(packageFiles[pkg], file)
+	}
+
+	var paths []string
+
+	for _, pkg := range packages {
+		res, err := generateErlTestFiles(fds, packageFiles[pkg],
+			suite.Parameter)
+		if err != nil {
+			t.Fatalf("cannot generate package %s: %v", pkg, err)
+		}
+
+		for _, f := range res.File {
+			filePath := filepath.Join(dir, filepath.FromSlash(f.GetName()))
+
+			err := os.MkdirAll(filepath.Dir(filePath), 0755)
+			if err != nil {
+				t.Fatalf("cannot create directory: %v", err)
+			}
+
+			err = ioutil.WriteFile(filePath, []byte(f.GetContent()), 0644)
+			if err != nil {
+				t.Fatalf("cannot write %s: %v", filePath, err)
+			}
+
+			paths = append(paths, filePath)
+		}
+	}
+
+	return paths
+}
+
+func generateErlTestFiles(fds []*descriptor.FileDescriptorProto, files []string, parameter string) (*plugin.CodeGeneratorResponse, error) {
+	req := plugin.CodeGeneratorRequest{
+		FileToGenerate: files,
+		Parameter:      proto.String(parameter),
+		ProtoFile:      fds,
+	}
+
+	g, err := NewGenerator(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	g.Verbose = false
+
+	if err := g.GenerateOutput(); err != nil {
+		return nil, err
+	}
+
+	return g.Response, nil
+}
+
+func runErlTestSuite(t *testing.T, suite erlTestSuite, dir string, paths []string) {
+	args := []string{"-o", dir}
+
+	includeDirs := make(map[string]bool)
+	var sources []string
+
+	for _, p := range paths {
+		switch filepath.Ext(p) {
+		case ".hrl":
+			includeDirs[filepath.Dir(p)] = true
+		case ".erl":
+			sources = append(sources, p)
+		}
+	}
+
+	for includeDir := range includeDirs {
+		args = append(args, "-I", includeDir)
+	}
+
+	testPath, err := filepath.Abs(
+		filepath.Join(erlTestDirectory, suite.Name+"_tests.erl"))
+	if err != nil {
+		t.Fatalf("cannot resolve test module path: %v", err)
+	}
+
+	args = append(args, sources...)
+	args = append(args, testPath)
+
+	if output, err := exec.Command("erlc", args...).CombinedOutput(); err != nil {
+		t.Fatalf("cannot compile erlang modules: %v\n%s", err, output)
+	}
+
+	eval := "case eunit:test(" + suite.Name + "_tests, [verbose]) of " +
+		"ok -> halt(0); _ -> halt(1) end."
+
+	output, err := exec.Command("erl", "-noshell", "-pa", dir,
+		"-eval", eval).CombinedOutput()
+	if err != nil {
+		t.Fatalf("erlang tests failed: %v\n%s", err, output)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Options messages such as MethodOptions keep extensions they do not know
+// about as encoded data. We decode them ourselves instead of depending on
+// the generated Go code of each extension.
+
+type RawField struct {
+	Number   int32
+	WireType int
+	Varint   uint64 // for varint, fixed32 and fixed64 fields
+	Bytes    []byte // for length-delimited fields
+}
+
+type RawFields []RawField
+
+func DecodeRawFields(data []byte) (RawFields, error) {
+	var fields RawFields
+
+	for len(data) > 0 {
+		key, n := proto.DecodeVarint(data)
+		if n == 0 {
+			return nil, fmt.Errorf("truncated field key")
+		}
+		data = data[n:]
+
+		field := RawField{
+			Number:   int32(key >> 3),
+			WireType: int(key & 0x7),
+		}
+
+		switch field.WireType {
+		case proto.WireVarint:
+			field.Varint, n = proto.DecodeVarint(data)
+
+		case proto.WireFixed64:
+			if len(data) >= 8 {
+				field.Varint = binary.LittleEndian.Uint64(data)
+				n = 8
+			}
+
+		case proto.WireFixed32:
+			if len(data) >= 4 {
+				field.Varint = uint64(binary.LittleEndian.Uint32(data))
+				n = 4
+			}
+
+		case proto.WireBytes:
+			var length uint64
+			length, n = proto.DecodeVarint(data)
+			if n > 0 && uint64(len(data)-n) >= length {
+				field.Bytes = data[n : n+int(length)]
+				n += int(length)
+			} else {
+				n = 0
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d for field %d",
+				field.WireType, field.Number)
+		}
+
+		if n == 0 {
+			return nil, fmt.Errorf("truncated field %d", field.Number)
+		}
+		data = data[n:]
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func (fields RawFields) Find(number int32) RawFields {
+	var matches RawFields
+
+	for _, field := range fields {
+		if field.Number == number {
+			matches = append(matches, field)
+		}
+	}
+
+	return matches
+}
+
+func (fields RawFields) String(number int32) string {
+	matches := fields.Find(number)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	return string(matches[len(matches)-1].Bytes)
+}
+
+// Return all occurrences of an extension as raw fields, or nil if the
+// extension is not set. The value of a message extension is available as
+// encoded data in the Bytes member of each field.
+func RawExtension(msg proto.Message, number int32, name string) (RawFields, error) {
+	extDesc := proto.ExtensionDesc{
+		ExtendedType: nil,
+		Field:        number,
+		Name:         name,
+	}
+
+	if !proto.HasExtension(msg, &extDesc) {
+		return nil, nil
+	}
+
+	value, err := proto.GetExtension(msg, &extDesc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read extension %s: %w", name, err)
+	}
+
+	data, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("extension %s has already been decoded", name)
+	}
+
+	fields, err := DecodeRawFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode extension %s: %w", name, err)
+	}
+
+	return fields, nil
+}
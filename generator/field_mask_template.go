@@ -0,0 +1,219 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+// Field mask functions accept either a google.protobuf.FieldMask record or a
+// list of paths. Paths are made of protobuf field names separated by dots;
+// only singular message fields, including message fields of oneofs, can
+// have sub-paths. Nested messages are handled by the field mask functions
+// of their own package, so all packages must be generated with the
+// field_masks option.
+//
+// When merging a source into a target, fields whose path is in the mask are
+// replaced, including repeated and message fields, so that a field set to
+// its default value in the source is cleared in the target.
+var erlFieldMaskTemplateContent = `
+{{- define "erl_message_field_mask" }}
+{{- $mt := . }}
+%% Return a copy of a message where only the fields selected by a mask are
+%% set.
+-spec mask_{{ .ErlName }}({{ .ErlTypeName }}(), pb_field_mask()) ->
+        {{ .ErlTypeName }}().
+mask_{{ .ErlName }}(Message, Mask) ->
+  Fields = mask_fields_{{ .ErlName }}(),
+  pb_check_mask(pb_validate_mask(Mask, Fields)),
+  pb_mask(Message, Mask, #{{ .ErlName }}{}, Fields).
+
+%% Replace the fields of a message selected by a mask by the fields of
+%% another message.
+-spec merge_masked_{{ .ErlName }}({{ .ErlTypeName }}(), {{ .ErlTypeName }}(), pb_field_mask()) ->
+        {{ .ErlTypeName }}().
+merge_masked_{{ .ErlName }}(Target, Source, Mask) ->
+  Fields = mask_fields_{{ .ErlName }}(),
+  pb_check_mask(pb_validate_mask(Mask, Fields)),
+  pb_merge_masked(Target, Source, Mask, Fields).
+
+%% Check that all paths of a mask refer to existing fields.
+-spec validate_mask_{{ .ErlName }}(pb_field_mask()) ->
+        ok | {error, {invalid_path, binary()}}.
+validate_mask_{{ .ErlName }}(Mask) ->
+  pb_validate_mask(Mask, mask_fields_{{ .ErlName }}()).
+
+-spec mask_fields_{{ .ErlName }}() -> #{binary() => tuple()}.
+mask_fields_{{ .ErlName }}() ->
+  #{
+  {{- range $i, $f := .Fields }}
+  {{- if gt $i 0 }},
+    {{ end }}
+  {{- "" }}<<"{{ $f.Name }}">> =>
+      {#{{ $mt.ErlName }}.{{ if $f.OneofType }}{{ $f.OneofType.ErlName }}, {{ $f.ErlName }}{{ else }}{{ $f.ErlName }}, undefined{{ end }}, {{ $f.ErlFieldMaskFunctions }}}
+  {{- end }}}.
+{{- end }}
+
+{{- define "erl_field_mask_support" }}
+%% google.protobuf.FieldMask records contain the list of paths and the list of
+%% unknown fields.
+-type pb_field_mask() :: {field_mask, [unicode:chardata()], list()}
+                       | [unicode:chardata()].
+
+-spec pb_mask_paths(pb_field_mask()) -> [binary()].
+pb_mask_paths({field_mask, Paths, _}) ->
+  pb_mask_paths(Paths);
+pb_mask_paths(Paths) when is_list(Paths) ->
+  [unicode:characters_to_binary(Path) || Path <- Paths].
+
+%% Group paths by their first field name; all indicates that the whole field
+%% is selected.
+-spec pb_mask_groups(pb_field_mask()) -> #{binary() => all | [binary()]}.
+pb_mask_groups(Mask) ->
+  lists:foldl(fun (Path, Groups) ->
+                  case binary:split(Path, <<".">>) of
+                    [Name] ->
+                      Groups#{Name => all};
+                    [Name, SubPath] ->
+                      case maps:get(Name, Groups, []) of
+                        all ->
+                          Groups;
+                        SubPaths ->
+                          Groups#{Name => [SubPath | SubPaths]}
+                      end
+                  end
+              end, #{}, pb_mask_paths(Mask)).
+
+-spec pb_validate_mask(pb_field_mask(), #{binary() => tuple()}) ->
+        ok | {error, {invalid_path, binary()}}.
+pb_validate_mask(Mask, Fields) ->
+  maps:fold(fun (Name, SubPaths, ok) ->
+                pb_validate_mask_field(Name, SubPaths, Fields);
+                (_, _, Error) ->
+                Error
+            end, ok, pb_mask_groups(Mask)).
+
+-spec pb_validate_mask_field(binary(), all | [binary()],
+                             #{binary() => tuple()}) ->
+        ok | {error, {invalid_path, binary()}}.
+pb_validate_mask_field(Name, SubPaths, Fields) ->
+  case maps:find(Name, Fields) of
+    {ok, _} when SubPaths =:= all ->
+      ok;
+    {ok, {_, _, {_, _, Validate}}} ->
+      case Validate(SubPaths) of
+        ok ->
+          ok;
+        {error, {invalid_path, Path}} ->
+          {error, {invalid_path, <<Name/binary, ".", Path/binary>>}}
+      end;
+    {ok, _} ->
+      [SubPath | _] = SubPaths,
+      {error, {invalid_path, <<Name/binary, ".", SubPath/binary>>}};
+    error ->
+      {error, {invalid_path, Name}}
+  end.
+
+-spec pb_check_mask(ok | {error, term()}) -> ok.
+pb_check_mask(ok) ->
+  ok;
+pb_check_mask({error, Reason}) ->
+  error({invalid_field_mask, Reason}).
+
+-spec pb_mask(tuple(), pb_field_mask(), tuple(), #{binary() => tuple()}) ->
+        tuple().
+pb_mask(Message, Mask, Result, Fields) ->
+  maps:fold(fun (Name, SubPaths, Acc) ->
+                Field = maps:get(Name, Fields),
+                pb_mask_field(Message, Acc, Field, SubPaths)
+            end, Result, pb_mask_groups(Mask)).
+
+-spec pb_mask_field(tuple(), tuple(), tuple(), all | [binary()]) -> tuple().
+pb_mask_field(Message, Result, {Pos, undefined, _}, all) ->
+  setelement(Pos, Result, element(Pos, Message));
+pb_mask_field(Message, Result, {Pos, Case, _}, all) ->
+  case element(Pos, Message) of
+    {Case, _} = Value ->
+      setelement(Pos, Result, Value);
+    _ ->
+      Result
+  end;
+pb_mask_field(Message, Result, {Pos, undefined, {Mask, _, _}}, SubPaths) ->
+  case element(Pos, Message) of
+    undefined ->
+      Result;
+    Value ->
+      setelement(Pos, Result, Mask(Value, SubPaths))
+  end;
+pb_mask_field(Message, Result, {Pos, Case, {Mask, _, _}}, SubPaths) ->
+  case element(Pos, Message) of
+    {Case, Value} ->
+      setelement(Pos, Result, {Case, Mask(Value, SubPaths)});
+    _ ->
+      Result
+  end.
+
+-spec pb_merge_masked(tuple(), tuple(), pb_field_mask(),
+                      #{binary() => tuple()}) ->
+        tuple().
+pb_merge_masked(Target, Source, Mask, Fields) ->
+  maps:fold(fun (Name, SubPaths, Acc) ->
+                Field = maps:get(Name, Fields),
+                pb_merge_masked_field(Acc, Source, Field, SubPaths)
+            end, Target, pb_mask_groups(Mask)).
+
+-spec pb_merge_masked_field(tuple(), tuple(), tuple(), all | [binary()]) ->
+        tuple().
+pb_merge_masked_field(Target, Source, {Pos, undefined, _}, all) ->
+  setelement(Pos, Target, element(Pos, Source));
+pb_merge_masked_field(Target, Source, {Pos, Case, _}, all) ->
+  case {element(Pos, Target), element(Pos, Source)} of
+    {_, {Case, _} = Value} ->
+      setelement(Pos, Target, Value);
+    {{ "{{" }}Case, _}, _} ->
+      setelement(Pos, Target, undefined);
+    _ ->
+      Target
+  end;
+pb_merge_masked_field(Target, Source, {Pos, undefined, Funs}, SubPaths) ->
+  Value = pb_merge_masked_value(element(Pos, Target), element(Pos, Source),
+                                Funs, SubPaths),
+  setelement(Pos, Target, Value);
+pb_merge_masked_field(Target, Source, {Pos, Case, Funs}, SubPaths) ->
+  TargetValue = case element(Pos, Target) of
+                  {Case, V1} -> V1;
+                  _ -> undefined
+                end,
+  SourceValue = case element(Pos, Source) of
+                  {Case, V2} -> V2;
+                  _ -> undefined
+                end,
+  case pb_merge_masked_value(TargetValue, SourceValue, Funs,
+                             SubPaths) of
+    undefined ->
+      Target;
+    Value ->
+      setelement(Pos, Target, {Case, Value})
+  end.
+
+%% Merge nested messages; an unset message is handled as an empty message,
+%% obtained by masking another message with an empty mask.
+-spec pb_merge_masked_value(term(), term(), tuple(), [binary()]) -> term().
+pb_merge_masked_value(undefined, undefined, _, _) ->
+  undefined;
+pb_merge_masked_value(undefined, Source, {Mask, MergeMasked, _}, SubPaths) ->
+  MergeMasked(Mask(Source, []), Source, SubPaths);
+pb_merge_masked_value(Target, undefined, {Mask, MergeMasked, _}, SubPaths) ->
+  MergeMasked(Target, Mask(Target, []), SubPaths);
+pb_merge_masked_value(Target, Source, {_, MergeMasked, _}, SubPaths) ->
+  MergeMasked(Target, Source, SubPaths).
+{{- end }}
+`
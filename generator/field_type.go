@@ -16,6 +16,10 @@ package generator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 )
@@ -35,6 +39,24 @@ type FieldType struct {
 	TypeId   FieldTypeId
 	TypeName string
 
+	JSONName string
+
+	DefaultValue string // proto2 default value in protoc text format
+
+	Deprecated bool
+
+	StringAs StringAs // from the erlang.string_as option or the generator
+	MapRepr  MapRepr  // from the erlang.map_repr option
+
+	SimpleIntegerSpecs bool // set by the generator
+
+	ValidationRules *ValidationRules // nil if the field has no rules
+
+	Comment *Comment
+
+	Packed       bool // set from the packed option and the file syntax
+	ValidateUTF8 bool // true for string fields of proto3 files
+
 	ErlName          string
 	ErlValueTypeSpec string // available after type resolution
 	ErlTypeSpec      string // available after type resolution
@@ -49,6 +71,16 @@ func (fieldType *FieldType) FromDescriptor(fid *descriptor.FieldDescriptorProto)
 		Number: int(fid.GetNumber()),
 
 		TypeName: fid.GetTypeName(),
+
+		JSONName: fid.GetJsonName(),
+
+		DefaultValue: fid.GetDefaultValue(),
+
+		Deprecated: fid.GetOptions().GetDeprecated(),
+	}
+
+	if ft.JSONName == "" {
+		ft.JSONName = SnakeCaseToLowerCamelCase(ft.Name)
 	}
 
 	switch fid.GetLabel() {
@@ -66,67 +98,93 @@ func (fieldType *FieldType) FromDescriptor(fid *descriptor.FieldDescriptorProto)
 		return fmt.Errorf("unsupported extendee field")
 	}
 
-	if fid.GetDefaultValue() != "" {
-		return fmt.Errorf("unsupported default value")
-	}
-
 	if err := ft.TypeId.FromProto(fid.GetType()); err != nil {
 		return fmt.Errorf("invalid type %d: %w", fid.GetType(), err)
 	}
 
-	ft.ErlName = ft.Name
+	erlName, err := ErlFieldNameOption(fid.GetOptions())
+	if err != nil {
+		return err
+	} else if erlName == "" {
+		erlName = ft.Name
+	}
+
+	ft.ErlName = erlName
+
+	stringAs, err := ErlStringAsOption(fid.GetOptions())
+	if err != nil {
+		return err
+	}
+
+	if stringAs != StringAsDefault && ft.TypeId != FieldTypeIdString {
+		return fmt.Errorf("option erlang.string_as is only valid " +
+			"for string fields")
+	}
+
+	ft.StringAs = stringAs
+
+	mapRepr, err := ErlMapReprOption(fid.GetOptions())
+	if err != nil {
+		return err
+	}
+
+	ft.MapRepr = mapRepr
+
+	validationRules, err := ValidationRulesFromFieldOptions(fid.GetOptions())
+	if err != nil {
+		return err
+	}
+
+	ft.ValidationRules = validationRules
 
 	*fieldType = ft
 	return nil
 }
 
 func (ft *FieldType) ResolveType(absNameResolver AbsoluteNameResolver) error {
+	if it, found := ft.TypeId.IntegerType(); found {
+		ft.ErlValueTypeSpec = it.ErlTypeSpec(ft.SimpleIntegerSpecs)
+		ft.ErlDefaultValue = "0"
+	}
+
 	switch ft.TypeId {
 	case FieldTypeIdBool:
 		ft.ErlValueTypeSpec = "boolean()"
 		ft.ErlDefaultValue = "false"
 	case FieldTypeIdFloat:
-		ft.ErlValueTypeSpec = "float()"
+		ft.ErlValueTypeSpec = "float() | infinity | '-infinity' | nan"
 		ft.ErlDefaultValue = "0.0"
 	case FieldTypeIdDouble:
-		ft.ErlValueTypeSpec = "float()"
+		ft.ErlValueTypeSpec = "float() | infinity | '-infinity' | nan"
 		ft.ErlDefaultValue = "0.0"
-	case FieldTypeIdInt32:
-		ft.ErlValueTypeSpec = "-2147483648..2147483647"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdInt64:
-		ft.ErlValueTypeSpec = "-9223372036854775808..9223372036854775807"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdUInt32:
-		ft.ErlValueTypeSpec = "0..4294967295"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdUInt64:
-		ft.ErlValueTypeSpec = "0..18446744073709551615"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdSInt32:
-		ft.ErlValueTypeSpec = "-2147483648..2147483647"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdSInt64:
-		ft.ErlValueTypeSpec = "-9223372036854775808..9223372036854775807"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdFixed32:
-		ft.ErlValueTypeSpec = "-2147483648..2147483647"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdFixed64:
-		ft.ErlValueTypeSpec = "-9223372036854775808..9223372036854775807"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdSFixed32:
-		ft.ErlValueTypeSpec = "-2147483648..2147483647"
-		ft.ErlDefaultValue = "0"
-	case FieldTypeIdSFixed64:
-		ft.ErlValueTypeSpec = "-9223372036854775808..9223372036854775807"
-		ft.ErlDefaultValue = "0"
+	case FieldTypeIdInt32, FieldTypeIdInt64, FieldTypeIdUInt32,
+		FieldTypeIdUInt64, FieldTypeIdSInt32, FieldTypeIdSInt64,
+		FieldTypeIdFixed32, FieldTypeIdFixed64, FieldTypeIdSFixed32,
+		FieldTypeIdSFixed64:
+		// See IntegerType
 	case FieldTypeIdString:
-		ft.ErlValueTypeSpec = "iodata()"
-		ft.ErlDefaultValue = "[]"
+		switch ft.StringAs {
+		case StringAsBinary:
+			ft.ErlValueTypeSpec = "binary()"
+			ft.ErlDefaultValue = "<<>>"
+		case StringAsChardata:
+			ft.ErlValueTypeSpec = "unicode:chardata()"
+			ft.ErlDefaultValue = "<<>>"
+		case StringAsList:
+			ft.ErlValueTypeSpec = "string()"
+			ft.ErlDefaultValue = "[]"
+		default:
+			ft.ErlValueTypeSpec = "iodata()"
+			ft.ErlDefaultValue = "[]"
+		}
 	case FieldTypeIdBytes:
-		ft.ErlValueTypeSpec = "iodata()"
-		ft.ErlDefaultValue = "[]"
+		if ft.StringAs == StringAsBinary {
+			ft.ErlValueTypeSpec = "binary()"
+			ft.ErlDefaultValue = "<<>>"
+		} else {
+			ft.ErlValueTypeSpec = "iodata()"
+			ft.ErlDefaultValue = "[]"
+		}
 
 	case FieldTypeIdGroup:
 		return fmt.Errorf("unsupported field type %q", ft.TypeId)
@@ -138,7 +196,10 @@ func (ft *FieldType) ResolveType(absNameResolver AbsoluteNameResolver) error {
 		}
 
 		ft.EnumType = et
-		ft.ErlValueTypeSpec = et.ErlPackage + ":" + et.ErlName + "()"
+
+		// Unknown enum values are represented by their number
+		ft.ErlValueTypeSpec = et.ErlPackage + ":" + et.ErlTypeName +
+			"() | integer()"
 
 		ft.ErlDefaultValue = et.Values[0].ErlName
 
@@ -150,13 +211,23 @@ func (ft *FieldType) ResolveType(absNameResolver AbsoluteNameResolver) error {
 		}
 
 		ft.MessageType = mt
-		ft.ErlValueTypeSpec = mt.ErlPackage + ":" + mt.ErlName + "()"
+		ft.ErlValueTypeSpec = mt.ErlPackage + ":" + mt.ErlTypeName + "()"
 		ft.ErlDefaultValue = "undefined"
 
 	default:
 		return fmt.Errorf("unhandled type %q", string(ft.TypeId))
 	}
 
+	if ft.DefaultValue != "" {
+		value, err := ft.erlDefaultValue()
+		if err != nil {
+			return fmt.Errorf("invalid default value %q: %w",
+				ft.DefaultValue, err)
+		}
+
+		ft.ErlDefaultValue = value
+	}
+
 	ft.ErlTypeSpec = ft.ErlValueTypeSpec
 
 	if ft.Repeated {
@@ -168,5 +239,417 @@ func (ft *FieldType) ResolveType(absNameResolver AbsoluteNameResolver) error {
 		ft.ErlTypeSpec = "undefined | " + ft.ErlTypeSpec
 	}
 
+	if ft.MapRepr == MapReprMap {
+		if !ft.IsMap() {
+			return fmt.Errorf("option erlang.map_repr is only " +
+				"valid for map fields")
+		}
+
+		// String keys are always binaries: other representations are
+		// not suitable for map keys since equal strings can have
+		// different iodata or chardata values.
+		key, value := ft.MessageType.MapEntryFields()
+		if key.TypeId == FieldTypeIdString {
+			key.StringAs = StringAsBinary
+		}
+
+		// The entry message type may not have been resolved yet
+		for _, eft := range []*FieldType{key, value} {
+			if err := eft.ResolveType(absNameResolver); err != nil {
+				return fmt.Errorf("cannot resolve type of map "+
+					"entry field %q: %w", eft.Name, err)
+			}
+		}
+
+		ft.ErlTypeSpec = "#{" + key.ErlValueTypeSpec + " => " +
+			value.ErlValueTypeSpec + "}"
+		ft.ErlDefaultValue = "#{}"
+	}
+
 	return nil
 }
+
+// erlDefaultValue returns the Erlang term of an explicit default value.
+func (ft *FieldType) erlDefaultValue() (string, error) {
+	s := ft.DefaultValue
+
+	switch ft.TypeId {
+	case FieldTypeIdBool:
+		if s != "true" && s != "false" {
+			return "", fmt.Errorf("invalid boolean")
+		}
+
+		return s, nil
+
+	case FieldTypeIdFloat, FieldTypeIdDouble:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("unsupported floating point value")
+		}
+
+		return ErlFloat(f), nil
+
+	case FieldTypeIdString:
+		if ft.StringAs == StringAsList {
+			return ErlString(s), nil
+		}
+
+		return ErlBinaryString(s), nil
+
+	case FieldTypeIdEnum:
+		for _, v := range ft.EnumType.Values {
+			if v.Name == s {
+				return v.ErlName, nil
+			}
+		}
+
+		return "", fmt.Errorf("unknown enum value")
+
+	case FieldTypeIdBytes, FieldTypeIdMessage:
+		return "", fmt.Errorf("unsupported default value type")
+
+	default:
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return "", fmt.Errorf("invalid integer")
+		}
+
+		return i.String(), nil
+	}
+}
+
+// ErlJSONName returns the JSON name of the field as an Erlang binary; it
+// can contain any character since it can be set with the json_name option.
+func (ft *FieldType) ErlJSONName() string {
+	return ErlBinaryString(ft.JSONName)
+}
+
+// ErlReflectionType returns the Erlang term describing the type of the field
+// in generated reflection functions.
+func (ft *FieldType) ErlReflectionType() string {
+	switch ft.TypeId {
+	case FieldTypeIdEnum:
+		return fmt.Sprintf("{enum, %s, %s}",
+			ft.EnumType.ErlPackage, ft.EnumType.ErlName)
+	case FieldTypeIdMessage:
+		return fmt.Sprintf("{message, %s, %s}",
+			ft.MessageType.ErlPackage, ft.MessageType.ErlName)
+	default:
+		return string(ft.TypeId)
+	}
+}
+
+func (ft *FieldType) ErlLabel() string {
+	switch {
+	case ft.Repeated:
+		return "repeated"
+	case ft.Required:
+		return "required"
+	default:
+		return "optional"
+	}
+}
+
+// IsMap returns true if the field is a map field, i.e. a repeated field
+// whose type is a map entry message type.
+func (ft *FieldType) IsMap() bool {
+	return ft.Repeated && ft.MessageType != nil && ft.MessageType.MapEntry
+}
+
+// ErlJSONType returns the Erlang term describing the type of the field for
+// generated JSON functions.
+func (ft *FieldType) ErlJSONType() string {
+	if ft.IsMap() {
+		key, value := ft.MessageType.MapEntryFields()
+
+		if ft.MapRepr == MapReprMap {
+			return fmt.Sprintf("{erl_map, %s, %s}",
+				key.erlJSONValueType(), value.erlJSONValueType())
+		}
+
+		return fmt.Sprintf("{map, %s, %s, %s}", ft.MessageType.ErlName,
+			key.erlJSONValueType(), value.erlJSONValueType())
+	}
+
+	if ft.Repeated {
+		return "{repeated, " + ft.erlJSONValueType() + "}"
+	}
+
+	return ft.erlJSONValueType()
+}
+
+func (ft *FieldType) erlJSONValueType() string {
+	switch ft.TypeId {
+	case FieldTypeIdEnum:
+		et := ft.EnumType
+		return fmt.Sprintf("{enum, fun %s:to_json_%s/1, "+
+			"fun %s:from_json_%s/1, %s}",
+			et.ErlPackage, et.ErlName, et.ErlPackage, et.ErlName,
+			et.Values[0].ErlName)
+	case FieldTypeIdMessage:
+		mt := ft.MessageType
+		return fmt.Sprintf("{message, fun %s:to_json_%s/1, "+
+			"fun %s:from_json_%s/1}",
+			mt.ErlPackage, mt.ErlName, mt.ErlPackage, mt.ErlName)
+	case FieldTypeIdString:
+		if ft.StringAs == StringAsList {
+			return "{string, list}"
+		}
+
+		return "string"
+	default:
+		return string(ft.TypeId)
+	}
+}
+
+// ErlCodecType returns the Erlang term describing the type of the field for
+// generated protobuf encoding and decoding functions.
+func (ft *FieldType) ErlCodecType() string {
+	if ft.MapRepr == MapReprMap {
+		key, value := ft.MessageType.MapEntryFields()
+		return fmt.Sprintf("{map, %s, %s}",
+			key.erlCodecValueType(), value.erlCodecValueType())
+	}
+
+	return ft.erlCodecValueType()
+}
+
+func (ft *FieldType) erlCodecValueType() string {
+	switch ft.TypeId {
+	case FieldTypeIdEnum:
+		et := ft.EnumType
+		return fmt.Sprintf("{enum, fun %s:%s_to_int/1, "+
+			"fun %s:int_to_%s/1}",
+			et.ErlPackage, et.ErlName, et.ErlPackage, et.ErlName)
+	case FieldTypeIdMessage:
+		mt := ft.MessageType
+		return fmt.Sprintf("{message, fun %s:codec_fields_%s/1, "+
+			"fun %s:decoding_fields_%s/0}",
+			mt.ErlPackage, mt.ErlName, mt.ErlPackage, mt.ErlName)
+	case FieldTypeIdString:
+		repr := ft.StringAs
+		if repr == StringAsDefault {
+			repr = StringAsIOData
+		}
+
+		return fmt.Sprintf("{string, %s, %t}", repr, ft.ValidateUTF8)
+	}
+
+	if it, found := ft.TypeId.IntegerType(); found {
+		return it.ErlCodecType()
+	}
+
+	return string(ft.TypeId)
+}
+
+// IsFirstOneofField returns true if the field is the first field of its
+// oneof; encoding functions handle the whole oneof at this position.
+func (ft *FieldType) IsFirstOneofField() bool {
+	return ft.OneofType != nil && ft.OneofType.Fields[0] == ft
+}
+
+// ErlEncodeMode returns the Erlang term indicating how generated encoding
+// functions handle the value of the field.
+func (ft *FieldType) ErlEncodeMode() string {
+	switch {
+	case ft.MapRepr == MapReprMap:
+		return "map"
+	case ft.IsMap():
+		key, _ := ft.MessageType.MapEntryFields()
+		return "{map_entries, " + key.erlCodecValueType() + "}"
+	case ft.Repeated && ft.Packed:
+		return "packed"
+	case ft.Repeated:
+		return "repeated"
+	case ft.Required:
+		return "required"
+	default:
+		return "{optional, " + ft.ErlDefaultValue + "}"
+	}
+}
+
+// ErlDecodeMode returns the Erlang term indicating how generated decoding
+// functions store decoded values in the record of the message. Messages
+// found multiple times for the same singular field are merged.
+func (ft *FieldType) ErlDecodeMode() string {
+	switch {
+	case ft.OneofType != nil && ft.TypeId == FieldTypeIdMessage:
+		return fmt.Sprintf("{oneof, %s, %s}",
+			ft.ErlName, ft.erlMergeFunction())
+	case ft.OneofType != nil:
+		return "{oneof, " + ft.ErlName + "}"
+	case ft.MapRepr == MapReprMap:
+		return "map"
+	case ft.Repeated:
+		return "repeated"
+	case ft.TypeId == FieldTypeIdMessage:
+		return "{merge, " + ft.erlMergeFunction() + "}"
+	default:
+		return "single"
+	}
+}
+
+// ErlMergeType returns the Erlang term indicating how generated merge
+// functions combine values of the field.
+func (ft *FieldType) ErlMergeType() string {
+	switch {
+	case ft.MapRepr == MapReprMap:
+		return "map"
+	case ft.IsMap():
+		return "map_entries"
+	case ft.Repeated:
+		return "repeated"
+	case ft.TypeId == FieldTypeIdMessage:
+		return "{message, " + ft.erlMergeFunction() + "}"
+	case ft.Required:
+		return "replace"
+	default:
+		return fmt.Sprintf("{optional, %s, %s}",
+			ft.erlCodecValueType(), ft.ErlDefaultValue)
+	}
+}
+
+func (ft *FieldType) erlMergeFunction() string {
+	mt := ft.MessageType
+	return fmt.Sprintf("fun %s:merge_%s/2", mt.ErlPackage, mt.ErlName)
+}
+
+// ErlFieldMaskFunctions returns the Erlang term containing the field mask
+// functions of the type of singular message fields, or undefined for fields
+// which cannot have sub-paths.
+func (ft *FieldType) ErlFieldMaskFunctions() string {
+	if ft.TypeId != FieldTypeIdMessage || ft.Repeated {
+		return "undefined"
+	}
+
+	mt := ft.MessageType
+	return fmt.Sprintf("{fun %s:mask_%s/2, fun %s:merge_masked_%s/3, "+
+		"fun %s:validate_mask_%s/1}",
+		mt.ErlPackage, mt.ErlName, mt.ErlPackage, mt.ErlName,
+		mt.ErlPackage, mt.ErlName)
+}
+
+// ErlJSONKeys returns the list of keys accepted for the field in JSON
+// objects: the JSON name and the original field name.
+func (ft *FieldType) ErlJSONKeys() string {
+	if ft.JSONName == ft.Name {
+		return "[" + ft.ErlJSONName() + "]"
+	}
+
+	return "[" + ft.ErlJSONName() + ", " + ErlBinaryString(ft.Name) + "]"
+}
+
+// Validated returns true if generated validation functions must check the
+// field, either because it has validation rules or because it contains
+// messages which may have their own rules.
+func (ft *FieldType) Validated() bool {
+	if ft.ValidationRules != nil {
+		return true
+	}
+
+	if ft.IsMap() {
+		_, value := ft.MessageType.MapEntryFields()
+		return value.TypeId == FieldTypeIdMessage &&
+			value.MessageType.WellKnownType == ""
+	}
+
+	return ft.TypeId == FieldTypeIdMessage &&
+		ft.MessageType.WellKnownType == ""
+}
+
+// ErlValidationType returns the Erlang term describing the type of the field
+// for generated validation functions.
+func (ft *FieldType) ErlValidationType() string {
+	var valueType string
+
+	switch {
+	case ft.IsMap():
+		key, value := ft.MessageType.MapEntryFields()
+		valueType = fmt.Sprintf("{map, %s, %s}",
+			key.erlValidationValueType(), value.erlValidationValueType())
+	case ft.Repeated:
+		valueType = "{repeated, " + ft.erlValidationValueType() + "}"
+	default:
+		valueType = ft.erlValidationValueType()
+	}
+
+	if ft.OneofType != nil {
+		return fmt.Sprintf("{oneof, %s, %s}", ft.ErlName, valueType)
+	}
+
+	return valueType
+}
+
+func (ft *FieldType) erlValidationValueType() string {
+	switch ft.TypeId {
+	case FieldTypeIdBool, FieldTypeIdString, FieldTypeIdBytes:
+		return string(ft.TypeId)
+	case FieldTypeIdEnum:
+		et := ft.EnumType
+		return fmt.Sprintf("{enum, fun %s:%s_to_int/1, fun %s:%s_values/0}",
+			et.ErlPackage, et.ErlName, et.ErlPackage, et.ErlName)
+	case FieldTypeIdMessage:
+		// Well-known types do not have validation functions
+		mt := ft.MessageType
+		if mt.WellKnownType != "" {
+			return "message"
+		}
+
+		return fmt.Sprintf("{message, fun %s:validate_%s/1}",
+			mt.ErlPackage, mt.ErlName)
+	default:
+		return "number"
+	}
+}
+
+// ErlVerifyType returns the Erlang term describing the type specification of
+// the field for generated verification functions.
+func (ft *FieldType) ErlVerifyType() string {
+	switch {
+	case ft.IsMap() && ft.MapRepr == MapReprMap:
+		key, value := ft.MessageType.MapEntryFields()
+		return fmt.Sprintf("{map, %s, %s}",
+			key.erlVerifyValueType(), value.erlVerifyValueType())
+	case ft.Repeated:
+		return "{repeated, " + ft.erlVerifyValueType() + "}"
+	case ft.TypeId == FieldTypeIdMessage:
+		return "{optional, " + ft.erlVerifyValueType() + "}"
+	default:
+		return ft.erlVerifyValueType()
+	}
+}
+
+func (ft *FieldType) erlVerifyValueType() string {
+	switch ft.TypeId {
+	case FieldTypeIdEnum:
+		et := ft.EnumType
+		return fmt.Sprintf("{enum, %s, %s, fun %s:%s_values/0}",
+			et.ErlPackage, et.ErlName, et.ErlPackage, et.ErlName)
+
+	case FieldTypeIdMessage:
+		// Well-known types do not have verification functions
+		mt := ft.MessageType
+		if mt.WellKnownType != "" {
+			return fmt.Sprintf("{record, %s, %s}",
+				mt.ErlPackage, mt.ErlName)
+		}
+
+		return fmt.Sprintf("{message, %s, %s, fun %s:verify_%s/1}",
+			mt.ErlPackage, mt.ErlName, mt.ErlPackage, mt.ErlName)
+
+	case FieldTypeIdFloat, FieldTypeIdDouble:
+		return "float"
+	}
+
+	if it, found := ft.TypeId.IntegerType(); found {
+		return it.ErlVerifyType()
+	}
+
+	spec := ft.ErlValueTypeSpec
+
+	if spec == "unicode:chardata()" {
+		return "chardata"
+	}
+
+	return strings.TrimSuffix(spec, "()")
+}
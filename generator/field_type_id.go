@@ -16,6 +16,8 @@ package generator
 
 import (
 	"errors"
+	"fmt"
+	"math/big"
 
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 )
@@ -87,3 +89,100 @@ func (tid *FieldTypeId) FromProto(v descriptor.FieldDescriptorProto_Type) error
 
 	return nil
 }
+
+// IsPackable returns true if repeated fields of the type can use the packed
+// encoding, i.e. if values are not length-delimited.
+func (tid FieldTypeId) IsPackable() bool {
+	switch tid {
+	case FieldTypeIdString, FieldTypeIdBytes, FieldTypeIdGroup,
+		FieldTypeIdMessage:
+		return false
+	default:
+		return true
+	}
+}
+
+type IntegerEncoding string
+
+const (
+	IntegerEncodingVarint IntegerEncoding = "varint"
+	IntegerEncodingZigZag IntegerEncoding = "zigzag"
+	IntegerEncodingFixed  IntegerEncoding = "fixed"
+)
+
+// IntegerType describes the values and the wire encoding of an integer field
+// type.
+type IntegerType struct {
+	Bits     int
+	Signed   bool
+	Encoding IntegerEncoding
+}
+
+var integerTypes = map[FieldTypeId]IntegerType{
+	FieldTypeIdInt32:    {32, true, IntegerEncodingVarint},
+	FieldTypeIdInt64:    {64, true, IntegerEncodingVarint},
+	FieldTypeIdUInt32:   {32, false, IntegerEncodingVarint},
+	FieldTypeIdUInt64:   {64, false, IntegerEncodingVarint},
+	FieldTypeIdSInt32:   {32, true, IntegerEncodingZigZag},
+	FieldTypeIdSInt64:   {64, true, IntegerEncodingZigZag},
+	FieldTypeIdFixed32:  {32, false, IntegerEncodingFixed},
+	FieldTypeIdFixed64:  {64, false, IntegerEncodingFixed},
+	FieldTypeIdSFixed32: {32, true, IntegerEncodingFixed},
+	FieldTypeIdSFixed64: {64, true, IntegerEncodingFixed},
+}
+
+// IntegerType returns the description of the type if it is an integer type.
+func (tid FieldTypeId) IntegerType() (IntegerType, bool) {
+	it, found := integerTypes[tid]
+	return it, found
+}
+
+func (it IntegerType) Min() *big.Int {
+	if !it.Signed {
+		return new(big.Int)
+	}
+
+	min := new(big.Int).Lsh(big.NewInt(1), uint(it.Bits-1))
+	return min.Neg(min)
+}
+
+func (it IntegerType) Max() *big.Int {
+	bits := it.Bits
+	if it.Signed {
+		bits--
+	}
+
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	return max.Sub(max, big.NewInt(1))
+}
+
+// ErlTypeSpec returns the Erlang type specification of the values of the
+// type, either as an exact range or as a simpler builtin type.
+func (it IntegerType) ErlTypeSpec(simple bool) string {
+	switch {
+	case simple && it.Signed:
+		return "integer()"
+	case simple:
+		return "non_neg_integer()"
+	default:
+		return it.Min().String() + ".." + it.Max().String()
+	}
+}
+
+// ErlCodecType returns the Erlang term describing the type for generated
+// protobuf encoding and decoding functions.
+func (it IntegerType) ErlCodecType() string {
+	signedness := "unsigned"
+	if it.Signed {
+		signedness = "signed"
+	}
+
+	return fmt.Sprintf("{integer, %s, %d, %s}",
+		it.Encoding, it.Bits, signedness)
+}
+
+// ErlVerifyType returns the Erlang term describing the type for generated
+// verification functions; it always uses the exact range of the type.
+func (it IntegerType) ErlVerifyType() string {
+	return fmt.Sprintf("{integer, %s, %s}", it.Min(), it.Max())
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"testing"
+)
+
+func TestFieldTypeIdIntegerType(t *testing.T) {
+	tests := []struct {
+		tid        FieldTypeId
+		min        string
+		max        string
+		spec       string
+		simpleSpec string
+		codecType  string
+	}{
+		{FieldTypeIdInt32, "-2147483648", "2147483647",
+			"-2147483648..2147483647", "integer()",
+			"{integer, varint, 32, signed}"},
+		{FieldTypeIdInt64, "-9223372036854775808", "9223372036854775807",
+			"-9223372036854775808..9223372036854775807", "integer()",
+			"{integer, varint, 64, signed}"},
+		{FieldTypeIdUInt32, "0", "4294967295",
+			"0..4294967295", "non_neg_integer()",
+			"{integer, varint, 32, unsigned}"},
+		{FieldTypeIdUInt64, "0", "18446744073709551615",
+			"0..18446744073709551615", "non_neg_integer()",
+			"{integer, varint, 64, unsigned}"},
+		{FieldTypeIdSInt32, "-2147483648", "2147483647",
+			"-2147483648..2147483647", "integer()",
+			"{integer, zigzag, 32, signed}"},
+		{FieldTypeIdSInt64, "-9223372036854775808", "9223372036854775807",
+			"-9223372036854775808..9223372036854775807", "integer()",
+			"{integer, zigzag, 64, signed}"},
+		{FieldTypeIdFixed32, "0", "4294967295",
+			"0..4294967295", "non_neg_integer()",
+			"{integer, fixed, 32, unsigned}"},
+		{FieldTypeIdFixed64, "0", "18446744073709551615",
+			"0..18446744073709551615", "non_neg_integer()",
+			"{integer, fixed, 64, unsigned}"},
+		{FieldTypeIdSFixed32, "-2147483648", "2147483647",
+			"-2147483648..2147483647", "integer()",
+			"{integer, fixed, 32, signed}"},
+		{FieldTypeIdSFixed64, "-9223372036854775808", "9223372036854775807",
+			"-9223372036854775808..9223372036854775807", "integer()",
+			"{integer, fixed, 64, signed}"},
+	}
+
+	for _, test := range tests {
+		it, found := test.tid.IntegerType()
+		if !found {
+			t.Errorf("%s: integer type not found", test.tid)
+			continue
+		}
+
+		if min := it.Min().String(); min != test.min {
+			t.Errorf("%s: min is %s but should be %s", test.tid, min, test.min)
+		}
+
+		if max := it.Max().String(); max != test.max {
+			t.Errorf("%s: max is %s but should be %s", test.tid, max, test.max)
+		}
+
+		if spec := it.ErlTypeSpec(false); spec != test.spec {
+			t.Errorf("%s: type spec is %s but should be %s",
+				test.tid, spec, test.spec)
+		}
+
+		if spec := it.ErlTypeSpec(true); spec != test.simpleSpec {
+			t.Errorf("%s: simple type spec is %s but should be %s",
+				test.tid, spec, test.simpleSpec)
+		}
+
+		if codecType := it.ErlCodecType(); codecType != test.codecType {
+			t.Errorf("%s: codec type is %s but should be %s",
+				test.tid, codecType, test.codecType)
+		}
+
+		verifyType := "{integer, " + test.min + ", " + test.max + "}"
+		if vt := it.ErlVerifyType(); vt != verifyType {
+			t.Errorf("%s: verify type is %s but should be %s",
+				test.tid, vt, verifyType)
+		}
+	}
+
+	nonIntegers := []FieldTypeId{
+		FieldTypeIdBool, FieldTypeIdFloat, FieldTypeIdDouble,
+		FieldTypeIdString, FieldTypeIdBytes, FieldTypeIdEnum,
+		FieldTypeIdMessage,
+	}
+
+	for _, tid := range nonIntegers {
+		if _, found := tid.IntegerType(); found {
+			t.Errorf("%s: found integer type for non-integer type", tid)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+type FileDescriptor struct {
+	Name string
+	Data []byte // serialized FileDescriptorProto
+
+	ErlName         string // binary string
+	ErlData         string // binary literal
+	ErlSetEntryData string // tag and length prefix in a FileDescriptorSet
+}
+
+type FileDescriptors []*FileDescriptor
+
+func (fileDescriptor *FileDescriptor) FromDescriptor(fd *descriptor.FileDescriptorProto) error {
+	// Source code information is only useful to code generators and
+	// would significantly increase the size of generated modules.
+	fd2 := proto.Clone(fd).(*descriptor.FileDescriptorProto)
+	fd2.SourceCodeInfo = nil
+
+	data, err := proto.Marshal(fd2)
+	if err != nil {
+		return err
+	}
+
+	f := FileDescriptor{
+		Name: fd.GetName(),
+		Data: data,
+	}
+
+	// FileDescriptorSet.file is field 1 with wire type 2
+	entryData := append([]byte{0x0a}, proto.EncodeVarint(uint64(len(data)))...)
+
+	f.ErlName = ErlBinaryString(f.Name)
+	f.ErlData = ErlBinaryLiteral(data, "    ")
+	f.ErlSetEntryData = ErlBinaryLiteral(entryData, "")
+
+	*fileDescriptor = f
+	return nil
+}
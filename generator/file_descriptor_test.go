@@ -0,0 +1,68 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestFileDescriptorFromDescriptor(t *testing.T) {
+	fd := &descriptor.FileDescriptorProto{
+		Name:    proto.String(`dir/"quoted"\file.proto`),
+		Package: proto.String(strings.Repeat("a", 200)),
+		SourceCodeInfo: &descriptor.SourceCodeInfo{
+			Location: []*descriptor.SourceCodeInfo_Location{
+				{Path: []int32{4, 0}, LeadingComments: proto.String("x")},
+			},
+		},
+	}
+
+	var f FileDescriptor
+	if err := f.FromDescriptor(fd); err != nil {
+		t.Fatalf("cannot create file descriptor: %v", err)
+	}
+
+	if fd.SourceCodeInfo == nil {
+		t.Errorf("source code information removed from the original " +
+			"descriptor")
+	}
+
+	var fd2 descriptor.FileDescriptorProto
+	if err := proto.Unmarshal(f.Data, &fd2); err != nil {
+		t.Fatalf("cannot decode descriptor data: %v", err)
+	}
+
+	if fd2.SourceCodeInfo != nil {
+		t.Errorf("source code information not removed")
+	}
+
+	if fd2.GetName() != fd.GetName() || fd2.GetPackage() != fd.GetPackage() {
+		t.Errorf("decoded descriptor %v does not match %v", &fd2, fd)
+	}
+
+	// Name: 2 + 23 bytes, package: 3 + 200 bytes
+	if expected := "<<10,228,1>>"; f.ErlSetEntryData != expected {
+		t.Errorf("set entry data is %s but should be %s",
+			f.ErlSetEntryData, expected)
+	}
+
+	if expected := `<<"dir/\"quoted\"\\file.proto">>`; f.ErlName != expected {
+		t.Errorf("name is %s but should be %s", f.ErlName, expected)
+	}
+}
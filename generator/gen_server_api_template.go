@@ -0,0 +1,126 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlGenServerAPITemplateContent = `
+{{- define "erl_input" }}{{ .InputType.ErlPackage }}:{{ .InputType.ErlTypeName }}(){{ end }}
+{{- define "erl_output" }}{{ .OutputType.ErlPackage }}:{{ .OutputType.ErlTypeName }}(){{ end }}
+
+%%% Generated from protobuf service {{ .FullName }}.
+%%% DO NOT EDIT.
+
+%%% Client functions for servers started with {{ .ErlGenServerModuleName }}.
+%%%
+%%% Options:
+%%% - timeout: the call timeout (default: 5000);
+%%% - node: if set, the call is executed on this node with erpc;
+%%% - encoding: either record (default) to send records as Erlang terms, or
+%%%   wire to encode messages with the protobuf wire format, so that nodes
+%%%   running different versions of the schema can communicate.
+{{- with .Comment }}{{ .ErlModuleComment }}{{ end }}
+
+-module({{ .ErlGenServerAPIModuleName }}).
+{{- with .Comment }}{{ .ErlModuleDoc }}{{ end }}
+
+-export([
+  {{- range $i, $m := .UnaryMethods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ $m.ErlName }}/2,
+  {{ $m.ErlName }}/3
+  {{- end }}
+]).
+{{- if .DeprecatedUnaryMethods }}
+
+-deprecated([
+  {{- range $i, $m := .DeprecatedUnaryMethods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ "{" }}{{ $m.ErlName }}, '_'}
+  {{- end }}
+]).
+{{- end }}
+
+-export_type([call_options/0]).
+
+-type call_options() :: #{timeout => timeout(),
+                          node => node(),
+                          encoding => record | wire}.
+{{ range .UnaryMethods }}
+%% Generated for method {{ .Name }}.
+{{ with .Comment }}{{ .ErlDoc }}
+{{ end }}-spec {{ .ErlName }}(gen_server:server_ref(), {{ template "erl_input" . }}) ->
+        {ok, {{ template "erl_output" . }}} | {error, term()}.
+{{ .ErlName }}(Server, Request) ->
+  {{ .ErlName }}(Server, Request, #{}).
+
+-spec {{ .ErlName }}(gen_server:server_ref(), {{ template "erl_input" . }},
+          call_options()) ->
+        {ok, {{ template "erl_output" . }}} | {error, term()}.
+{{ .ErlName }}(Server, Request, Options) ->
+  call(Server, {{ .ErlName }}, Request, Options,
+       fun {{ .InputType.ErlPackage }}:encode_{{ .InputType.ErlName }}/1,
+       fun {{ .OutputType.ErlPackage }}:decode_{{ .OutputType.ErlName }}/2).
+{{ end }}
+{{- if .UnaryMethods }}
+-spec call(gen_server:server_ref(), atom(), tuple(), call_options(),
+           fun((tuple()) -> iodata()),
+           fun((binary(), list()) ->
+                   {ok, tuple()} | {error, term()})) ->
+        {ok, tuple()} | {error, term()}.
+call(Server, Method, Request, Options, Encode, Decode) ->
+  Payload = case maps:get(encoding, Options, record) of
+              record ->
+                {record, Request};
+              wire ->
+                {wire, iolist_to_binary(Encode(Request))}
+            end,
+  Timeout = maps:get(timeout, Options, 5000),
+  Msg = {'$rpc', Method, Payload},
+  Result = case maps:find(node, Options) of
+             {ok, Node} ->
+               erpc:call(Node, gen_server, call, [Server, Msg, Timeout],
+                         Timeout);
+             error ->
+               gen_server:call(Server, Msg, Timeout)
+           end,
+  case Result of
+    {ok, {record, Response}} ->
+      {ok, Response};
+    {ok, {wire, Data}} ->
+      case Decode(Data, []) of
+        {ok, Response} ->
+          {ok, Response};
+        {error, Reason} ->
+          {error, {invalid_payload, Reason}}
+      end;
+    {error, Reason} ->
+      {error, Reason}
+  end.
+{{- end }}
+`
+
+func ErlGenServerAPITemplate() (*template.Template, error) {
+	tpl := template.New("erl_gen_server_api")
+
+	if _, err := tpl.Parse(erlGenServerAPITemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlGenServerTemplateContent = `
+{{- define "erl_input" }}{{ .InputType.ErlPackage }}:{{ .InputType.ErlTypeName }}(){{ end }}
+{{- define "erl_output" }}{{ .OutputType.ErlPackage }}:{{ .OutputType.ErlTypeName }}(){{ end }}
+
+%%% Generated from protobuf service {{ .FullName }}.
+%%% DO NOT EDIT.
+
+%%% A gen_server executing calls sent by {{ .ErlGenServerAPIModuleName }}
+%%% with an implementation module providing the callbacks of this module.
+%%% Requests and responses are either passed as records or encoded with the
+%%% protobuf wire format, depending on the encoding chosen by the caller.
+{{- with .Comment }}{{ .ErlModuleComment }}{{ end }}
+
+-module({{ .ErlGenServerModuleName }}).
+{{- with .Comment }}{{ .ErlModuleDoc }}{{ end }}
+
+-behaviour(gen_server).
+
+-export([start_link/2,
+         start_link/3]).
+
+-export([init/1,
+         terminate/2,
+         handle_call/3,
+         handle_cast/2,
+         handle_info/2]).
+
+-export_type([payload/0]).
+{{- if .DeprecatedUnaryMethods }}
+
+-deprecated_callback([
+  {{- range $i, $m := .DeprecatedUnaryMethods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ "{" }}{{ $m.ErlName }}, 2}
+  {{- end }}
+]).
+{{- end }}
+
+-type payload() :: {record, tuple()} | {wire, binary()}.
+{{- if .UnaryMethods }}
+
+-type decode_fun() :: fun((binary(), list()) ->
+                             {ok, tuple()} | {error, term()}).
+{{- end }}
+
+-callback init(Args :: term()) -> {ok, State :: term()} | {stop, term()}.
+{{ range .UnaryMethods }}
+%% Generated for method {{ .Name }}.
+{{ with .Comment }}{{ .ErlDoc }}
+{{ end }}-callback {{ .ErlName }}({{ template "erl_input" . }}, State :: term()) ->
+  {reply, {{ template "erl_output" . }}, State :: term()}
+  | {error, Reason :: term(), State :: term()}.
+{{ end }}
+-spec start_link(module(), term()) -> {ok, pid()} | {error, term()}.
+start_link(Impl, Args) ->
+  gen_server:start_link(?MODULE, {Impl, Args}, []).
+
+-spec start_link(gen_server:server_name(), module(), term()) ->
+        {ok, pid()} | {error, term()}.
+start_link(Name, Impl, Args) ->
+  gen_server:start_link(Name, ?MODULE, {Impl, Args}, []).
+
+init({Impl, Args}) ->
+  case Impl:init(Args) of
+    {ok, ImplState} ->
+      {ok, #{impl => Impl, impl_state => ImplState}};
+    {stop, Reason} ->
+      {stop, Reason}
+  end.
+
+terminate(_Reason, _State) ->
+  ok.
+{{ range .UnaryMethods }}
+handle_call({'$rpc', {{ .ErlName }}, Payload}, _From, State) ->
+  Decode = fun {{ .InputType.ErlPackage }}:decode_{{ .InputType.ErlName }}/2,
+  Encode = fun {{ .OutputType.ErlPackage }}:encode_{{ .OutputType.ErlName }}/1,
+  call({{ .ErlName }}, Payload, Decode, Encode, State);
+{{- end }}
+handle_call({'$rpc', Method, _}, _From, State) ->
+  {reply, {error, {unknown_method, Method}}, State};
+handle_call(Msg, From, State) ->
+  logger:warning("unhandled call ~p from ~p", [Msg, From]),
+  {reply, {error, unhandled_call}, State}.
+
+handle_cast(Msg, State) ->
+  logger:warning("unhandled cast ~p", [Msg]),
+  {noreply, State}.
+
+handle_info(Msg, State) ->
+  logger:warning("unhandled info ~p", [Msg]),
+  {noreply, State}.
+{{- if .UnaryMethods }}
+
+%% Invalid request payloads are reported to the caller without calling the
+%% implementation module, so that they cannot crash the server.
+-spec call(atom(), payload(), decode_fun(), fun((tuple()) -> iodata()),
+           map()) ->
+        {reply, {ok, payload()} | {error, term()}, map()}.
+call(Method, RequestPayload, Decode, Encode,
+     State = #{impl := Impl, impl_state := ImplState}) ->
+  case decode_payload(RequestPayload, Decode) of
+    {ok, Request} ->
+      case Impl:Method(Request, ImplState) of
+        {reply, Response, ImplState2} ->
+          ResponsePayload = encode_payload(Response, RequestPayload, Encode),
+          {reply, {ok, ResponsePayload}, State#{impl_state => ImplState2}};
+        {error, Reason, ImplState2} ->
+          {reply, {error, Reason}, State#{impl_state => ImplState2}}
+      end;
+    {error, Reason} ->
+      {reply, {error, {invalid_payload, Reason}}, State}
+  end.
+
+-spec decode_payload(payload(), decode_fun()) ->
+        {ok, tuple()} | {error, term()}.
+decode_payload({record, Message}, _) when is_tuple(Message) ->
+  {ok, Message};
+decode_payload({wire, Data}, Decode) when is_binary(Data) ->
+  Decode(Data, []);
+decode_payload(_, _) ->
+  {error, invalid_format}.
+
+%% Responses use the same encoding as requests.
+-spec encode_payload(tuple(), payload(), fun((tuple()) -> iodata())) ->
+        payload().
+encode_payload(Message, {record, _}, _) ->
+  {record, Message};
+encode_payload(Message, {wire, _}, Encode) ->
+  {wire, iolist_to_binary(Encode(Message))}.
+{{- end }}
+`
+
+func ErlGenServerTemplate() (*template.Template, error) {
+	tpl := template.New("erl_gen_server")
+
+	if _, err := tpl.Parse(erlGenServerTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
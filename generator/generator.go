@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"text/template"
 
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
@@ -31,11 +33,77 @@ type AbsoluteNameResolver interface {
 	FindEnumType(string) *EnumType
 }
 
+// The functions exported by the generated module, grouped by feature. Each
+// group is exported by a separate attribute.
+type ErlExports struct {
+	Enums        ErlFunctions
+	Messages     ErlFunctions
+	Accessors    ErlFunctions
+	FieldMasks   ErlFunctions
+	JSONEnums    ErlFunctions
+	JSONMessages ErlFunctions
+	Validation   ErlFunctions
+	Verification ErlFunctions
+}
+
+// All returns all exported functions.
+func (exports *ErlExports) All() ErlFunctions {
+	groups := []ErlFunctions{
+		exports.Enums, exports.Messages, exports.Accessors,
+		exports.FieldMasks, exports.JSONEnums, exports.JSONMessages,
+		exports.Validation, exports.Verification,
+	}
+
+	var fns ErlFunctions
+
+	for _, group := range groups {
+		fns = append(fns, group...)
+	}
+
+	return fns
+}
+
+// Deprecated returns the exported functions generated for deprecated
+// elements.
+func (exports *ErlExports) Deprecated() ErlFunctions {
+	var fns ErlFunctions
+
+	for _, fn := range exports.All() {
+		if fn.Deprecated {
+			fns = append(fns, fn)
+		}
+	}
+
+	return fns
+}
+
+// CheckConflicts returns an error if different elements, e.g. fields of
+// messages whose names share a prefix, yield functions with the same name
+// and arity.
+func (exports *ErlExports) CheckConflicts() error {
+	nameToFunction := make(map[string]*ErlFunction)
+
+	for _, fn := range exports.All() {
+		key := fn.Name + "/" + strconv.Itoa(fn.Arity)
+
+		if fn2, found := nameToFunction[key]; found {
+			return fmt.Errorf("function %s generated for %s conflicts "+
+				"with function generated for %s",
+				key, fn.Source, fn2.Source)
+		}
+
+		nameToFunction[key] = fn
+	}
+
+	return nil
+}
+
 type Generator struct {
 	Request  *plugin.CodeGeneratorRequest
 	Response *plugin.CodeGeneratorResponse
 
 	Verbose bool
+	Options Options
 
 	InputFileDescriptors []*descriptor.FileDescriptorProto
 
@@ -51,12 +119,29 @@ type Generator struct {
 	PackageEnumTypes       EnumTypes
 	AbsoluteNameToEnumType map[string]*EnumType
 
+	ServiceTypes ServiceTypes
+
 	ErlModuleName string
 	ErlHRLPath    string
 	ErlModulePath string
 
-	erlHRLTemplate    *template.Template
-	erlModuleTemplate *template.Template
+	ErlMacros ErlMacros
+
+	ErlExports ErlExports
+
+	FileDescriptors FileDescriptors
+
+	erlHRLTemplate           *template.Template
+	erlModuleTemplate        *template.Template
+	erlGRPCBehaviourTemplate *template.Template
+	erlGRPCDispatchTemplate  *template.Template
+	erlGRPCClientTemplate    *template.Template
+	erlGRPCTransportTemplate *template.Template
+	erlGenServerTemplate     *template.Template
+	erlGenServerAPITemplate  *template.Template
+	erlTwirpHandlerTemplate  *template.Template
+	erlTwirpClientTemplate   *template.Template
+	erlHTTPHandlerTemplate   *template.Template
 }
 
 func NewGenerator(req *plugin.CodeGeneratorRequest) (*Generator, error) {
@@ -67,6 +152,10 @@ func NewGenerator(req *plugin.CodeGeneratorRequest) (*Generator, error) {
 		Verbose: true,
 	}
 
+	if err := g.Options.Parse(req.GetParameter()); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
 	erlHRLTemplate, err := ErlHRLTemplate()
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -81,6 +170,69 @@ func NewGenerator(req *plugin.CodeGeneratorRequest) (*Generator, error) {
 	}
 	g.erlModuleTemplate = erlModuleTemplate
 
+	erlGRPCBehaviourTemplate, err := ErlGRPCBehaviourTemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang grpc behaviour template: %w", err)
+	}
+	g.erlGRPCBehaviourTemplate = erlGRPCBehaviourTemplate
+
+	erlGRPCDispatchTemplate, err := ErlGRPCDispatchTemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang grpc dispatch template: %w", err)
+	}
+	g.erlGRPCDispatchTemplate = erlGRPCDispatchTemplate
+
+	erlGRPCClientTemplate, err := ErlGRPCClientTemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang grpc client template: %w", err)
+	}
+	g.erlGRPCClientTemplate = erlGRPCClientTemplate
+
+	erlGRPCTransportTemplate, err := ErlGRPCTransportTemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang grpc transport template: %w", err)
+	}
+	g.erlGRPCTransportTemplate = erlGRPCTransportTemplate
+
+	erlGenServerTemplate, err := ErlGenServerTemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang gen_server template: %w", err)
+	}
+	g.erlGenServerTemplate = erlGenServerTemplate
+
+	erlGenServerAPITemplate, err := ErlGenServerAPITemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang gen_server api template: %w", err)
+	}
+	g.erlGenServerAPITemplate = erlGenServerAPITemplate
+
+	erlTwirpHandlerTemplate, err := ErlTwirpHandlerTemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang twirp handler template: %w", err)
+	}
+	g.erlTwirpHandlerTemplate = erlTwirpHandlerTemplate
+
+	erlTwirpClientTemplate, err := ErlTwirpClientTemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang twirp client template: %w", err)
+	}
+	g.erlTwirpClientTemplate = erlTwirpClientTemplate
+
+	erlHTTPHandlerTemplate, err := ErlHTTPHandlerTemplate()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"cannot create erlang http handler template: %w", err)
+	}
+	g.erlHTTPHandlerTemplate = erlHTTPHandlerTemplate
+
 	return &g, nil
 }
 
@@ -97,8 +249,6 @@ func (g *Generator) GenerateOutput() error {
 		return err
 	}
 
-	g.ErlModuleName = ProtoPackageNameToErlModuleName(g.PackageName)
-
 	g.ErlHRLPath = path.Join(g.PackageDirectory, g.ErlModuleName+".hrl")
 	g.ErlModulePath = path.Join(g.PackageDirectory, g.ErlModuleName+".erl")
 
@@ -114,6 +264,157 @@ func (g *Generator) GenerateOutput() error {
 		return fmt.Errorf("cannot generate erlang module: %w", err)
 	}
 
+	if g.Options.GRPC && len(g.ServiceTypes) > 0 {
+		transportPath := path.Join(g.PackageDirectory,
+			ErlGRPCTransportModuleName(g.ErlModuleName)+".erl")
+
+		err = g.generateFile(transportPath,
+			g.erlGRPCTransportTemplate, g)
+		if err != nil {
+			return fmt.Errorf("cannot generate grpc transport "+
+				"module: %w", err)
+		}
+
+		for _, st := range g.ServiceTypes {
+			if err := g.generateGRPCFiles(st); err != nil {
+				return fmt.Errorf("cannot generate grpc modules "+
+					"for service %s: %w", st.Name, err)
+			}
+		}
+	}
+
+	if g.Options.GenServer {
+		for _, st := range g.ServiceTypes {
+			if err := g.generateGenServerFiles(st); err != nil {
+				return fmt.Errorf("cannot generate gen_server "+
+					"modules for service %s: %w", st.Name, err)
+			}
+		}
+	}
+
+	if g.Options.Twirp {
+		for _, st := range g.ServiceTypes {
+			if err := g.generateTwirpFiles(st); err != nil {
+				return fmt.Errorf("cannot generate twirp "+
+					"modules for service %s: %w", st.Name, err)
+			}
+		}
+	}
+
+	if g.Options.HTTP {
+		for _, st := range g.ServiceTypes {
+			if err := g.generateHTTPFiles(st); err != nil {
+				return fmt.Errorf("cannot generate http "+
+					"modules for service %s: %w", st.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (g *Generator) generateHTTPFiles(st *ServiceType) error {
+	for _, m := range st.Methods {
+		if !m.Unary() && len(m.HTTPRules) > 0 {
+			g.Info("ignoring http rules of streaming method %s of "+
+				"service %s", m.Name, st.FullName)
+		}
+	}
+
+	if len(st.HTTPMethods()) == 0 {
+		return nil
+	}
+
+	handlerPath := path.Join(g.PackageDirectory,
+		st.ErlHTTPHandlerModuleName+".erl")
+
+	err := g.generateFile(handlerPath, g.erlHTTPHandlerTemplate, st)
+	if err != nil {
+		return fmt.Errorf("cannot generate handler module: %w", err)
+	}
+
+	return nil
+}
+
+func (g *Generator) generateTwirpFiles(st *ServiceType) error {
+	for _, m := range st.Methods {
+		if !m.Unary() {
+			g.Info("ignoring streaming method %s of service %s "+
+				"for twirp modules", m.Name, st.FullName)
+		}
+	}
+
+	handlerPath := path.Join(g.PackageDirectory,
+		st.ErlTwirpHandlerModuleName+".erl")
+
+	err := g.generateFile(handlerPath, g.erlTwirpHandlerTemplate, st)
+	if err != nil {
+		return fmt.Errorf("cannot generate handler module: %w", err)
+	}
+
+	clientPath := path.Join(g.PackageDirectory,
+		st.ErlTwirpClientModuleName+".erl")
+
+	err = g.generateFile(clientPath, g.erlTwirpClientTemplate, st)
+	if err != nil {
+		return fmt.Errorf("cannot generate client module: %w", err)
+	}
+
+	return nil
+}
+
+func (g *Generator) generateGenServerFiles(st *ServiceType) error {
+	for _, m := range st.Methods {
+		if !m.Unary() {
+			g.Info("ignoring streaming method %s of service %s "+
+				"for gen_server modules", m.Name, st.FullName)
+		}
+	}
+
+	serverPath := path.Join(g.PackageDirectory,
+		st.ErlGenServerModuleName+".erl")
+
+	err := g.generateFile(serverPath, g.erlGenServerTemplate, st)
+	if err != nil {
+		return fmt.Errorf("cannot generate server module: %w", err)
+	}
+
+	apiPath := path.Join(g.PackageDirectory,
+		st.ErlGenServerAPIModuleName+".erl")
+
+	err = g.generateFile(apiPath, g.erlGenServerAPITemplate, st)
+	if err != nil {
+		return fmt.Errorf("cannot generate api module: %w", err)
+	}
+
+	return nil
+}
+
+func (g *Generator) generateGRPCFiles(st *ServiceType) error {
+	behaviourPath := path.Join(g.PackageDirectory,
+		st.ErlBehaviourModuleName+".erl")
+
+	err := g.generateFile(behaviourPath, g.erlGRPCBehaviourTemplate, st)
+	if err != nil {
+		return fmt.Errorf("cannot generate behaviour module: %w", err)
+	}
+
+	dispatchPath := path.Join(g.PackageDirectory,
+		st.ErlDispatchModuleName+".erl")
+
+	err = g.generateFile(dispatchPath, g.erlGRPCDispatchTemplate, st)
+	if err != nil {
+		return fmt.Errorf("cannot generate dispatch module: %w", err)
+	}
+
+	clientPath := path.Join(g.PackageDirectory,
+		st.ErlClientModuleName+".erl")
+
+	err = g.generateFile(clientPath, g.erlGRPCClientTemplate, st)
+	if err != nil {
+		return fmt.Errorf("cannot generate client module: %w", err)
+	}
+
 	return nil
 }
 
@@ -140,9 +441,18 @@ func (g *Generator) collectData() error {
 		g.collectInputFileDescriptors,
 		g.collectPackageName,
 		g.collectPackageDirectory,
+		g.collectErlModuleName,
 		g.collectMessageTypes,
 		g.collectEnumTypes,
+		g.collectServiceTypes,
+		g.collectComments,
 		g.resolveTypes,
+		g.checkValidationRules,
+		g.checkJSONTypes,
+		g.checkErlTypes,
+		g.collectErlMacros,
+		g.collectErlExports,
+		g.collectFileDescriptors,
 	}
 
 	for _, fn := range fns {
@@ -203,6 +513,32 @@ func (g *Generator) collectPackageDirectory() error {
 	return nil
 }
 
+// The erlang.module option applies to a whole package, so all the files of
+// a package must agree on its value. We check every file since the module
+// name is also used to refer to types of imported packages.
+func (g *Generator) collectErlModuleName() error {
+	moduleNames := make(map[string]string)
+
+	for _, fd := range g.Request.ProtoFile {
+		name, err := ErlFileModuleName(fd)
+		if err != nil {
+			return fmt.Errorf("invalid options in file %s: %w",
+				fd.GetName(), err)
+		}
+
+		pkg := fd.GetPackage()
+		if pkgName, found := moduleNames[pkg]; found && pkgName != name {
+			return fmt.Errorf("conflicting erlang module names "+
+				"%q and %q for package %s", pkgName, name, pkg)
+		}
+
+		moduleNames[pkg] = name
+	}
+
+	g.ErlModuleName = moduleNames[g.PackageName]
+	return nil
+}
+
 func (g *Generator) collectMessageTypes() error {
 	var mts MessageTypes
 
@@ -218,6 +554,23 @@ func (g *Generator) collectMessageTypes() error {
 				d.GetName(), fd.GetPackage(), err)
 		}
 
+		mt.VerifyOnEncode = g.Options.VerifyEncode
+
+		for _, ft := range mt.Fields {
+			ft.SimpleIntegerSpecs = g.Options.SimpleIntegerSpecs
+
+			if ft.StringAs != StringAsDefault {
+				continue
+			}
+
+			switch ft.TypeId {
+			case FieldTypeIdString:
+				ft.StringAs = g.Options.StringRepr
+			case FieldTypeIdBytes:
+				ft.StringAs = g.Options.BytesRepr
+			}
+		}
+
 		mts = append(mts, &mt)
 
 		descriptorToMessageType[d] = &mt
@@ -267,6 +620,12 @@ func (g *Generator) collectEnumTypes() error {
 				ed.GetName(), fd.GetPackage(), err)
 		}
 
+		if g.Options.StripEnumPrefix && !et.StripValuePrefixes() {
+			g.Info("cannot strip value prefixes of enum %s "+
+				"in package %s without creating invalid or "+
+				"duplicate atoms", et.FullName, et.Package)
+		}
+
 		ets = append(ets, &et)
 
 		absoluteNameToEnumType[et.AbsoluteName] = &et
@@ -325,6 +684,150 @@ func (g *Generator) collectEnumTypes() error {
 	return nil
 }
 
+func (g *Generator) collectServiceTypes() error {
+	var sts ServiceTypes
+
+	for _, fd := range g.InputFileDescriptors {
+		for _, sd := range fd.Service {
+			var st ServiceType
+			if err := st.FromDescriptor(fd, sd); err != nil {
+				return fmt.Errorf("cannot create type for "+
+					"service %s in package %s: %w",
+					sd.GetName(), fd.GetPackage(), err)
+			}
+
+			sts = append(sts, &st)
+		}
+	}
+
+	g.ServiceTypes = sts
+	return nil
+}
+
+func (g *Generator) collectComments() error {
+	format := g.Options.DocFormat
+
+	var addMessageComments func(Comments, *descriptor.DescriptorProto, []int32)
+	var addEnumComments func(Comments, *descriptor.EnumDescriptorProto, string, []int32)
+
+	addMessageComments = func(comments Comments, d *descriptor.DescriptorProto, path []int32) {
+		mt := g.DescriptorToMessageType[d]
+		mt.Comment = comments.Find(path...).
+			MarkDeprecated(format, mt.Deprecated)
+
+		for i, fid := range d.Field {
+			fieldPath := append(path, MessageDescriptorFieldPath, int32(i))
+			if ft := mt.FindField(fid.GetName()); ft != nil {
+				ft.Comment = comments.Find(fieldPath...).
+					MarkDeprecated(format, ft.Deprecated)
+			}
+		}
+
+		for i, nd := range d.NestedType {
+			nestedPath := append(path, MessageDescriptorNestedTypePath,
+				int32(i))
+			addMessageComments(comments, nd, nestedPath)
+		}
+
+		for i, ed := range d.EnumType {
+			enumPath := append(path, MessageDescriptorEnumTypePath,
+				int32(i))
+			addEnumComments(comments, ed,
+				mt.AbsoluteName+"."+ed.GetName(), enumPath)
+		}
+	}
+
+	addEnumComments = func(comments Comments, ed *descriptor.EnumDescriptorProto, absName string, path []int32) {
+		et := g.FindEnumType(absName)
+		if et == nil {
+			return
+		}
+
+		// Enum values cannot be documented individually, so their
+		// comments are added to the documentation of the enum type.
+		var valueLines []string
+
+		for i, evd := range ed.Value {
+			valuePath := append(path, EnumDescriptorValuePath, int32(i))
+
+			for _, v := range et.Values {
+				if v.Name != evd.GetName() {
+					continue
+				}
+
+				v.Comment = comments.Find(valuePath...).
+					MarkDeprecated(format, v.Deprecated)
+				if v.Comment == nil {
+					continue
+				}
+
+				lines := append([]string{}, v.Comment.Lines...)
+				if v.Deprecated {
+					if len(lines) == 0 {
+						lines = []string{"deprecated."}
+					} else {
+						lines[len(lines)-1] += " (deprecated)"
+					}
+				}
+
+				for j, line := range lines {
+					if j == 0 {
+						line = v.ErlName + ": " + line
+					} else if line != "" {
+						line = "  " + line
+					}
+
+					valueLines = append(valueLines, line)
+				}
+			}
+		}
+
+		et.Comment = comments.Find(path...).
+			MarkDeprecated(format, et.Deprecated).
+			Append(format, valueLines...)
+	}
+
+	for _, fd := range g.Request.ProtoFile {
+		comments := CommentsFromFileDescriptor(fd, format)
+
+		for i, d := range fd.MessageType {
+			addMessageComments(comments, d,
+				[]int32{FileDescriptorMessageTypePath, int32(i)})
+		}
+
+		for i, ed := range fd.EnumType {
+			addEnumComments(comments, ed,
+				"."+fd.GetPackage()+"."+ed.GetName(),
+				[]int32{FileDescriptorEnumTypePath, int32(i)})
+		}
+
+		for i, sd := range fd.Service {
+			fullName := sd.GetName()
+			if fd.GetPackage() != "" {
+				fullName = fd.GetPackage() + "." + fullName
+			}
+
+			for _, st := range g.ServiceTypes {
+				if st.FullName != fullName {
+					continue
+				}
+
+				path := []int32{FileDescriptorServicePath, int32(i)}
+				st.Comment = comments.Find(path...).
+					MarkDeprecated(format, st.Deprecated)
+
+				for j, m := range st.Methods {
+					m.Comment = comments.Find(append(path,
+						ServiceDescriptorMethodPath, int32(j))...).
+						MarkDeprecated(format, m.Deprecated)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func (g *Generator) resolveTypes() error {
 	for _, mt := range g.MessageTypes {
 		if err := mt.ResolveTypes(g); err != nil {
@@ -333,6 +836,311 @@ func (g *Generator) resolveTypes() error {
 		}
 	}
 
+	for _, st := range g.ServiceTypes {
+		if err := st.ResolveTypes(g); err != nil {
+			return fmt.Errorf("cannot resolve types in service %q "+
+				"of package %q: %w", st.Name, st.Package, err)
+		}
+	}
+
+	return nil
+}
+
+func (g *Generator) checkValidationRules() error {
+	if !g.Options.Validate {
+		return nil
+	}
+
+	for _, mt := range g.PackageMessageTypes {
+		for _, ft := range mt.Fields {
+			rules := ft.ValidationRules
+			if rules == nil {
+				continue
+			}
+
+			if err := rules.CheckFieldType(ft, ft.Repeated); err != nil {
+				return fmt.Errorf("invalid validation rules for "+
+					"field %q of message %q: %w",
+					ft.Name, mt.FullName, err)
+			}
+
+			if names := rules.AllUnsupported(); len(names) > 0 {
+				return fmt.Errorf("unsupported validation rules %s "+
+					"for field %q of message %q",
+					strings.Join(names, ", "), ft.Name, mt.FullName)
+			}
+
+			rules.SetDefaultValue(ft)
+		}
+	}
+
+	return nil
+}
+
+// The JSON mapping of google.protobuf.Any requires the type of the packed
+// message to be resolved at runtime, which JSON functions cannot do.
+func (g *Generator) checkJSONTypes() error {
+	if !g.Options.JSON {
+		return nil
+	}
+
+	for _, mt := range g.PackageMessageTypes {
+		for _, ft := range mt.Fields {
+			if ft.MessageType != nil && ft.MessageType.WellKnownType == "Any" {
+				return fmt.Errorf("field %q of message %q has type "+
+					"google.protobuf.Any which is not supported "+
+					"by JSON functions", ft.Name, mt.FullName)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkErlTypes returns an error if the types of different messages and
+// enums, or of support functions of the module, have the same name.
+func (g *Generator) checkErlTypes() error {
+	nameToSource := map[string]string{
+		"reflection_field":    "reflection functions",
+		"reflection_type":     "reflection functions",
+		"encode_option":       "encoding functions",
+		"decode_option":       "decoding functions",
+		"decode_limit":        "decoding functions",
+		"decode_error_reason": "decoding functions",
+		"decode_error":        "decoding functions",
+	}
+
+	if g.Options.FieldMasks {
+		nameToSource["pb_field_mask"] = "field mask functions"
+	}
+
+	if g.Options.Validate {
+		nameToSource["validation_error"] = "validation functions"
+	}
+
+	if g.Options.Verify {
+		nameToSource["verification_error"] = "verification functions"
+	}
+
+	addType := func(name, source string) error {
+		if source2, found := nameToSource[name]; found {
+			return fmt.Errorf("type %s() generated for %s conflicts "+
+				"with type generated for %s", name, source, source2)
+		}
+
+		nameToSource[name] = source
+		return nil
+	}
+
+	for _, et := range g.PackageEnumTypes {
+		if err := addType(et.ErlTypeName, "enum "+et.FullName); err != nil {
+			return err
+		}
+	}
+
+	for _, mt := range g.PackageMessageTypes {
+		if err := addType(mt.ErlTypeName, "message "+mt.FullName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *Generator) collectErlMacros() error {
+	if !g.Options.HRLMacros {
+		return nil
+	}
+
+	var macros ErlMacros
+	nameToMacro := make(map[string]*ErlMacro)
+
+	addMacro := func(name string, value int, source string) error {
+		macro := ErlMacro{
+			Name:   strings.ToUpper(name),
+			Value:  strconv.Itoa(value),
+			Source: source,
+		}
+
+		if macro2, found := nameToMacro[macro.Name]; found {
+			return fmt.Errorf("macro %s generated for %s conflicts "+
+				"with macro generated for %s",
+				macro.Name, macro.Source, macro2.Source)
+		}
+
+		macros = append(macros, &macro)
+		nameToMacro[macro.Name] = &macro
+
+		return nil
+	}
+
+	for _, et := range g.PackageEnumTypes {
+		for _, ev := range et.Values {
+			name := et.ErlName + "_" + ev.ErlName
+			source := "value " + ev.Name + " of enum " + et.FullName
+
+			if err := addMacro(name, ev.Number, source); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, mt := range g.PackageMessageTypes {
+		for _, ft := range mt.Fields {
+			name := mt.ErlName + "_" + ft.ErlName + "_field_number"
+			source := "field " + ft.Name + " of message " + mt.FullName
+
+			if err := addMacro(name, ft.Number, source); err != nil {
+				return err
+			}
+		}
+	}
+
+	g.ErlMacros = macros
+	return nil
+}
+
+func (g *Generator) collectErlExports() error {
+	var exports ErlExports
+
+	for _, et := range g.PackageEnumTypes {
+		deprecated := et.Deprecated
+		source := "enum " + et.FullName
+
+		exports.Enums.Add(et.ErlName+"_to_int", 1, deprecated, source)
+		exports.Enums.Add("int_to_"+et.ErlName, 1, deprecated, source)
+		exports.Enums.Add(et.ErlName+"_values", 0, deprecated, source)
+		exports.Enums.Add(et.ErlName+"_name", 1, deprecated, source)
+		exports.Enums.Add(et.ErlName+"_aliases", 1, deprecated, source)
+
+		if g.Options.JSON {
+			exports.JSONEnums.Add("to_json_"+et.ErlName, 1, deprecated, source)
+			exports.JSONEnums.Add("from_json_"+et.ErlName, 1, deprecated, source)
+		}
+	}
+
+	for _, mt := range g.PackageMessageTypes {
+		name := mt.ErlName
+		deprecated := mt.Deprecated
+		source := "message " + mt.FullName
+
+		exports.Messages.Add("encode_"+name, 1, deprecated, source)
+		exports.Messages.Add("encode_"+name, 2, deprecated, source)
+		exports.Messages.Add("decode_"+name, 1, deprecated, source)
+		exports.Messages.Add("decode_"+name, 2, deprecated, source)
+		exports.Messages.Add("codec_fields_"+name, 1, deprecated, source)
+		exports.Messages.Add("decoding_fields_"+name, 0, deprecated, source)
+		exports.Messages.Add("encoded_size_"+name, 1, deprecated, source)
+		exports.Messages.Add("merge_"+name, 2, deprecated, source)
+
+		if g.Options.Accessors {
+			exports.Accessors.Add("new_"+name, 0, deprecated, source)
+			exports.Accessors.Add("new_"+name, 1, deprecated, source)
+
+			for _, ft := range mt.Fields {
+				fieldDeprecated := deprecated || ft.Deprecated
+				fieldSource := "field " + ft.Name + " of " + source
+
+				exports.Accessors.Add("get_"+name+"_"+ft.ErlName, 1,
+					fieldDeprecated, fieldSource)
+				exports.Accessors.Add("set_"+name+"_"+ft.ErlName, 2,
+					fieldDeprecated, fieldSource)
+			}
+
+			for _, ot := range mt.Oneofs {
+				oneofSource := "oneof " + ot.Name + " of " + source
+
+				exports.Accessors.Add("which_"+name+"_"+ot.ErlName, 1,
+					deprecated, oneofSource)
+			}
+		}
+
+		if g.Options.FieldMasks {
+			exports.FieldMasks.Add("mask_"+name, 2, deprecated, source)
+			exports.FieldMasks.Add("merge_masked_"+name, 3,
+				deprecated, source)
+			exports.FieldMasks.Add("validate_mask_"+name, 1,
+				deprecated, source)
+		}
+
+		if g.Options.JSON {
+			exports.JSONMessages.Add("to_json_"+name, 1, deprecated, source)
+			exports.JSONMessages.Add("from_json_"+name, 1, deprecated, source)
+		}
+
+		if g.Options.Validate {
+			exports.Validation.Add("validate_"+name, 1, deprecated, source)
+		}
+
+		if g.Options.Verify {
+			exports.Verification.Add("verify_"+name, 1, deprecated, source)
+		}
+	}
+
+	if err := exports.CheckConflicts(); err != nil {
+		return err
+	}
+
+	g.ErlExports = exports
+	return nil
+}
+
+func (g *Generator) collectFileDescriptors() error {
+	nameToFileDescriptor := make(map[string]*descriptor.FileDescriptorProto)
+	for _, fd := range g.Request.ProtoFile {
+		nameToFileDescriptor[fd.GetName()] = fd
+	}
+
+	selected := make(map[string]bool)
+
+	var addFile func(string) error
+	addFile = func(name string) error {
+		if selected[name] {
+			return nil
+		}
+
+		fd, found := nameToFileDescriptor[name]
+		if !found {
+			return fmt.Errorf("unknown file %q", name)
+		}
+
+		selected[name] = true
+
+		for _, depName := range fd.Dependency {
+			if err := addFile(depName); err != nil {
+				return fmt.Errorf("cannot add dependency of "+
+					"file %q: %w", name, err)
+			}
+		}
+
+		return nil
+	}
+
+	for _, fd := range g.InputFileDescriptors {
+		if err := addFile(fd.GetName()); err != nil {
+			return err
+		}
+	}
+
+	// Protoc sends files in topological order, we keep it so that each
+	// file appears after its dependencies in the descriptor set.
+	var fds FileDescriptors
+
+	for _, fd := range g.Request.ProtoFile {
+		if !selected[fd.GetName()] {
+			continue
+		}
+
+		var f FileDescriptor
+		if err := f.FromDescriptor(fd); err != nil {
+			return fmt.Errorf("cannot create descriptor for "+
+				"file %q: %w", fd.GetName(), err)
+		}
+
+		fds = append(fds, &f)
+	}
+
+	g.FileDescriptors = fds
 	return nil
 }
 
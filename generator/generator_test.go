@@ -0,0 +1,284 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func testFieldDescriptor(name string, number int32, tid descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+	return &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     tid.Enum(),
+		JsonName: proto.String(name),
+	}
+}
+
+func testGenerationError(t *testing.T, fd *descriptor.FileDescriptorProto, parameter, expectedError string) {
+	fds := []*descriptor.FileDescriptorProto{fd}
+
+	_, err := generateErlTestFiles(fds, []string{fd.GetName()}, parameter)
+	if err == nil {
+		t.Errorf("%s: generation should fail", fd.GetName())
+	} else if !strings.Contains(err.Error(), expectedError) {
+		t.Errorf("%s: error is %q but should contain %q",
+			fd.GetName(), err.Error(), expectedError)
+	}
+}
+
+func TestGeneratorMacroConflicts(t *testing.T) {
+	// A.B.c and A.b_c both generate A_B_C_FIELD_NUMBER
+	fd := &descriptor.FileDescriptorProto{
+		Name:    proto.String("conflicts.proto"),
+		Package: proto.String("conflicts"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("A"),
+				Field: []*descriptor.FieldDescriptorProto{
+					testFieldDescriptor("b_c", 1,
+						descriptor.FieldDescriptorProto_TYPE_INT32),
+				},
+				NestedType: []*descriptor.DescriptorProto{
+					{
+						Name: proto.String("B"),
+						Field: []*descriptor.FieldDescriptorProto{
+							testFieldDescriptor("c", 1,
+								descriptor.FieldDescriptorProto_TYPE_INT32),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	testGenerationError(t, fd, "hrl_macros", "macro A_B_C_FIELD_NUMBER")
+
+	fds := []*descriptor.FileDescriptorProto{fd}
+	if _, err := generateErlTestFiles(fds, []string{fd.GetName()}, ""); err != nil {
+		t.Errorf("generation without macros failed: %v", err)
+	}
+}
+
+func testAnyFileDescriptor() *descriptor.FileDescriptorProto {
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String("google/protobuf/any.proto"),
+		Package: proto.String("google.protobuf"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("Any"),
+				Field: []*descriptor.FieldDescriptorProto{
+					testFieldDescriptor("type_url", 1,
+						descriptor.FieldDescriptorProto_TYPE_STRING),
+					testFieldDescriptor("value", 2,
+						descriptor.FieldDescriptorProto_TYPE_BYTES),
+				},
+			},
+		},
+	}
+}
+
+func TestGeneratorJSONAnyFields(t *testing.T) {
+	anyField := testFieldDescriptor("details", 1,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	anyField.TypeName = proto.String(".google.protobuf.Any")
+
+	anyFd := testAnyFileDescriptor()
+	fd := &descriptor.FileDescriptorProto{
+		Name:       proto.String("errors.proto"),
+		Package:    proto.String("errors"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{anyFd.GetName()},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:  proto.String("Error"),
+				Field: []*descriptor.FieldDescriptorProto{anyField},
+			},
+		},
+	}
+
+	fds := []*descriptor.FileDescriptorProto{anyFd, fd}
+	files := []string{fd.GetName()}
+
+	for _, parameter := range []string{"json", "twirp", "http"} {
+		_, err := generateErlTestFiles(fds, files, parameter)
+		if err == nil {
+			t.Errorf("%s: generation should fail", parameter)
+		} else if !strings.Contains(err.Error(), "google.protobuf.Any") {
+			t.Errorf("%s: unexpected error %q", parameter, err.Error())
+		}
+	}
+
+	if _, err := generateErlTestFiles(fds, files, ""); err != nil {
+		t.Errorf("generation without json failed: %v", err)
+	}
+
+	// The JSON functions of the Any message itself signal an error
+	res, err := generateErlTestFiles(fds, []string{anyFd.GetName()}, "json")
+	if err != nil {
+		t.Fatalf("cannot generate google.protobuf.Any: %v", err)
+	}
+
+	for _, f := range res.File {
+		if !strings.HasSuffix(f.GetName(), ".erl") {
+			continue
+		}
+
+		content := f.GetContent()
+		for _, s := range []string{
+			"to_json_any(Message) ->\n" +
+				"  error({unsupported_json_mapping, Message, any}).",
+			"from_json_any(Json) ->\n" +
+				"  error({unsupported_json_mapping, Json, any}).",
+		} {
+			if !strings.Contains(content, s) {
+				t.Errorf("%s does not contain %q", f.GetName(), s)
+			}
+		}
+	}
+}
+
+func TestGeneratorBuiltinTypeNames(t *testing.T) {
+	childField := testFieldDescriptor("child", 1,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	childField.TypeName = proto.String(".builtins.Node")
+
+	numberField := testFieldDescriptor("number", 2,
+		descriptor.FieldDescriptorProto_TYPE_ENUM)
+	numberField.TypeName = proto.String(".builtins.Number")
+
+	fd := &descriptor.FileDescriptorProto{
+		Name:    proto.String("builtins.proto"),
+		Package: proto.String("builtins"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:  proto.String("Node"),
+				Field: []*descriptor.FieldDescriptorProto{childField, numberField},
+			},
+		},
+		EnumType: []*descriptor.EnumDescriptorProto{
+			{
+				Name: proto.String("Number"),
+				Value: []*descriptor.EnumValueDescriptorProto{
+					{Name: proto.String("ZERO"), Number: proto.Int32(0)},
+				},
+			},
+		},
+	}
+
+	anyFd := testAnyFileDescriptor()
+
+	tests := []struct {
+		fd       *descriptor.FileDescriptorProto
+		expected []string
+	}{
+		{fd, []string{
+			"-type node_message() :: #node{}.",
+			"-type number_enum() :: zero.",
+			"  node_message/0\n",
+			"  number_enum/0\n",
+			"-spec encode_node(node_message()) -> binary().",
+			"child = undefined :: undefined | builtins:node_message()",
+			"number = zero :: builtins:number_enum()",
+		}},
+		{anyFd, []string{
+			"-type any_message() :: #any{}.",
+			"  any_message/0\n",
+		}},
+	}
+
+	for _, test := range tests {
+		fds := []*descriptor.FileDescriptorProto{test.fd}
+		files := []string{test.fd.GetName()}
+
+		res, err := generateErlTestFiles(fds, files, "")
+		if err != nil {
+			t.Errorf("%s: generation failed: %v", test.fd.GetName(), err)
+			continue
+		}
+
+		var content string
+		for _, f := range res.File {
+			content += f.GetContent()
+		}
+
+		for _, s := range test.expected {
+			if !strings.Contains(content, s) {
+				t.Errorf("%s: output does not contain %q",
+					test.fd.GetName(), s)
+			}
+		}
+	}
+}
+
+func TestGeneratorTypeConflicts(t *testing.T) {
+	testFd := func(name string, messageNames ...string) *descriptor.FileDescriptorProto {
+		fd := &descriptor.FileDescriptorProto{
+			Name:    proto.String(name + ".proto"),
+			Package: proto.String(name),
+			Syntax:  proto.String("proto3"),
+		}
+
+		for _, messageName := range messageNames {
+			fd.MessageType = append(fd.MessageType,
+				&descriptor.DescriptorProto{Name: proto.String(messageName)})
+		}
+
+		return fd
+	}
+
+	tests := []struct {
+		fd            *descriptor.FileDescriptorProto
+		parameter     string
+		expectedError string
+	}{
+		{testFd("decode_errors", "DecodeError"), "",
+			"type decode_error() generated for message DecodeError"},
+		{testFd("reflection_fields", "ReflectionField"), "",
+			"type reflection_field() generated for message ReflectionField"},
+		{testFd("field_masks", "PbFieldMask"), "field_masks",
+			"type pb_field_mask() generated for message PbFieldMask"},
+		{testFd("validation_errors", "ValidationError"), "validate",
+			"type validation_error() generated for message ValidationError"},
+		{testFd("verification_errors", "VerificationError"), "verify",
+			"type verification_error() generated for message VerificationError"},
+		{testFd("builtins", "Any", "AnyMessage"), "",
+			"type any_message() generated for message AnyMessage"},
+	}
+
+	for _, test := range tests {
+		testGenerationError(t, test.fd, test.parameter, test.expectedError)
+	}
+
+	// Support types only conflict when they are generated
+	for _, name := range []string{"PbFieldMask", "ValidationError",
+		"VerificationError"} {
+		fd := testFd("no_conflicts", name)
+		fds := []*descriptor.FileDescriptorProto{fd}
+
+		_, err := generateErlTestFiles(fds, []string{fd.GetName()}, "")
+		if err != nil {
+			t.Errorf("%s: generation failed: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlGRPCBehaviourTemplateContent = `
+{{- define "erl_input" }}{{ .InputType.ErlPackage }}:{{ .InputType.ErlTypeName }}(){{ end }}
+{{- define "erl_output" }}{{ .OutputType.ErlPackage }}:{{ .OutputType.ErlTypeName }}(){{ end }}
+
+{{- define "erl_callback" }}
+%% Generated for method {{ .Name }}.
+{{- with .Comment }}
+{{ .ErlDoc }}{{ end }}{{- if .Unary }}
+-callback {{ .ErlName }}(ctx:t(), {{ template "erl_input" . }}) ->
+  {ok, {{ template "erl_output" . }}, ctx:t()}
+  | grpcbox_stream:grpc_error_response().
+{{- else if .BidiStreaming }}
+-callback {{ .ErlName }}(reference(), grpcbox_stream:t()) ->
+  ok | grpcbox_stream:grpc_error_response().
+{{- else if .ClientStreaming }}
+-callback {{ .ErlName }}(reference(), grpcbox_stream:t()) ->
+  {ok, {{ template "erl_output" . }}, ctx:t()}
+  | grpcbox_stream:grpc_error_response().
+{{- else }}
+-callback {{ .ErlName }}({{ template "erl_input" . }}, grpcbox_stream:t()) ->
+  ok | grpcbox_stream:grpc_error_response().
+{{- end }}
+{{- end }}
+
+%%% Generated from protobuf service {{ .FullName }}.
+%%% DO NOT EDIT.
+{{- with .Comment }}{{ .ErlModuleComment }}{{ end }}
+
+-module({{ .ErlBehaviourModuleName }}).
+{{- with .Comment }}{{ .ErlModuleDoc }}{{ end }}
+{{- if .DeprecatedMethods }}
+
+-deprecated_callback([
+  {{- range $i, $m := .DeprecatedMethods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ "{" }}{{ $m.ErlName }}, 2}
+  {{- end }}
+]).
+{{- end }}
+{{ range .Methods }}
+{{- template "erl_callback" . }}
+{{ end }}
+`
+
+func ErlGRPCBehaviourTemplate() (*template.Template, error) {
+	tpl := template.New("erl_grpc_behaviour")
+
+	if _, err := tpl.Parse(erlGRPCBehaviourTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
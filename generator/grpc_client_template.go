@@ -0,0 +1,213 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlGRPCClientTemplateContent = `
+{{- define "erl_input" }}{{ .InputType.ErlPackage }}:{{ .InputType.ErlTypeName }}(){{ end }}
+{{- define "erl_output" }}{{ .OutputType.ErlPackage }}:{{ .OutputType.ErlTypeName }}(){{ end }}
+
+{{- define "erl_unary_call" }}
+%% Generated for method {{ .Name }}.
+{{ with .Comment }}{{ .ErlDoc }}
+{{ end }}-spec {{ .ErlName }}(channel(), {{ template "erl_input" . }}) ->
+        {ok, {{ template "erl_output" . }}} | {error, term()}.
+{{ .ErlName }}(Channel, Request) ->
+  {{ .ErlName }}(Channel, Request, #{}).
+
+-spec {{ .ErlName }}(channel(), {{ template "erl_input" . }}, call_options()) ->
+        {ok, {{ template "erl_output" . }}} | {error, term()}.
+{{ .ErlName }}({Transport, State}, Request, Options) ->
+  Data = iolist_to_binary({{ .InputType.ErlPackage }}:encode_{{ .InputType.ErlName }}(Request)),
+  case Transport:unary(State, <<"{{ .Path }}">>, Data, Options) of
+    {ok, ResponseData} ->
+      case {{ .OutputType.ErlPackage }}:decode_{{ .OutputType.ErlName }}(ResponseData, []) of
+        {ok, Response} ->
+          {ok, Response};
+        {error, Reason} ->
+          {error, {decode_error, Reason}}
+      end;
+    {error, Reason} ->
+      {error, Reason}
+  end.
+{{- end }}
+
+{{- define "erl_server_streaming_call" }}
+%% Generated for method {{ .Name }}.
+{{ with .Comment }}{{ .ErlDoc }}
+{{ end }}-spec {{ .ErlName }}(channel(), {{ template "erl_input" . }}) ->
+        {ok, stream()} | {error, term()}.
+{{ .ErlName }}(Channel, Request) ->
+  {{ .ErlName }}(Channel, Request, #{}).
+
+-spec {{ .ErlName }}(channel(), {{ template "erl_input" . }}, call_options()) ->
+        {ok, stream()} | {error, term()}.
+{{ .ErlName }}(Channel, Request, Options) ->
+  case new_stream(Channel, <<"{{ .Path }}">>, Options,
+                  fun {{ .InputType.ErlPackage }}:encode_{{ .InputType.ErlName }}/1,
+                  fun {{ .OutputType.ErlPackage }}:decode_{{ .OutputType.ErlName }}/2) of
+    {ok, Stream} ->
+      case send(Stream, Request) of
+        ok ->
+          case close_send(Stream) of
+            ok ->
+              {ok, Stream};
+            {error, Reason} ->
+              {error, Reason}
+          end;
+        {error, Reason} ->
+          {error, Reason}
+      end;
+    {error, Reason} ->
+      {error, Reason}
+  end.
+{{- end }}
+
+{{- define "erl_client_streaming_call" }}
+%% Generated for method {{ .Name }}.
+{{ with .Comment }}{{ .ErlDoc }}
+{{ end }}-spec {{ .ErlName }}(channel()) -> {ok, stream()} | {error, term()}.
+{{ .ErlName }}(Channel) ->
+  {{ .ErlName }}(Channel, #{}).
+
+-spec {{ .ErlName }}(channel(), call_options()) ->
+        {ok, stream()} | {error, term()}.
+{{ .ErlName }}(Channel, Options) ->
+  new_stream(Channel, <<"{{ .Path }}">>, Options,
+             fun {{ .InputType.ErlPackage }}:encode_{{ .InputType.ErlName }}/1,
+             fun {{ .OutputType.ErlPackage }}:decode_{{ .OutputType.ErlName }}/2).
+{{- end }}
+
+%%% Generated from protobuf service {{ .FullName }}.
+%%% DO NOT EDIT.
+{{- with .Comment }}{{ .ErlModuleComment }}{{ end }}
+
+-module({{ .ErlClientModuleName }}).
+{{- with .Comment }}{{ .ErlModuleDoc }}{{ end }}
+
+-export([
+  {{- range $i, $m := .Methods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{- if $m.ClientStreaming }}
+  {{ $m.ErlName }}/1,
+  {{ $m.ErlName }}/2
+  {{- else }}
+  {{ $m.ErlName }}/2,
+  {{ $m.ErlName }}/3
+  {{- end }}
+  {{- end }}
+]).
+
+{{- if .HasStreamingMethods }}
+
+-export([send/2,
+         close_send/1,
+         recv/1,
+         recv/2]).
+
+-export_type([stream/0]).
+{{- end }}
+{{- if .DeprecatedMethods }}
+
+-deprecated([
+  {{- range $i, $m := .DeprecatedMethods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ "{" }}{{ $m.ErlName }}, '_'}
+  {{- end }}
+]).
+{{- end }}
+
+-type channel() :: {{ .ErlTransportModuleName }}:channel().
+-type call_options() :: {{ .ErlTransportModuleName }}:call_options().
+{{- if .HasStreamingMethods }}
+
+-opaque stream() :: #{transport := module(),
+                      state := term(),
+                      encode := fun((term()) -> iodata()),
+                      decode := fun((binary(), list()) ->
+                                       {ok, term()} | {error, term()})}.
+{{- end }}
+{{ range .Methods }}
+{{- if .Unary }}
+{{- template "erl_unary_call" . }}
+{{- else if .ClientStreaming }}
+{{- template "erl_client_streaming_call" . }}
+{{- else }}
+{{- template "erl_server_streaming_call" . }}
+{{- end }}
+{{ end }}
+{{- if .HasStreamingMethods }}
+%% Send a message on a client or bidirectional streaming call.
+-spec send(stream(), term()) -> ok | {error, term()}.
+send(#{transport := Transport, state := State, encode := Encode}, Message) ->
+  Transport:send(State, iolist_to_binary(Encode(Message))).
+
+%% Signal that no more message will be sent on a stream.
+-spec close_send(stream()) -> ok | {error, term()}.
+close_send(#{transport := Transport, state := State}) ->
+  Transport:close_send(State).
+
+-spec recv(stream()) -> {ok, term()} | eos | {error, term()}.
+recv(Stream) ->
+  recv(Stream, 5000).
+
+%% Wait for the next message on a streaming call.
+-spec recv(stream(), timeout()) -> {ok, term()} | eos | {error, term()}.
+recv(#{transport := Transport, state := State, decode := Decode}, Timeout) ->
+  case Transport:recv(State, Timeout) of
+    {ok, Data} ->
+      case Decode(Data, []) of
+        {ok, Message} ->
+          {ok, Message};
+        {error, Reason} ->
+          {error, {decode_error, Reason}}
+      end;
+    eos ->
+      eos;
+    {error, Reason} ->
+      {error, Reason}
+  end.
+
+-spec new_stream(channel(), binary(), call_options(),
+                 fun((term()) -> iodata()),
+                 fun((binary(), list()) ->
+                         {ok, term()} | {error, term()})) ->
+        {ok, stream()} | {error, term()}.
+new_stream({Transport, State}, Path, Options, Encode, Decode) ->
+  case Transport:new_stream(State, Path, Options) of
+    {ok, StreamState} ->
+      {ok, #{transport => Transport,
+             state => StreamState,
+             encode => Encode,
+             decode => Decode}};
+    {error, Reason} ->
+      {error, Reason}
+  end.
+{{- end }}
+`
+
+func ErlGRPCClientTemplate() (*template.Template, error) {
+	tpl := template.New("erl_grpc_client")
+
+	if _, err := tpl.Parse(erlGRPCClientTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlGRPCDispatchTemplateContent = `
+{{- define "erl_rpc_def" -}}
+#{name => '{{ .Name }}', input => {{ .InputType.ErlName }}, output => {{ .OutputType.ErlName }}, input_stream => {{ .ClientStreaming }}, output_stream => {{ .ServerStreaming }}, opts => []}
+{{- end }}
+
+{{- define "erl_dispatch" }}
+{{- if .Unary }}
+dispatch(Impl, <<"{{ .Path }}">>, Ctx, Data) ->
+  try decode_msg(Data, {{ .InputType.ErlName }}) of
+    Request ->
+      case Impl:{{ .ErlName }}(Ctx, Request) of
+        {ok, Response, Ctx2} ->
+          {ok, encode_msg(Response, {{ .OutputType.ErlName }}), Ctx2};
+        Error ->
+          Error
+      end
+  catch
+    throw:{grpc_error, _} = Error ->
+      Error
+  end;
+{{- else if .BidiStreaming }}
+dispatch(Impl, <<"{{ .Path }}">>, Stream, Ref) ->
+  Impl:{{ .ErlName }}(Ref, Stream);
+{{- else if .ClientStreaming }}
+dispatch(Impl, <<"{{ .Path }}">>, Stream, Ref) ->
+  case Impl:{{ .ErlName }}(Ref, Stream) of
+    {ok, Response, Ctx} ->
+      {ok, encode_msg(Response, {{ .OutputType.ErlName }}), Ctx};
+    Error ->
+      Error
+  end;
+{{- else }}
+dispatch(Impl, <<"{{ .Path }}">>, Stream, Data) ->
+  try decode_msg(Data, {{ .InputType.ErlName }}) of
+    Request ->
+      Impl:{{ .ErlName }}(Request, Stream)
+  catch
+    throw:{grpc_error, _} = Error ->
+      Error
+  end;
+{{- end }}
+{{- end }}
+
+%%% Generated from protobuf service {{ .FullName }}.
+%%% DO NOT EDIT.
+
+-module({{ .ErlDispatchModuleName }}).
+
+-export([get_service_names/0,
+         get_service_def/1,
+         find_rpc_def/2,
+         fqbins_to_service_and_rpc_name/2,
+         encode_msg/2,
+         decode_msg/2,
+         dispatch/4]).
+
+-export_type([rpc_def/0]).
+
+-type rpc_def() :: #{name := atom(),
+                     input := atom(),
+                     output := atom(),
+                     input_stream := boolean(),
+                     output_stream := boolean(),
+                     opts := list()}.
+
+-spec get_service_names() -> [atom()].
+get_service_names() ->
+  ['{{ .FullName }}'].
+
+-spec get_service_def(atom()) -> {{ "{{" }}service, atom()}, [rpc_def()]} | error.
+get_service_def('{{ .FullName }}') ->
+  {{ "{{" }}service, '{{ .FullName }}'},
+   [
+   {{- range $i, $m := .Methods }}
+   {{- if gt $i 0 }},{{ end }}
+    {{ template "erl_rpc_def" $m }}
+   {{- end }}]};
+get_service_def(_) ->
+  error.
+
+-spec find_rpc_def(atom(), atom()) -> rpc_def() | error.
+{{- range .Methods }}
+find_rpc_def('{{ .Service.FullName }}', '{{ .Name }}') ->
+  {{ template "erl_rpc_def" . }};
+{{- end }}
+find_rpc_def(_, _) ->
+  error.
+
+-spec fqbins_to_service_and_rpc_name(binary(), binary()) -> {atom(), atom()}.
+{{- range .Methods }}
+fqbins_to_service_and_rpc_name(<<"{{ .Service.FullName }}">>, <<"{{ .Name }}">>) ->
+  {'{{ .Service.FullName }}', '{{ .Name }}'};
+{{- end }}
+fqbins_to_service_and_rpc_name(Service, Rpc) ->
+  error({gpb_error, {badarg, {Service, Rpc}}}).
+
+-spec encode_msg(tuple(), atom()) -> binary().
+{{- range .MessageTypes }}
+encode_msg(Message, {{ .ErlName }}) ->
+  iolist_to_binary({{ .ErlPackage }}:encode_{{ .ErlName }}(Message));
+{{- end }}
+encode_msg(_, MessageName) ->
+  error({unknown_message, MessageName}).
+
+%% Decode a message; invalid data are signaled by throwing an
+%% INVALID_ARGUMENT grpc error, as expected by grpcbox.
+-spec decode_msg(binary(), atom()) -> tuple().
+{{- range .MessageTypes }}
+decode_msg(Data, {{ .ErlName }}) ->
+  decode_result({{ .ErlPackage }}:decode_{{ .ErlName }}(Data, []));
+{{- end }}
+decode_msg(_, MessageName) ->
+  error({unknown_message, MessageName}).
+
+-spec decode_result({ok, tuple()} | {error, term()}) -> tuple().
+decode_result({ok, Message}) ->
+  Message;
+decode_result({error, Reason}) ->
+  Description = io_lib:format("invalid message: ~p", [Reason]),
+  throw({grpc_error, {<<"3">>, iolist_to_binary(Description)}}).
+
+%% Route a request to the callback of the implementation module Impl, which
+%% must implement the {{ .ErlBehaviourModuleName }} behaviour. Arguments
+%% depend on the kind of method:
+%% - unary: dispatch(Impl, Path, Ctx, RequestData);
+%% - server streaming: dispatch(Impl, Path, Stream, RequestData);
+%% - client and bidirectional streaming: dispatch(Impl, Path, Stream, Ref).
+-spec dispatch(module(), binary(), term(), term()) -> term().
+{{- range .Methods }}
+{{- template "erl_dispatch" . }}
+{{- end }}
+dispatch(_, Path, _, _) ->
+  {grpc_error, {<<"12">>, <<"unknown method ", Path/binary>>}}.
+`
+
+func ErlGRPCDispatchTemplate() (*template.Template, error) {
+	tpl := template.New("erl_grpc_dispatch")
+
+	if _, err := tpl.Parse(erlGRPCDispatchTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
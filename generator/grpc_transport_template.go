@@ -0,0 +1,71 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlGRPCTransportTemplateContent = `
+%%% Generated from protobuf package {{ .PackageName }}.
+%%% DO NOT EDIT.
+
+%%% Transports are used by generated gRPC clients to send requests and
+%%% receive responses. Messages are passed to transports encoded; framing,
+%%% compression and metadata are the responsibility of the transport.
+
+-module({{ .ErlModuleName }}_grpc_transport).
+
+-export_type([channel/0, call_options/0]).
+
+-type channel() :: {module(), term()}.
+
+-type call_options() :: #{metadata => #{binary() => binary()},
+                          timeout => timeout(),
+                          atom() => term()}.
+
+%% Execute a unary call and return the encoded response.
+-callback unary(State :: term(), Path :: binary(), Data :: binary(),
+                call_options()) ->
+  {ok, binary()} | {error, term()}.
+
+%% Open a new stream for a streaming call.
+-callback new_stream(State :: term(), Path :: binary(), call_options()) ->
+  {ok, StreamState :: term()} | {error, term()}.
+
+%% Send an encoded message on a stream.
+-callback send(StreamState :: term(), Data :: binary()) ->
+  ok | {error, term()}.
+
+%% Signal that the client will not send any more message on a stream.
+-callback close_send(StreamState :: term()) ->
+  ok | {error, term()}.
+
+%% Wait for the next encoded message on a stream; eos is returned once the
+%% server has closed the stream.
+-callback recv(StreamState :: term(), timeout()) ->
+  {ok, binary()} | eos | {error, term()}.
+`
+
+func ErlGRPCTransportTemplate() (*template.Template, error) {
+	tpl := template.New("erl_grpc_transport")
+
+	if _, err := tpl.Parse(erlGRPCTransportTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
@@ -21,6 +21,9 @@ import (
 
 var erlHRLTemplateContent = `
 {{- define "erl_field" }}
+{{- with .Comment }}
+{{ .ErlComment "  " }}
+{{- end }}
   {{ .ErlName }} = {{ .ErlDefaultValue }} :: {{ .ErlTypeSpec }}
 {{- end }}
 
@@ -30,7 +33,8 @@ var erlHRLTemplateContent = `
 
 {{- define "erl_message" }}
 %% Generated for message type {{ .FullName }}.
--record({{ .ErlName }}, {
+{{ with .Comment }}{{ .ErlComment "" }}
+{{ end }}-record({{ .ErlName }}, {
   {{- $first := true }}
 
   {{- range $i, $f := .Fields }}
@@ -44,11 +48,18 @@ var erlHRLTemplateContent = `
     {{- if $first  }}{{  $first = false  }}{{- else }},{{- end }}
     {{- template "erl_oneof" . }}
   {{- end }}
+  {{- if not $first }},{{ end }}
+  '$unknown' = [] :: [{pos_integer(), binary()}]
 }).
 {{- end }}
 
 %%% Generated from protobuf package {{ .PackageName }}.
 %%% DO NOT EDIT.
+{{- if .ErlMacros }}
+{{ range .ErlMacros }}
+-define({{ .Name }}, {{ .Value }}).
+{{- end }}
+{{- end }}
 
 {{ range .PackageMessageTypes }}
 {{- template "erl_message" . }}
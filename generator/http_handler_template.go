@@ -0,0 +1,318 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlHTTPHandlerTemplateContent = `
+%%% Generated from protobuf service {{ .FullName }}.
+%%% DO NOT EDIT.
+
+%%% A cowboy handler serving the service over HTTP based on google.api.http
+%%% annotations. Path variables, query parameters and the request body are
+%%% mapped to the request message, and responses are serialized with the
+%%% protobuf JSON mapping.
+%%%
+%%% Requests are forwarded to an implementation module of the
+%%% {{ .ErlBehaviourModuleName }} behaviour, so that the same implementation
+%%% can serve both HTTP and gRPC requests. The cowboy request is available in
+%%% the context with the cowboy_req key.
+%%%
+%%% Use routes/1 to obtain cowboy routes for the service.
+{{- with .Comment }}{{ .ErlModuleComment }}{{ end }}
+
+-module({{ .ErlHTTPHandlerModuleName }}).
+{{- with .Comment }}{{ .ErlModuleDoc }}{{ end }}
+
+-behaviour(cowboy_handler).
+
+-export([routes/1, init/2]).
+
+-type json_body() :: none | all | {field, binary()}.
+
+%% The field path of a path variable and the path segments of its value.
+-type variable() :: {[binary()], [binary()]}.
+
+-spec routes(module()) -> [{binary(), module(), map()}].
+routes(Impl) ->
+  [
+  {{- range $i, $route := .CowboyRoutes }}
+  {{- if gt $i 0 }},{{ end }}
+   {<<"{{ $route }}">>, ?MODULE, #{impl => Impl}}
+  {{- end }}].
+
+-spec init(cowboy_req:req(), map()) -> {ok, cowboy_req:req(), map()}.
+init(Req0, Options = #{impl := Impl}) ->
+  Method = cowboy_req:method(Req0),
+  Segments = path_segments(cowboy_req:path(Req0)),
+  Req = case match_route(Method, Segments) of
+          {ok, Function, Variables, Body, ResponseBody} ->
+            call(Impl, Function, Variables, Body, ResponseBody, Req0);
+          error ->
+            reply_error(<<"5">>, <<"no route found">>, Req0)
+        end,
+  {ok, Req, Options}.
+
+-spec path_segments(binary()) -> [binary()].
+path_segments(Path) ->
+  [Segment || Segment <- binary:split(Path, <<"/">>, [global]),
+              Segment =/= <<>>].
+
+-spec match_route(binary(), [binary()]) ->
+        {ok, atom(), [variable()], json_body(), json_body()} | error.
+match_route(Method, Segments) ->
+  Route = case lists:reverse(Segments) of
+            [Last | Rest] ->
+              case string:split(Last, <<":">>, trailing) of
+                [Segment, Verb] ->
+                  Segments2 = lists:reverse(Rest, [Segment]),
+                  match(Method, decode_segments(Segments2), Verb);
+                _ ->
+                  error
+              end;
+            [] ->
+              error
+          end,
+  case Route of
+    error ->
+      match(Method, decode_segments(Segments), undefined);
+    _ ->
+      Route
+  end.
+
+-spec decode_segments([binary()]) -> [binary()].
+decode_segments(Segments) ->
+  lists:map(fun (Segment) ->
+                case uri_string:percent_decode(Segment) of
+                  Decoded when is_binary(Decoded) ->
+                    Decoded;
+                  _ ->
+                    Segment
+                end
+            end, Segments).
+
+-spec match(binary(), [binary()], binary() | undefined) ->
+        {ok, atom(), [variable()], json_body(), json_body()} | error.
+{{- range $m := .HTTPMethods }}
+{{- range .HTTPRules }}
+match(<<"{{ .Method }}">>, {{ .PathTemplate.ErlSegmentsPattern }}, {{ .PathTemplate.ErlVerb }}) ->
+  {ok, {{ $m.ErlName }}, {{ .PathTemplate.ErlVariables }},
+   {{ .ErlBody }}, {{ .ErlResponseBody }}};
+{{- end }}
+{{- end }}
+match(_, _, _) ->
+  error.
+
+-spec method(atom()) ->
+        {fun((json:decode_value()) -> tuple()),
+         fun((tuple()) -> json:encode_value()),
+         {module(), atom()}}.
+{{- range $i, $m := .HTTPMethods }}
+{{- if gt $i 0 }};{{ end }}
+method({{ .ErlName }}) ->
+  {fun {{ .InputType.ErlPackage }}:from_json_{{ .InputType.ErlName }}/1,
+   fun {{ .OutputType.ErlPackage }}:to_json_{{ .OutputType.ErlName }}/1,
+   {{ "{" }}{{ .InputType.ErlPackage }}, {{ .InputType.ErlName }}}}
+{{- end }}.
+
+-spec call(module(), atom(), [variable()], json_body(), json_body(),
+           cowboy_req:req()) ->
+        cowboy_req:req().
+call(Impl, Function, Variables, Body, ResponseBody, Req0) ->
+  {FromJSON, ToJSON, InputType} = method(Function),
+  {ok, Data, Req} = case Body of
+                      none -> {ok, <<>>, Req0};
+                      _ -> read_body(Req0)
+                    end,
+  case decode_request(Data, Variables, Body, InputType, FromJSON, Req) of
+    {ok, Request} ->
+      Ctx = ctx:with_value(ctx:new(), cowboy_req, Req),
+      try Impl:Function(Ctx, Request) of
+        {ok, Response, _} ->
+          Value = response_body(ToJSON(Response), ResponseBody),
+          cowboy_req:reply(200,
+                           #{<<"content-type">> => <<"application/json">>},
+                           json:encode(Value), Req);
+        {grpc_error, {Status, Message}} ->
+          reply_error(Status, Message, Req);
+        {grpc_extended_error, #{status := Status, message := Message}} ->
+          reply_error(Status, Message, Req)
+      catch
+        Class:Reason:Stacktrace ->
+          logger:error("~p:~p failed: ~p:~p~n~p",
+                       [Impl, Function, Class, Reason, Stacktrace]),
+          reply_error(<<"13">>, <<"internal error">>, Req)
+      end;
+    error ->
+      reply_error(<<"3">>, <<"invalid request">>, Req)
+  end.
+
+-spec read_body(cowboy_req:req()) -> {ok, binary(), cowboy_req:req()}.
+read_body(Req) ->
+  read_body(Req, <<>>).
+
+-spec read_body(cowboy_req:req(), binary()) ->
+        {ok, binary(), cowboy_req:req()}.
+read_body(Req0, Acc) ->
+  case cowboy_req:read_body(Req0) of
+    {ok, Data, Req} ->
+      {ok, <<Acc/binary, Data/binary>>, Req};
+    {more, Data, Req} ->
+      read_body(Req, <<Acc/binary, Data/binary>>)
+  end.
+
+-spec decode_request(binary(), [variable()], json_body(), {module(), atom()},
+                     fun((json:decode_value()) -> tuple()),
+                     cowboy_req:req()) ->
+        {ok, tuple()} | error.
+decode_request(Data, Variables, Body, InputType, FromJSON, Req) ->
+  try
+    Value0 = case Body of
+               none -> #{};
+               all -> json:decode(Data);
+               {field, Name} -> #{Name => json:decode(Data)}
+             end,
+    %% When the whole request message is mapped to the body, there is no
+    %% query parameter.
+    Parameters = case Body of
+                   all -> [];
+                   _ -> cowboy_req:parse_qs(Req)
+                 end,
+    Value1 = lists:foldl(fun ({Name, ParameterValue}, Acc) ->
+                             FieldPath = binary:split(Name, <<".">>,
+                                                      [global]),
+                             set_parameter(FieldPath, ParameterValue,
+                                           InputType, Acc)
+                         end, Value0, Parameters),
+    Value = lists:foldl(fun ({FieldPath, Segments}, Acc) ->
+                            VariableValue = join_segments(Segments),
+                            set_parameter(FieldPath, VariableValue,
+                                          InputType, Acc)
+                        end, Value1, Variables),
+    {ok, FromJSON(Value)}
+  catch
+    _:_ ->
+      error
+  end.
+
+-spec join_segments([binary()]) -> binary().
+join_segments(Segments) ->
+  iolist_to_binary(lists:join(<<"/">>, Segments)).
+
+%% Query parameters and path variables are string values which are
+%% converted depending on the type of the field they refer to. Fields are
+%% referenced either by protobuf name or by JSON name; query parameters which
+%% do not refer to any field are ignored.
+-spec set_parameter([binary()], binary() | true, {module(), atom()},
+                    #{binary() => json:decode_value()}) ->
+        #{binary() => json:decode_value()}.
+set_parameter([Name0], Value, {Module, MessageName}, Object) ->
+  case find_field(Module, MessageName, Name0) of
+    {ok, #{proto_name := Name, type := Type, label := Label}} ->
+      JSONValue = parameter_value(Value, Type),
+      case Label of
+        repeated ->
+          Values = maps:get(Name, Object, []),
+          Object#{Name => Values ++ [JSONValue]};
+        _ ->
+          Object#{Name => JSONValue}
+      end;
+    error ->
+      Object
+  end;
+set_parameter([Name0 | FieldPath], Value, {Module, MessageName}, Object) ->
+  case find_field(Module, MessageName, Name0) of
+    {ok, #{proto_name := Name,
+           type := {message, FieldModule, FieldMessageName},
+           label := Label}} ->
+      Label =/= repeated orelse error({repeated_field, Name}),
+      Child = set_parameter(FieldPath, Value,
+                            {FieldModule, FieldMessageName},
+                            maps:get(Name, Object, #{})),
+      Object#{Name => Child};
+    {ok, _} ->
+      error({non_message_field, Name0});
+    error ->
+      Object
+  end.
+
+-spec find_field(module(), atom(), binary()) -> {ok, map()} | error.
+find_field(Module, MessageName, Name) ->
+  Fields = Module:fields(MessageName),
+  case [F || F = #{proto_name := ProtoName, json_name := JSONName} <- Fields,
+             ProtoName =:= Name orelse JSONName =:= Name] of
+    [Field | _] ->
+      {ok, Field};
+    [] ->
+      error
+  end.
+
+-spec parameter_value(binary() | true, term()) -> json:decode_value().
+parameter_value(true, bool) ->
+  true;
+parameter_value(<<"true">>, bool) ->
+  true;
+parameter_value(<<"false">>, bool) ->
+  false;
+parameter_value(Value, _) when is_binary(Value) ->
+  Value.
+
+-spec response_body(json:encode_value(), json_body()) -> json:encode_value().
+response_body(Value, all) ->
+  Value;
+response_body(Value, {field, Name}) ->
+  maps:get(Name, Value, null).
+
+%% Errors are represented as google.rpc.Status messages.
+-spec reply_error(binary(), binary(), cowboy_req:req()) -> cowboy_req:req().
+reply_error(Status, Message, Req) ->
+  Code = binary_to_integer(Status),
+  Value = #{<<"code">> => Code,
+            <<"message">> => Message,
+            <<"details">> => []},
+  cowboy_req:reply(http_status(Code),
+                   #{<<"content-type">> => <<"application/json">>},
+                   json:encode(Value), Req).
+
+-spec http_status(non_neg_integer()) -> cowboy:http_status().
+http_status(1) -> 499;                          % CANCELLED
+http_status(2) -> 500;                          % UNKNOWN
+http_status(3) -> 400;                          % INVALID_ARGUMENT
+http_status(4) -> 504;                          % DEADLINE_EXCEEDED
+http_status(5) -> 404;                          % NOT_FOUND
+http_status(6) -> 409;                          % ALREADY_EXISTS
+http_status(7) -> 403;                          % PERMISSION_DENIED
+http_status(8) -> 429;                          % RESOURCE_EXHAUSTED
+http_status(9) -> 400;                          % FAILED_PRECONDITION
+http_status(10) -> 409;                         % ABORTED
+http_status(11) -> 400;                         % OUT_OF_RANGE
+http_status(12) -> 501;                         % UNIMPLEMENTED
+http_status(14) -> 503;                         % UNAVAILABLE
+http_status(16) -> 401;                         % UNAUTHENTICATED
+http_status(_) -> 500.                          % INTERNAL, DATA_LOSS
+`
+
+func ErlHTTPHandlerTemplate() (*template.Template, error) {
+	tpl := template.New("erl_http_handler")
+
+	if _, err := tpl.Parse(erlHTTPHandlerTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
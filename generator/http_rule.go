@@ -0,0 +1,467 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// The google.api.http extension of MethodOptions, see
+// https://github.com/googleapis/googleapis/blob/master/google/api/http.proto.
+const HTTPRuleExtensionNumber = 72295728
+
+type HTTPRule struct {
+	Method  string // e.g. "GET"
+	Pattern string // e.g. "/v1/{name=shelves/*}"
+
+	PathTemplate *HTTPPathTemplate
+
+	Body         string // empty, "*" or a field name
+	ResponseBody string // empty or a field name
+
+	BodyField         *FieldType // available after type resolution
+	ResponseBodyField *FieldType // available after type resolution
+}
+
+type HTTPRules []*HTTPRule
+
+// HTTPRulesFromMethodOptions returns the HTTP rule of a method followed by
+// its additional bindings, or nil if the method has no HTTP rule.
+func HTTPRulesFromMethodOptions(opts *descriptor.MethodOptions) (HTTPRules, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	exts, err := RawExtension(opts, HTTPRuleExtensionNumber,
+		"google.api.http")
+	if err != nil {
+		return nil, err
+	}
+
+	var rules HTTPRules
+
+	for _, ext := range exts {
+		fields, err := DecodeRawFields(ext.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode http rule: %w", err)
+		}
+
+		var rule HTTPRule
+		if err := rule.FromRawFields(fields); err != nil {
+			return nil, fmt.Errorf("invalid http rule: %w", err)
+		}
+
+		rules = append(rules, &rule)
+
+		for _, binding := range fields.Find(11) {
+			bindingFields, err := DecodeRawFields(binding.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode additional "+
+					"http binding: %w", err)
+			}
+
+			var bindingRule HTTPRule
+			if err := bindingRule.FromRawFields(bindingFields); err != nil {
+				return nil, fmt.Errorf("invalid additional http "+
+					"binding: %w", err)
+			}
+
+			rules = append(rules, &bindingRule)
+		}
+	}
+
+	return rules, nil
+}
+
+func (rule *HTTPRule) FromRawFields(fields RawFields) error {
+	r := HTTPRule{
+		Body:         fields.String(7),
+		ResponseBody: fields.String(12),
+	}
+
+	methods := map[int32]string{
+		2: "GET",
+		3: "PUT",
+		4: "POST",
+		5: "DELETE",
+		6: "PATCH",
+	}
+
+	for _, field := range fields {
+		if method, found := methods[field.Number]; found {
+			r.Method = method
+			r.Pattern = string(field.Bytes)
+		} else if field.Number == 8 {
+			customFields, err := DecodeRawFields(field.Bytes)
+			if err != nil {
+				return fmt.Errorf("cannot decode custom pattern: %w", err)
+			}
+
+			r.Method = customFields.String(1)
+			r.Pattern = customFields.String(2)
+		}
+	}
+
+	if r.Method == "" {
+		return fmt.Errorf("missing pattern")
+	}
+
+	var pt HTTPPathTemplate
+	if err := pt.Parse(r.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", r.Pattern, err)
+	}
+	r.PathTemplate = &pt
+
+	*rule = r
+	return nil
+}
+
+func (rule *HTTPRule) ResolveTypes(m *MethodType) error {
+	for _, v := range rule.PathTemplate.Variables {
+		if err := v.ResolveTypes(m.InputType); err != nil {
+			return fmt.Errorf("invalid variable %q: %w",
+				strings.Join(v.FieldPath, "."), err)
+		}
+	}
+
+	if rule.Body != "" && rule.Body != "*" {
+		rule.BodyField = m.InputType.FindField(rule.Body)
+		if rule.BodyField == nil {
+			return fmt.Errorf("unknown body field %q", rule.Body)
+		}
+	}
+
+	if rule.ResponseBody != "" {
+		rule.ResponseBodyField = m.OutputType.FindField(rule.ResponseBody)
+		if rule.ResponseBodyField == nil {
+			return fmt.Errorf("unknown response body field %q",
+				rule.ResponseBody)
+		}
+	}
+
+	return nil
+}
+
+// ErlBody returns the Erlang term describing how the request body is mapped
+// to the request message in generated HTTP handlers.
+func (rule *HTTPRule) ErlBody() string {
+	switch {
+	case rule.Body == "":
+		return "none"
+	case rule.Body == "*":
+		return "all"
+	default:
+		return "{field, " + ErlBinaryString(rule.BodyField.Name) + "}"
+	}
+}
+
+// ErlResponseBody returns the Erlang term describing how the response
+// message is mapped to the response body in generated HTTP handlers.
+func (rule *HTTPRule) ErlResponseBody() string {
+	if rule.ResponseBodyField == nil {
+		return "all"
+	}
+
+	return "{field, " + ErlBinaryString(rule.ResponseBodyField.JSONName) + "}"
+}
+
+// The syntax of path templates is:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	FieldPath = IDENT { "." IDENT } ;
+//	Verb     = ":" LITERAL ;
+type HTTPPathTemplate struct {
+	Segments  []HTTPPathSegment
+	Variables []*HTTPPathVariable
+	Verb      string
+}
+
+type HTTPPathSegment struct {
+	Literal        string // empty for wildcards
+	Wildcard       bool   // "*"
+	DoubleWildcard bool   // "**"
+}
+
+type HTTPPathVariable struct {
+	FieldPath []string
+
+	// The range of segments matched by the variable.
+	Start int
+	End   int
+}
+
+func (pt *HTTPPathTemplate) Parse(s string) error {
+	if !strings.HasPrefix(s, "/") {
+		return fmt.Errorf("missing leading '/'")
+	}
+	s = s[1:]
+
+	// The verb is introduced by the first ':' outside of variables
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '{' {
+			depth++
+		} else if s[i] == '}' {
+			depth--
+		} else if s[i] == ':' && depth == 0 {
+			pt.Verb = s[i+1:]
+			s = s[:i]
+
+			if pt.Verb == "" {
+				return fmt.Errorf("empty verb")
+			}
+		}
+	}
+
+	for len(s) > 0 {
+		if s[0] == '{' {
+			end := strings.IndexByte(s, '}')
+			if end == -1 {
+				return fmt.Errorf("unterminated variable")
+			}
+
+			if err := pt.parseVariable(s[1:end]); err != nil {
+				return err
+			}
+
+			s = s[end+1:]
+		} else {
+			end := strings.IndexByte(s, '/')
+			if end == -1 {
+				end = len(s)
+			}
+
+			if err := pt.addSegment(s[:end]); err != nil {
+				return err
+			}
+
+			s = s[end:]
+		}
+
+		if len(s) > 0 {
+			if s[0] != '/' {
+				return fmt.Errorf("invalid character %q after segment",
+					s[0])
+			}
+
+			s = s[1:]
+			if len(s) == 0 {
+				return fmt.Errorf("empty segment")
+			}
+		}
+	}
+
+	if len(pt.Segments) == 0 {
+		return fmt.Errorf("empty template")
+	}
+
+	for i, segment := range pt.Segments {
+		if segment.DoubleWildcard && i < len(pt.Segments)-1 {
+			return fmt.Errorf("'**' must be the last segment")
+		}
+	}
+
+	return nil
+}
+
+func (pt *HTTPPathTemplate) parseVariable(s string) error {
+	fieldPath, segments := s, "*"
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		fieldPath, segments = s[:i], s[i+1:]
+	}
+
+	if fieldPath == "" {
+		return fmt.Errorf("empty variable field path")
+	}
+
+	v := HTTPPathVariable{
+		FieldPath: strings.Split(fieldPath, "."),
+		Start:     len(pt.Segments),
+	}
+
+	for _, segment := range strings.Split(segments, "/") {
+		if err := pt.addSegment(segment); err != nil {
+			return fmt.Errorf("invalid variable %q: %w", fieldPath, err)
+		}
+	}
+
+	v.End = len(pt.Segments)
+
+	pt.Variables = append(pt.Variables, &v)
+	return nil
+}
+
+func (pt *HTTPPathTemplate) addSegment(s string) error {
+	var segment HTTPPathSegment
+
+	switch s {
+	case "":
+		return fmt.Errorf("empty segment")
+	case "*":
+		segment.Wildcard = true
+	case "**":
+		segment.DoubleWildcard = true
+	default:
+		if strings.ContainsAny(s, "{}=*") {
+			return fmt.Errorf("invalid segment %q", s)
+		}
+
+		segment.Literal = s
+	}
+
+	pt.Segments = append(pt.Segments, segment)
+	return nil
+}
+
+// CowboyRoute returns the cowboy path matching all requests which may
+// match the template: literal segments up to the first wildcard, followed
+// by "[...]" if the template contains wildcards.
+func (pt *HTTPPathTemplate) CowboyRoute() string {
+	var literals []string
+
+	for _, segment := range pt.Segments {
+		if segment.Literal == "" {
+			return "/" + strings.Join(append(literals, "[...]"), "/")
+		}
+
+		literals = append(literals, segment.Literal)
+	}
+
+	route := "/" + strings.Join(literals, "/")
+	if pt.Verb != "" {
+		route += ":" + pt.Verb
+	}
+
+	return route
+}
+
+// ErlSegmentsPattern returns the Erlang pattern matching the list of path
+// segments of a request.
+func (pt *HTTPPathTemplate) ErlSegmentsPattern() string {
+	return pt.erlSegments(0, len(pt.Segments))
+}
+
+// ErlVerb returns the Erlang pattern matching the verb of a request.
+func (pt *HTTPPathTemplate) ErlVerb() string {
+	if pt.Verb == "" {
+		return "undefined"
+	}
+
+	return ErlBinaryString(pt.Verb)
+}
+
+// ErlVariables returns the Erlang list associating the field path of each
+// variable with the list of path segments containing its value.
+func (pt *HTTPPathTemplate) ErlVariables() string {
+	var buf strings.Builder
+
+	buf.WriteByte('[')
+
+	for i, v := range pt.Variables {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		names := make([]string, len(v.FieldPath))
+		for j, name := range v.FieldPath {
+			names[j] = ErlBinaryString(name)
+		}
+
+		buf.WriteString("{[" + strings.Join(names, ", ") + "], ")
+		buf.WriteString(pt.erlSegments(v.Start, v.End))
+		buf.WriteByte('}')
+	}
+
+	buf.WriteByte(']')
+
+	return buf.String()
+}
+
+func (pt *HTTPPathTemplate) erlSegments(start, end int) string {
+	// A "**" segment matches the tail of the list of segments
+	if end-start == 1 && pt.Segments[start].DoubleWildcard {
+		return pt.erlSegmentVariable(start)
+	}
+
+	var buf strings.Builder
+
+	buf.WriteByte('[')
+
+	for i := start; i < end; i++ {
+		segment := pt.Segments[i]
+
+		if i > start {
+			if segment.DoubleWildcard {
+				buf.WriteString(" | ")
+			} else {
+				buf.WriteString(", ")
+			}
+		}
+
+		if segment.Literal != "" {
+			buf.WriteString(ErlBinaryString(segment.Literal))
+		} else {
+			buf.WriteString(pt.erlSegmentVariable(i))
+		}
+	}
+
+	buf.WriteByte(']')
+
+	return buf.String()
+}
+
+func (pt *HTTPPathTemplate) erlSegmentVariable(i int) string {
+	for _, v := range pt.Variables {
+		if i >= v.Start && i < v.End {
+			return "S" + strconv.Itoa(i+1)
+		}
+	}
+
+	return "_"
+}
+
+func (v *HTTPPathVariable) ResolveTypes(mt *MessageType) error {
+	for i, name := range v.FieldPath {
+		ft := mt.FindField(name)
+		if ft == nil {
+			return fmt.Errorf("unknown field %q in message type %s",
+				name, mt.FullName)
+		}
+
+		if ft.Repeated {
+			return fmt.Errorf("field %q is repeated", name)
+		}
+
+		if i < len(v.FieldPath)-1 {
+			if ft.MessageType == nil {
+				return fmt.Errorf("field %q is not a message field",
+					name)
+			}
+
+			mt = ft.MessageType
+		} else if ft.MessageType != nil && ft.MessageType.WellKnownType == "" {
+			return fmt.Errorf("field %q is a message field", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHTTPPathTemplateParse(t *testing.T) {
+	lit := func(s string) HTTPPathSegment {
+		return HTTPPathSegment{Literal: s}
+	}
+	wildcard := HTTPPathSegment{Wildcard: true}
+	doubleWildcard := HTTPPathSegment{DoubleWildcard: true}
+
+	tests := []struct {
+		s  string
+		pt HTTPPathTemplate
+	}{
+		{"/v1",
+			HTTPPathTemplate{
+				Segments: []HTTPPathSegment{lit("v1")},
+			}},
+		{"/v1/shelves/*",
+			HTTPPathTemplate{
+				Segments: []HTTPPathSegment{
+					lit("v1"), lit("shelves"), wildcard},
+			}},
+		{"/v1/**:verb",
+			HTTPPathTemplate{
+				Segments: []HTTPPathSegment{lit("v1"), doubleWildcard},
+				Verb:     "verb",
+			}},
+		{"/v1/shelves/{shelf}",
+			HTTPPathTemplate{
+				Segments: []HTTPPathSegment{
+					lit("v1"), lit("shelves"), wildcard},
+				Variables: []*HTTPPathVariable{
+					{FieldPath: []string{"shelf"}, Start: 2, End: 3}},
+			}},
+		{"/v1/{name=shelves/*/books/**}",
+			HTTPPathTemplate{
+				Segments: []HTTPPathSegment{
+					lit("v1"), lit("shelves"), wildcard, lit("books"),
+					doubleWildcard},
+				Variables: []*HTTPPathVariable{
+					{FieldPath: []string{"name"}, Start: 1, End: 5}},
+			}},
+		{"/v1/{book.shelf}/{book.id}:read",
+			HTTPPathTemplate{
+				Segments: []HTTPPathSegment{lit("v1"), wildcard, wildcard},
+				Variables: []*HTTPPathVariable{
+					{FieldPath: []string{"book", "shelf"}, Start: 1, End: 2},
+					{FieldPath: []string{"book", "id"}, Start: 2, End: 3}},
+				Verb: "read",
+			}},
+	}
+
+	for _, test := range tests {
+		var pt HTTPPathTemplate
+		if err := pt.Parse(test.s); err != nil {
+			t.Errorf("%q: cannot parse template: %v", test.s, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(pt, test.pt) {
+			t.Errorf("%q: parsed %#v but expected %#v", test.s, pt, test.pt)
+		}
+	}
+}
+
+func TestHTTPPathTemplateParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"v1",
+		"/",
+		"/v1/",
+		"/v1//shelves",
+		"/v1:",
+		"/v1/{shelf",
+		"/v1/{}",
+		"/v1/{=shelves}",
+		"/v1/**/shelves",
+		"/v1/shel*ves",
+		"/v1/{shelf}x",
+	}
+
+	for _, s := range tests {
+		var pt HTTPPathTemplate
+		if err := pt.Parse(s); err == nil {
+			t.Errorf("%q: parsed invalid template", s)
+		}
+	}
+}
+
+func TestHTTPPathTemplateCowboyRoute(t *testing.T) {
+	tests := []struct {
+		s     string
+		route string
+	}{
+		{"/v1", "/v1"},
+		{"/v1/shelves:list", "/v1/shelves:list"},
+		{"/v1/shelves/*", "/v1/shelves/[...]"},
+		{"/v1/{name=shelves/*}:read", "/v1/shelves/[...]"},
+		{"/v1/**", "/v1/[...]"},
+		{"/{shelf}/books", "/[...]"},
+	}
+
+	for _, test := range tests {
+		var pt HTTPPathTemplate
+		if err := pt.Parse(test.s); err != nil {
+			t.Errorf("%q: cannot parse template: %v", test.s, err)
+			continue
+		}
+
+		if route := pt.CowboyRoute(); route != test.route {
+			t.Errorf("%q: route is %q but should be %q",
+				test.s, route, test.route)
+		}
+	}
+}
+
+func TestHTTPPathTemplateErlSegmentsPattern(t *testing.T) {
+	tests := []struct {
+		s       string
+		pattern string
+	}{
+		{"/v1", `[<<"v1">>]`},
+		{"/v1/shelves/*", `[<<"v1">>, <<"shelves">>, _]`},
+		{"/v1/**", `[<<"v1">> | _]`},
+		{"/**", `_`},
+		{"/v1/{shelf}", `[<<"v1">>, S2]`},
+		{"/v1/{name=shelves/*}", `[<<"v1">>, <<"shelves">>, S3]`},
+		{"/v1/{name=shelves/**}", `[<<"v1">>, <<"shelves">> | S3]`},
+		{"/{name=**}", `S1`},
+		{"/v1/\"x\"", `[<<"v1">>, <<"\"x\"">>]`},
+	}
+
+	for _, test := range tests {
+		var pt HTTPPathTemplate
+		if err := pt.Parse(test.s); err != nil {
+			t.Errorf("%q: cannot parse template: %v", test.s, err)
+			continue
+		}
+
+		if pattern := pt.ErlSegmentsPattern(); pattern != test.pattern {
+			t.Errorf("%q: pattern is %q but should be %q",
+				test.s, pattern, test.pattern)
+		}
+	}
+}
@@ -0,0 +1,502 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+// JSON functions implement the proto3 JSON mapping on top of the JSON
+// terms used by the json module of OTP 27: maps with binary keys, lists,
+// binaries, numbers, booleans and null.
+//
+// The JSON mapping of google.protobuf.Any depends on the type of the packed
+// message, which is only known at runtime: the generator rejects fields of
+// this type, and JSON functions of the Any message itself signal an error.
+var erlJSONTemplateContent = `
+{{- define "erl_enum_json" }}
+-spec to_json_{{ .ErlName }}({{ .ErlTypeName }}()) -> {{ if eq .WellKnownType "NullValue" }}null{{ else }}binary(){{ end }}.
+{{- if eq .WellKnownType "NullValue" }}
+to_json_{{ .ErlName }}(_) ->
+  null.
+{{- else }}
+to_json_{{ .ErlName }}(Value) ->
+  {{ .ErlName }}_name(Value).
+{{- end }}
+
+-spec from_json_{{ .ErlName }}(binary() | integer() | null) ->
+        {{ .ErlTypeName }}() | integer().
+{{- $et := . }}
+from_json_{{ .ErlName }}(Number) when is_integer(Number) ->
+  try
+    int_to_{{ .ErlName }}(Number)
+  catch
+    error:function_clause ->
+      Number
+  end;
+{{- if eq .WellKnownType "NullValue" }}
+from_json_{{ .ErlName }}(null) ->
+  {{ (index .Values 0).ErlName }};
+{{- end }}
+{{- range .Values }}
+from_json_{{ $et.ErlName }}(<<"{{ .Name }}">>) ->
+  {{ .ErlName }};
+{{- end }}
+from_json_{{ .ErlName }}(Value) ->
+  error({invalid_json_value, Value, {{ .ErlName }}}).
+{{- end }}
+
+{{- define "erl_message_json_generic" }}
+-spec to_json_{{ .ErlName }}({{ .ErlTypeName }}()) -> #{binary() => term()}.
+{{- if .Fields }}
+to_json_{{ .ErlName }}(Message) ->
+  json_object(
+    [
+    {{- $mt := . }}
+    {{- $first := true }}
+    {{- range .Fields }}
+    {{- if not .OneofType }}
+    {{- if $first }}{{ $first = false }}{{ else }},
+     {{ end }}
+    {{- "" }}{ {{- .ErlJSONName }}, Message#{{ $mt.ErlName }}.{{ .ErlName }},
+      {{ .ErlJSONType }}}
+    {{- end }}
+    {{- end }}],
+    [
+    {{- range $i, $o := .Oneofs }}
+    {{- if gt $i 0 }},
+     {{ end }}
+    {{- "" }}json_oneof(Message#{{ $mt.ErlName }}.{{ $o.ErlName }},
+      [
+      {{- range $j, $f := $o.Fields }}
+      {{- if gt $j 0 }},
+       {{ end }}
+      {{- "" }}{ {{- $f.ErlName }}, {{ $f.ErlJSONName }}, {{ $f.ErlJSONType }}}
+      {{- end }}])
+    {{- end }}]).
+{{- else }}
+to_json_{{ .ErlName }}(_) ->
+  #{}.
+{{- end }}
+
+-spec from_json_{{ .ErlName }}(#{binary() => term()}) -> {{ .ErlTypeName }}().
+from_json_{{ .ErlName }}(Json) when is_map(Json) ->
+  #{{ .ErlName }}{
+  {{- $first := true }}
+  {{- range .Fields }}
+  {{- if not .OneofType }}
+  {{- if $first }}{{ $first = false }}{{ else }},{{ end }}
+    {{ .ErlName }} = json_field(Json, {{ .ErlJSONKeys }},
+      {{ .ErlJSONType }},
+      {{ .ErlDefaultValue }})
+  {{- end }}
+  {{- end }}
+  {{- range .Oneofs }}
+  {{- if $first }}{{ $first = false }}{{ else }},{{ end }}
+    {{ .ErlName }} = json_oneof_field(Json,
+      [
+      {{- range $j, $f := .Fields }}
+      {{- if gt $j 0 }},
+       {{ end }}
+      {{- "" }}{ {{- $f.ErlName }}, {{ $f.ErlJSONKeys }}, {{ $f.ErlJSONType }}}
+      {{- end }}])
+  {{- end }}};
+from_json_{{ .ErlName }}(Json) ->
+  error({invalid_json_value, Json, {{ .ErlName }}}).
+{{- end }}
+
+{{- define "erl_message_json_unwrapped" }}
+{{- $f := index .Fields 0 }}
+-spec to_json_{{ .ErlName }}({{ .ErlTypeName }}()) -> term().
+to_json_{{ .ErlName }}(#{{ .ErlName }}{ {{- $f.ErlName }} = Value}) ->
+  json_encode_value(Value, {{ $f.ErlJSONType }}).
+
+-spec from_json_{{ .ErlName }}(term()) -> {{ .ErlTypeName }}().
+from_json_{{ .ErlName }}(Json) ->
+  #{{ .ErlName }}{ {{- $f.ErlName }} = json_decode_value(Json, {{ $f.ErlJSONType }})}.
+{{- end }}
+
+{{- define "erl_message_json_timestamp" }}
+-spec to_json_{{ .ErlName }}({{ .ErlTypeName }}()) -> binary().
+to_json_{{ .ErlName }}(#{{ .ErlName }}{seconds = Seconds, nanos = Nanos}) ->
+  Time = Seconds * 1000000000 + Nanos,
+  Options = [{unit, nanosecond}, {offset, "Z"}],
+  list_to_binary(calendar:system_time_to_rfc3339(Time, Options)).
+
+-spec from_json_{{ .ErlName }}(binary()) -> {{ .ErlTypeName }}().
+from_json_{{ .ErlName }}(Json) when is_binary(Json) ->
+  Options = [{unit, nanosecond}],
+  Time = calendar:rfc3339_to_system_time(binary_to_list(Json), Options),
+  Nanos = (Time rem 1000000000 + 1000000000) rem 1000000000,
+  #{{ .ErlName }}{seconds = (Time - Nanos) div 1000000000, nanos = Nanos};
+from_json_{{ .ErlName }}(Json) ->
+  error({invalid_json_value, Json, {{ .ErlName }}}).
+{{- end }}
+
+{{- define "erl_message_json_duration" }}
+-spec to_json_{{ .ErlName }}({{ .ErlTypeName }}()) -> binary().
+to_json_{{ .ErlName }}(#{{ .ErlName }}{seconds = Seconds, nanos = Nanos}) ->
+  Sign = case Seconds < 0 orelse Nanos < 0 of
+           true -> <<"-">>;
+           false -> <<>>
+         end,
+  Fraction = case Nanos of
+               0 -> <<>>;
+               _ -> iolist_to_binary(io_lib:format(".~9..0B", [abs(Nanos)]))
+             end,
+  <<Sign/binary, (integer_to_binary(abs(Seconds)))/binary,
+    Fraction/binary, "s">>.
+
+-spec from_json_{{ .ErlName }}(binary()) -> {{ .ErlTypeName }}().
+from_json_{{ .ErlName }}(Json) when is_binary(Json) ->
+  Size = byte_size(Json) - 1,
+  <<Value:Size/binary, "s">> = Json,
+  {Sign, AbsValue} = case Value of
+                       <<"-", Rest/binary>> -> {-1, Rest};
+                       _ -> {1, Value}
+                     end,
+  {Seconds, Nanos} =
+    case binary:split(AbsValue, <<".">>) of
+      [Integer] ->
+        {binary_to_integer(Integer), 0};
+      [Integer, Fraction] ->
+        Fraction2 = binary:part(<<Fraction/binary, "000000000">>, 0, 9),
+        {binary_to_integer(Integer), binary_to_integer(Fraction2)}
+    end,
+  #{{ .ErlName }}{seconds = Sign * Seconds, nanos = Sign * Nanos};
+from_json_{{ .ErlName }}(Json) ->
+  error({invalid_json_value, Json, {{ .ErlName }}}).
+{{- end }}
+
+{{- define "erl_message_json_field_mask" }}
+-spec to_json_{{ .ErlName }}({{ .ErlTypeName }}()) -> binary().
+to_json_{{ .ErlName }}(#{{ .ErlName }}{paths = Paths}) ->
+  Paths2 = [json_lower_camel_case(unicode:characters_to_binary(Path))
+            || Path <- Paths],
+  iolist_to_binary(lists:join(<<",">>, Paths2)).
+
+-spec from_json_{{ .ErlName }}(binary()) -> {{ .ErlTypeName }}().
+from_json_{{ .ErlName }}(Json) when is_binary(Json) ->
+  Paths = [json_snake_case(Path)
+           || Path <- binary:split(Json, <<",">>, [global]),
+              Path =/= <<>>],
+  #{{ .ErlName }}{paths = Paths};
+from_json_{{ .ErlName }}(Json) ->
+  error({invalid_json_value, Json, {{ .ErlName }}}).
+
+-spec json_lower_camel_case(binary()) -> binary().
+json_lower_camel_case(<<"_", C, Rest/binary>>) when C >= $a, C =< $z ->
+  <<(C - $a + $A), (json_lower_camel_case(Rest))/binary>>;
+json_lower_camel_case(<<C, Rest/binary>>) ->
+  <<C, (json_lower_camel_case(Rest))/binary>>;
+json_lower_camel_case(<<>>) ->
+  <<>>.
+
+-spec json_snake_case(binary()) -> binary().
+json_snake_case(<<C, Rest/binary>>) when C >= $A, C =< $Z ->
+  <<"_", (C - $A + $a), (json_snake_case(Rest))/binary>>;
+json_snake_case(<<C, Rest/binary>>) ->
+  <<C, (json_snake_case(Rest))/binary>>;
+json_snake_case(<<>>) ->
+  <<>>.
+{{- end }}
+
+{{- define "erl_message_json_value" }}
+-spec to_json_{{ .ErlName }}({{ .ErlTypeName }}()) -> term().
+to_json_{{ .ErlName }}(#{{ .ErlName }}{kind = Kind}) ->
+  case Kind of
+    {null_value, _} -> null;
+    {number_value, Number} -> Number;
+    {string_value, String} -> unicode:characters_to_binary(String);
+    {bool_value, Boolean} -> Boolean;
+    {struct_value, Struct} -> to_json_struct(Struct);
+    {list_value, List} -> to_json_list_value(List);
+    undefined -> null
+  end.
+
+-spec from_json_{{ .ErlName }}(term()) -> {{ .ErlTypeName }}().
+from_json_{{ .ErlName }}(null) ->
+  #{{ .ErlName }}{kind = {null_value, null_value}};
+from_json_{{ .ErlName }}(Json) when is_boolean(Json) ->
+  #{{ .ErlName }}{kind = {bool_value, Json}};
+from_json_{{ .ErlName }}(Json) when is_number(Json) ->
+  #{{ .ErlName }}{kind = {number_value, float(Json)}};
+from_json_{{ .ErlName }}(Json) when is_binary(Json) ->
+  #{{ .ErlName }}{kind = {string_value, Json}};
+from_json_{{ .ErlName }}(Json) when is_map(Json) ->
+  #{{ .ErlName }}{kind = {struct_value, from_json_struct(Json)}};
+from_json_{{ .ErlName }}(Json) when is_list(Json) ->
+  #{{ .ErlName }}{kind = {list_value, from_json_list_value(Json)}}.
+{{- end }}
+
+{{- define "erl_message_json_any" }}
+-spec to_json_{{ .ErlName }}({{ .ErlTypeName }}()) -> no_return().
+to_json_{{ .ErlName }}(Message) ->
+  error({unsupported_json_mapping, Message, {{ .ErlName }}}).
+
+-spec from_json_{{ .ErlName }}(term()) -> no_return().
+from_json_{{ .ErlName }}(Json) ->
+  error({unsupported_json_mapping, Json, {{ .ErlName }}}).
+{{- end }}
+
+{{- define "erl_message_json" }}
+{{- if eq .WellKnownType "Any" }}
+{{- template "erl_message_json_any" . }}
+{{- else if eq .WellKnownType "Timestamp" }}
+{{- template "erl_message_json_timestamp" . }}
+{{- else if eq .WellKnownType "Duration" }}
+{{- template "erl_message_json_duration" . }}
+{{- else if eq .WellKnownType "FieldMask" }}
+{{- template "erl_message_json_field_mask" . }}
+{{- else if eq .WellKnownType "Value" }}
+{{- template "erl_message_json_value" . }}
+{{- else if .JSONUnwrapped }}
+{{- template "erl_message_json_unwrapped" . }}
+{{- else }}
+{{- template "erl_message_json_generic" . }}
+{{- end }}
+{{- end }}
+
+{{- define "erl_json_support" }}
+-spec json_object([{binary(), term(), term()}],
+                  [[{binary(), term(), term()}]]) ->
+        #{binary() => term()}.
+json_object(Fields, Oneofs) ->
+  Pairs = [{Key, json_encode_value(Value, Type)}
+           || {Key, Value, Type} <- Fields,
+              not json_is_default(Value, Type)],
+  OneofPairs = [{Key, json_encode_value(Value, Type)}
+                || {Key, Value, Type} <- lists:append(Oneofs)],
+  maps:from_list(Pairs ++ OneofPairs).
+
+-spec json_oneof(undefined | {atom(), term()}, [{atom(), binary(), term()}]) ->
+        [{binary(), term(), term()}].
+json_oneof(undefined, _) ->
+  [];
+json_oneof({Case, Value}, Cases) ->
+  {Case, Key, Type} = lists:keyfind(Case, 1, Cases),
+  [{Key, Value, Type}].
+
+-spec json_is_default(term(), term()) -> boolean().
+json_is_default(Value, {repeated, _}) ->
+  Value =:= [];
+json_is_default(Value, {map, _, _, _}) ->
+  Value =:= [];
+json_is_default(Value, {erl_map, _, _}) ->
+  map_size(Value) =:= 0;
+json_is_default(Value, {message, _, _}) ->
+  Value =:= undefined;
+json_is_default(Value, {enum, _, _, Default}) ->
+  Value =:= Default;
+json_is_default(Value, bool) ->
+  Value =:= false;
+json_is_default(Value, string) ->
+  unicode:characters_to_binary(Value) =:= <<>>;
+json_is_default(Value, {string, list}) ->
+  Value =:= [];
+json_is_default(Value, bytes) ->
+  iolist_size(Value) =:= 0;
+json_is_default(Value, _) ->
+  Value == 0.
+
+-spec json_encode_value(term(), term()) -> term().
+json_encode_value(Value, Type) when Type =:= int64; Type =:= uint64;
+                                    Type =:= sint64; Type =:= fixed64;
+                                    Type =:= sfixed64 ->
+  integer_to_binary(Value);
+json_encode_value(Value, string) ->
+  unicode:characters_to_binary(Value);
+json_encode_value(Value, {string, list}) ->
+  unicode:characters_to_binary(Value);
+json_encode_value(Value, bytes) ->
+  base64:encode(iolist_to_binary(Value));
+json_encode_value(Value, {enum, _, _, _}) when is_integer(Value) ->
+  Value;
+json_encode_value(Value, {enum, Encode, _, _}) ->
+  Encode(Value);
+json_encode_value(Value, {message, Encode, _}) ->
+  Encode(Value);
+json_encode_value(Values, {repeated, Type}) ->
+  [json_encode_value(Value, Type) || Value <- Values];
+json_encode_value(Entries, {map, _, KeyType, ValueType}) ->
+  maps:from_list([{json_encode_map_key(element(2, Entry), KeyType),
+                   json_encode_value(element(3, Entry), ValueType)}
+                  || Entry <- Entries]);
+json_encode_value(Map, {erl_map, KeyType, ValueType}) ->
+  maps:from_list([{json_encode_map_key(Key, KeyType),
+                   json_encode_value(Value, ValueType)}
+                  || {Key, Value} <- maps:to_list(Map)]);
+json_encode_value(Value, Type) when Type =:= float; Type =:= double ->
+  json_encode_float(Value);
+json_encode_value(Value, _) ->
+  Value.
+
+%% Non-finite values are encoded as strings.
+-spec json_encode_float(float() | infinity | '-infinity' | nan) ->
+        float() | binary().
+json_encode_float(infinity) ->
+  <<"Infinity">>;
+json_encode_float('-infinity') ->
+  <<"-Infinity">>;
+json_encode_float(nan) ->
+  <<"NaN">>;
+json_encode_float(Value) ->
+  Value.
+
+-spec json_encode_map_key(term(), term()) -> binary().
+json_encode_map_key(Key, string) ->
+  unicode:characters_to_binary(Key);
+json_encode_map_key(Key, {string, list}) ->
+  unicode:characters_to_binary(Key);
+json_encode_map_key(Key, bool) ->
+  atom_to_binary(Key);
+json_encode_map_key(Key, _) ->
+  integer_to_binary(Key).
+
+-spec json_field(#{binary() => term()}, [binary()], term(), term()) ->
+        term().
+json_field(Json, Keys, Type, Default) ->
+  case json_find(Json, Keys) of
+    {ok, null} ->
+      Default;
+    {ok, Value} ->
+      json_decode_value(Value, Type);
+    error ->
+      Default
+  end.
+
+-spec json_oneof_field(#{binary() => term()},
+                       [{atom(), [binary()], term()}]) ->
+        undefined | {atom(), term()}.
+json_oneof_field(_, []) ->
+  undefined;
+json_oneof_field(Json, [{Case, Keys, Type} | Cases]) ->
+  case json_find(Json, Keys) of
+    {ok, Value} when Value =/= null ->
+      {Case, json_decode_value(Value, Type)};
+    _ ->
+      json_oneof_field(Json, Cases)
+  end.
+
+-spec json_find(#{binary() => term()}, [binary()]) -> {ok, term()} | error.
+json_find(_, []) ->
+  error;
+json_find(Json, [Key | Keys]) ->
+  case maps:find(Key, Json) of
+    {ok, Value} ->
+      {ok, Value};
+    error ->
+      json_find(Json, Keys)
+  end.
+
+-spec json_decode_value(term(), term()) -> term().
+json_decode_value(Value, bool) when is_boolean(Value) ->
+  Value;
+json_decode_value(Value, Type) when Type =:= int32; Type =:= int64;
+                                    Type =:= uint32; Type =:= uint64;
+                                    Type =:= sint32; Type =:= sint64;
+                                    Type =:= fixed32; Type =:= fixed64;
+                                    Type =:= sfixed32; Type =:= sfixed64 ->
+  json_decode_integer(Value);
+json_decode_value(Value, Type) when Type =:= float; Type =:= double ->
+  json_decode_float(Value);
+json_decode_value(Value, string) when is_binary(Value) ->
+  Value;
+json_decode_value(Value, {string, list}) when is_binary(Value) ->
+  unicode:characters_to_list(Value);
+json_decode_value(Value, bytes) when is_binary(Value) ->
+  json_decode_base64(Value);
+json_decode_value(Value, {enum, _, Decode, _}) ->
+  Decode(Value);
+json_decode_value(Value, {message, _, Decode}) ->
+  Decode(Value);
+json_decode_value(Values, {repeated, Type}) when is_list(Values) ->
+  [json_decode_value(Value, Type) || Value <- Values];
+json_decode_value(Object, {map, EntryName, KeyType, ValueType})
+  when is_map(Object) ->
+  [{EntryName, json_decode_map_key(Key, KeyType),
+    json_decode_value(Value, ValueType)}
+   || {Key, Value} <- lists:sort(maps:to_list(Object))];
+json_decode_value(Object, {erl_map, KeyType, ValueType}) when is_map(Object) ->
+  maps:from_list([{json_decode_map_key(Key, KeyType),
+                   json_decode_value(Value, ValueType)}
+                  || {Key, Value} <- maps:to_list(Object)]);
+json_decode_value(Value, Type) ->
+  error({invalid_json_value, Value, Type}).
+
+-spec json_decode_integer(term()) -> integer().
+json_decode_integer(Value) when is_integer(Value) ->
+  Value;
+json_decode_integer(Value) when is_float(Value), Value == trunc(Value) ->
+  trunc(Value);
+json_decode_integer(Value) when is_binary(Value) ->
+  binary_to_integer(Value);
+json_decode_integer(Value) ->
+  error({invalid_json_value, Value, integer}).
+
+%% Numbers can be encoded as strings, using any JSON number syntax, e.g.
+%% "1e5".
+-spec json_decode_float(term()) -> float() | infinity | '-infinity' | nan.
+json_decode_float(Value) when is_float(Value) ->
+  Value;
+json_decode_float(Value) when is_integer(Value) ->
+  float(Value);
+json_decode_float(<<"Infinity">>) ->
+  infinity;
+json_decode_float(<<"-Infinity">>) ->
+  '-infinity';
+json_decode_float(<<"NaN">>) ->
+  nan;
+json_decode_float(Value) when is_binary(Value) ->
+  try json:decode(Value) of
+    Number when is_number(Number) ->
+      float(Number);
+    _ ->
+      error({invalid_json_value, Value, float})
+  catch
+    error:_ ->
+      error({invalid_json_value, Value, float})
+  end;
+json_decode_float(Value) ->
+  error({invalid_json_value, Value, float}).
+
+-spec json_decode_map_key(binary(), term()) -> term().
+json_decode_map_key(Key, string) ->
+  Key;
+json_decode_map_key(Key, {string, list}) ->
+  unicode:characters_to_list(Key);
+json_decode_map_key(<<"true">>, bool) ->
+  true;
+json_decode_map_key(<<"false">>, bool) ->
+  false;
+json_decode_map_key(Key, _) ->
+  binary_to_integer(Key).
+
+%% Proto3 JSON parsers must accept both the standard and the URL-safe
+%% base64 alphabets, with or without padding.
+-spec json_decode_base64(binary()) -> binary().
+json_decode_base64(Value) ->
+  Value2 = << <<(case C of $- -> $+; $_ -> $/; _ -> C end)>>
+              || <<C>> <= Value >>,
+  Padding = case byte_size(Value2) rem 4 of
+              0 -> <<>>;
+              1 -> error({invalid_json_value, Value, bytes});
+              2 -> <<"==">>;
+              3 -> <<"=">>
+            end,
+  try
+    base64:decode(<<Value2/binary, Padding/binary>>)
+  catch
+    error:_ ->
+      error({invalid_json_value, Value, bytes})
+  end.
+{{- end }}
+`
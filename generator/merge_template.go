@@ -0,0 +1,95 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+// Merge functions follow the semantics of merging encoded messages: the
+// result of merge_foo(A, B) is equal to decoding the concatenation of the
+// encodings of A and B. Since records do not track the presence of scalar
+// fields, optional scalars of the source are only merged when they are not
+// equal to their default value. Unknown fields of the source are appended to
+// those of the target.
+var erlMergeTemplateContent = `
+{{- define "erl_message_merge" }}
+-spec merge_{{ .ErlName }}({{ .ErlTypeName }}(), {{ .ErlTypeName }}()) ->
+        {{ .ErlTypeName }}().
+{{- $mt := . }}
+merge_{{ .ErlName }}(Target, Source) ->
+  pb_merge_fields(
+    Target, Source,
+    [
+    {{- $first := true }}
+    {{- range .Fields }}
+    {{- if not .OneofType }}
+    {{- if $first }}{{ $first = false }}{{ else }},
+     {{ end }}
+    {{- "" }}{#{{ $mt.ErlName }}.{{ .ErlName }}, {{ .ErlMergeType }}}
+    {{- end }}
+    {{- end }}
+    {{- range .Oneofs }}
+    {{- if $first }}{{ $first = false }}{{ else }},
+     {{ end }}
+    {{- "" }}{#{{ $mt.ErlName }}.{{ .ErlName }}, {{ .ErlMergeType }}}
+    {{- end }}
+    {{- if not $first }},
+     {{ end }}
+    {{- "" }}{#{{ $mt.ErlName }}.'$unknown', repeated}]).
+{{- end }}
+
+{{- define "erl_merge_support" }}
+-spec pb_merge_fields(tuple(), tuple(), [{pos_integer(), term()}]) -> tuple().
+pb_merge_fields(Target, Source, Fields) ->
+  lists:foldl(fun ({Pos, Type}, Message) ->
+                  Value = pb_merge_value(element(Pos, Message),
+                                         element(Pos, Source), Type),
+                  setelement(Pos, Message, Value)
+              end, Target, Fields).
+
+-spec pb_merge_value(term(), term(), term()) -> term().
+pb_merge_value(_, Source, replace) ->
+  Source;
+pb_merge_value(Target, Source, {optional, Type, Default}) ->
+  case pb_is_default(Source, Type, Default) of
+    true ->
+      Target;
+    false ->
+      Source
+  end;
+pb_merge_value(Target, Source, repeated) ->
+  Target ++ Source;
+pb_merge_value(Target, Source, map) ->
+  maps:merge(Target, Source);
+pb_merge_value(Target, Source, map_entries) ->
+  %% Entries are records whose first field is the key
+  [Entry || Entry <- Target,
+            not lists:keymember(element(2, Entry), 2, Source)] ++ Source;
+pb_merge_value(Target, undefined, {message, _}) ->
+  Target;
+pb_merge_value(undefined, Source, {message, _}) ->
+  Source;
+pb_merge_value(Target, Source, {message, Merge}) ->
+  Merge(Target, Source);
+pb_merge_value(Target, undefined, {oneof, _}) ->
+  Target;
+pb_merge_value({Case, Target}, {Case, Source}, {oneof, Cases}) ->
+  case lists:keyfind(Case, 1, Cases) of
+    {Case, Merge} ->
+      {Case, Merge(Target, Source)};
+    false ->
+      {Case, Source}
+  end;
+pb_merge_value(_, Source, {oneof, _}) ->
+  Source.
+{{- end }}
+`
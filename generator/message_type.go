@@ -29,11 +29,23 @@ type MessageType struct {
 	FullName     string
 	AbsoluteName string
 
-	ErlPackage string
-	ErlName    string
+	MapEntry   bool
+	Deprecated bool
+
+	// The name of the message for well-known types of the google.protobuf
+	// package, or an empty string for all other types.
+	WellKnownType string
+
+	ErlPackage  string
+	ErlName     string
+	ErlTypeName string
 
 	Oneofs OneofTypes
 	Fields FieldTypes
+
+	Comment *Comment
+
+	VerifyOnEncode bool // set by the generator for the verify_encode option
 }
 
 type MessageTypes []*MessageType
@@ -44,13 +56,33 @@ func (messageType *MessageType) FromDescriptor(fd *descriptor.FileDescriptorProt
 
 		Package: fd.GetPackage(),
 		Name:    d.GetName(),
+
+		MapEntry:   d.GetOptions().GetMapEntry(),
+		Deprecated: d.GetOptions().GetDeprecated(),
 	}
 
 	mt.FullName = MessageTypeFullName(&mt)
+
+	if mt.Package == "google.protobuf" {
+		mt.WellKnownType = mt.FullName
+	}
 	mt.AbsoluteName = "." + mt.Package + "." + mt.FullName
 
-	mt.ErlPackage = ProtoPackageNameToErlModuleName(mt.Package)
-	mt.ErlName = MessageTypeFullNameToErlRecordName(mt.FullName)
+	erlPackage, err := ErlFileModuleName(fd)
+	if err != nil {
+		return err
+	}
+
+	erlName, err := ErlMessageRecordNameOption(d.GetOptions())
+	if err != nil {
+		return err
+	} else if erlName == "" {
+		erlName = MessageTypeFullNameToErlRecordName(mt.FullName)
+	}
+
+	mt.ErlPackage = erlPackage
+	mt.ErlName = erlName
+	mt.ErlTypeName = ErlTypeName(erlName, "message")
 
 	for _, od := range d.OneofDecl {
 		var ot OneofType
@@ -62,6 +94,8 @@ func (messageType *MessageType) FromDescriptor(fd *descriptor.FileDescriptorProt
 		mt.Oneofs = append(mt.Oneofs, &ot)
 	}
 
+	proto3 := fd.GetSyntax() == "proto3"
+
 	for _, fid := range d.Field {
 		var ft FieldType
 		if err := ft.FromDescriptor(fid); err != nil {
@@ -69,6 +103,17 @@ func (messageType *MessageType) FromDescriptor(fd *descriptor.FileDescriptorProt
 				fid.GetName(), err)
 		}
 
+		if ft.Repeated && ft.TypeId.IsPackable() {
+			// Repeated scalar fields are packed by default in proto3
+			if opts := fid.GetOptions(); opts != nil && opts.Packed != nil {
+				ft.Packed = opts.GetPacked()
+			} else {
+				ft.Packed = proto3
+			}
+		}
+
+		ft.ValidateUTF8 = proto3 && ft.TypeId == FieldTypeIdString
+
 		if fid.OneofIndex != nil {
 			idx := fid.GetOneofIndex()
 			if int(idx) >= len(mt.Oneofs) {
@@ -126,3 +171,73 @@ func MessageTypeFullNameToErlRecordName(name string) string {
 	name2 := strings.ReplaceAll(name, ".", "_")
 	return CamelCaseToSnakeCase(name2)
 }
+
+// FindField returns the field with a specific name, or nil if there is no
+// such field.
+func (mt *MessageType) FindField(name string) *FieldType {
+	for _, ft := range mt.Fields {
+		if ft.Name == name {
+			return ft
+		}
+	}
+
+	return nil
+}
+
+// MapEntryFields returns the key and value fields of a map entry message
+// type.
+func (mt *MessageType) MapEntryFields() (*FieldType, *FieldType) {
+	var key, value *FieldType
+
+	for _, ft := range mt.Fields {
+		switch ft.Number {
+		case 1:
+			key = ft
+		case 2:
+			value = ft
+		}
+	}
+
+	return key, value
+}
+
+// JSONUnwrapped returns true for well-known types whose JSON representation
+// is the JSON representation of their only field.
+func (mt *MessageType) JSONUnwrapped() bool {
+	switch mt.WellKnownType {
+	case "DoubleValue", "FloatValue", "Int64Value", "UInt64Value",
+		"Int32Value", "UInt32Value", "BoolValue", "StringValue",
+		"BytesValue", "Struct", "ListValue":
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidatedFields returns the fields checked by the generated validation
+// function of the message type.
+func (mt *MessageType) ValidatedFields() FieldTypes {
+	var fts FieldTypes
+
+	for _, ft := range mt.Fields {
+		if ft.Validated() {
+			fts = append(fts, ft)
+		}
+	}
+
+	return fts
+}
+
+// ErlVerifyFields returns the fields which are not part of a oneof, in the
+// order they are checked by generated verification functions.
+func (mt *MessageType) ErlVerifyFields() FieldTypes {
+	var fts FieldTypes
+
+	for _, ft := range mt.Fields {
+		if ft.OneofType == nil {
+			fts = append(fts, ft)
+		}
+	}
+
+	return fts
+}
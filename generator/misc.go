@@ -49,3 +49,25 @@ func CamelCaseToSnakeCase(s string) string {
 
 	return buf.String()
 }
+
+func SnakeCaseToLowerCamelCase(s string) string {
+	var buf bytes.Buffer
+
+	upper := false
+
+	for _, c := range []byte(s) {
+		if c == '_' {
+			upper = true
+			continue
+		}
+
+		if upper && c >= 'a' && c <= 'z' {
+			c = c - 'a' + 'A'
+		}
+		upper = false
+
+		buf.WriteByte(c)
+	}
+
+	return buf.String()
+}
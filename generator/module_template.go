@@ -22,20 +22,54 @@ import (
 var erlModuleTemplateContent = `
 {{- define "erl_enum" }}
 %% Generated for enum type {{ .FullName }}.
--type {{ .ErlName }}() ::{{ range $i, $v := .Values }}{{ if gt $i 0 }} |{{ end}} {{ .ErlName }}{{ end }}.
+{{ with .Comment }}{{ .ErlTypeDoc }}
+{{ end }}-type {{ .ErlTypeName }}() ::{{ range $i, $v := .Values }}{{ if gt $i 0 }} |{{ end}} {{ .ErlName }}{{ end }}.
+
+-spec {{ .ErlName }}_to_int({{ .ErlTypeName }}()) -> integer().
+{{- $et := . }}
+{{- range $i, $v := .Values }}
+{{- if gt $i 0 }};{{ end }}
+{{ $et.ErlName }}_to_int({{ $v.ErlName }}) -> {{ $v.Number }}
+{{- end }}.
+
+-spec int_to_{{ .ErlName }}(integer()) -> {{ .ErlTypeName }}().
+{{- range $i, $v := .CanonicalValues }}
+{{- if gt $i 0 }};{{ end }}
+int_to_{{ $et.ErlName }}({{ $v.Number }}) -> {{ $v.ErlName }}
+{{- end }}.
+
+-spec {{ .ErlName }}_values() -> [{{ .ErlTypeName }}()].
+{{ .ErlName }}_values() ->
+  [{{ range $i, $v := .Values }}{{ if gt $i 0 }}, {{ end }}{{ $v.ErlName }}{{ end }}].
+
+-spec {{ .ErlName }}_name({{ .ErlTypeName }}()) -> binary().
+{{- range $i, $v := .Values }}
+{{- if gt $i 0 }};{{ end }}
+{{ $et.ErlName }}_name({{ $v.ErlName }}) -> <<"{{ $v.Name }}">>
+{{- end }}.
+
+-spec {{ .ErlName }}_aliases({{ .ErlTypeName }}()) -> [{{ .ErlTypeName }}()].
+{{- range $i, $v := .Values }}
+{{- if gt $i 0 }};{{ end }}
+{{ $et.ErlName }}_aliases({{ $v.ErlName }}) ->
+  [{{ range $j, $a := $v.Aliases }}{{ if gt $j 0 }}, {{ end }}{{ $a.ErlName }}{{ end }}]
+{{- end }}.
+{{- end }}
+
+{{- define "erl_exports" -}}
+-export([
+  {{- range $i, $f := . }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ $f.Name }}/{{ $f.Arity }}
+  {{- end }}
+]).
 {{- end }}
 
 {{- define "erl_message" }}
 %% Generated for message type {{ .FullName }}.
--type {{ .ErlName }}() :: #{{ .ErlName }}{}.
-
--spec encode_{{ .ErlName }}({{ .ErlName }}()) -> iodata().
-encode_{{ .ErlName }}(Message) ->
-  undefined.
-
--spec decode_{{ .ErlName }}(iodata()) -> {{ "{" }}{{ .ErlName }}(), iodata()}.
-decode_{{ .ErlName }}(Data) ->
-  undefined.
+{{ with .Comment }}{{ .ErlTypeDoc }}
+{{ end }}-type {{ .ErlTypeName }}() :: #{{ .ErlName }}{}.
+{{ template "erl_message_codec" . }}
 {{- end }}
 
 %%% Generated from protobuf package {{ .PackageName }}.
@@ -45,34 +79,316 @@ decode_{{ .ErlName }}(Data) ->
 
 -include("{{ .ErlModuleName }}.hrl").
 
+-export_type([
+  reflection_field/0,
+  reflection_type/0
+]).
+
 -export_type([
   {{- range $i, $e := .PackageEnumTypes }}
   {{- if gt $i 0 }},{{ end }}
-  {{ $e.ErlName }}/0
+  {{ $e.ErlTypeName }}/0
   {{- end }}
 ]).
 
 -export_type([
   {{- range $i, $m := .PackageMessageTypes }}
   {{- if gt $i 0 }},{{ end }}
-  {{ $m.ErlName }}/0
+  {{ $m.ErlTypeName }}/0
   {{- end }}
 ]).
 
 -export([
-  {{- range $i, $m := .PackageMessageTypes }}
+  descriptor/0,
+  file_descriptor_proto/1,
+  message_names/0,
+  enum_names/0,
+  fields/1,
+  find_field/2
+]).
+
+-type reflection_field() ::
+        #{number := pos_integer(),
+          name := atom(),
+          proto_name := binary(),
+          json_name := binary(),
+          type := reflection_type(),
+          label := optional | required | repeated,
+          oneof := atom() | undefined}.
+
+-type reflection_type() ::
+        double | float | int32 | int64 | uint32 | uint64
+      | sint32 | sint64 | fixed32 | fixed64 | sfixed32 | sfixed64
+      | bool | string | bytes
+      | {enum, module(), atom()}
+      | {message, module(), atom()}.
+
+{{ template "erl_exports" .ErlExports.Enums }}
+
+-export_type([encode_option/0, decode_option/0, decode_error/0]).
+
+{{ template "erl_exports" .ErlExports.Messages }}
+
+-compile({nowarn_unused_function, [{pb_message_size, 2},
+                                   {pb_field_number, 1},
+                                   {pb_field_size, 2},
+                                   {pb_sort_map_entries, 3},
+                                   {pb_values_size, 4},
+                                   {pb_value_size, 4},
+                                   {pb_raw_size, 2},
+                                   {pb_bytes_size, 1},
+                                   {pb_key_size, 1},
+                                   {pb_varint_size, 1},
+                                   {pb_encode_items, 2},
+                                   {pb_encode_raw, 3},
+                                   {pb_encode_bytes, 2},
+                                   {pb_encode_key, 3},
+                                   {pb_encode_varint, 2},
+                                   {pb_zigzag, 1},
+                                   {pb_special_float_bits, 2},
+                                   {pb_string_data, 2},
+                                   {pb_is_default, 3},
+                                   {pb_wire_type, 1},
+                                   {pb_decode_context, 3},
+                                   {pb_field_context, 4},
+                                   {pb_path_element, 3},
+                                   {pb_limit_exceeded, 2},
+                                   {pb_field_path, 1},
+                                   {pb_decode_message, 3},
+                                   {pb_decode_fields, 5},
+                                   {pb_decode_error, 5},
+                                   {pb_decode_field, 5},
+                                   {pb_decode_field_value, 6},
+                                   {pb_set_field, 4},
+                                   {pb_decode_packed, 6},
+                                   {pb_decode_value, 3},
+                                   {pb_check_length, 2},
+                                   {pb_decode_value, 2},
+                                   {pb_integer, 3},
+                                   {pb_special_float, 2},
+                                   {pb_decode_string, 3},
+                                   {pb_decode_map_entry, 5},
+                                   {pb_default, 1},
+                                   {pb_decode_varint, 1},
+                                   {pb_decode_varint, 3},
+                                   {pb_decode_bytes, 1},
+                                   {pb_skip_value, 2},
+                                   {pb_merge_fields, 3},
+                                   {pb_merge_value, 3}]}).
+{{- if .Options.Accessors }}
+
+{{ template "erl_exports" .ErlExports.Accessors }}
+{{- end }}
+{{- if .Options.FieldMasks }}
+
+-export_type([pb_field_mask/0]).
+
+{{ template "erl_exports" .ErlExports.FieldMasks }}
+
+-compile({nowarn_unused_function, [{pb_mask_paths, 1},
+                                   {pb_mask_groups, 1},
+                                   {pb_validate_mask, 2},
+                                   {pb_validate_mask_field, 3},
+                                   {pb_check_mask, 1},
+                                   {pb_mask, 4},
+                                   {pb_mask_field, 4},
+                                   {pb_merge_masked, 4},
+                                   {pb_merge_masked_field, 4},
+                                   {pb_merge_masked_value, 4}]}).
+{{- end }}
+{{- if .Options.JSON }}
+
+{{ template "erl_exports" .ErlExports.JSONEnums }}
+
+{{ template "erl_exports" .ErlExports.JSONMessages }}
+
+-compile({nowarn_unused_function, [{json_object, 2},
+                                   {json_oneof, 2},
+                                   {json_is_default, 2},
+                                   {json_encode_value, 2},
+                                   {json_encode_float, 1},
+                                   {json_encode_map_key, 2},
+                                   {json_field, 4},
+                                   {json_oneof_field, 2},
+                                   {json_find, 2},
+                                   {json_decode_value, 2},
+                                   {json_decode_integer, 1},
+                                   {json_decode_float, 1},
+                                   {json_decode_map_key, 2},
+                                   {json_decode_base64, 1}]}).
+{{- end }}
+{{- if .Options.Validate }}
+
+-export_type([validation_error/0]).
+
+{{ template "erl_exports" .ErlExports.Validation }}
+
+-compile({nowarn_unused_function, [{validate_fields, 1},
+                                   {validate_value, 4},
+                                   {validate_unset, 2},
+                                   {validate_children, 4},
+                                   {validate_rule, 3},
+                                   {validate_string_rule, 3},
+                                   {validation_length, 2},
+                                   {validation_is_empty, 2},
+                                   {validation_enum_int, 2},
+                                   {validation_map_entries, 1},
+                                   {validation_reason, 1},
+                                   {validation_reason_format, 1}]}).
+{{- end }}
+{{- if .Options.Verify }}
+
+-export_type([verification_error/0]).
+
+{{ template "erl_exports" .ErlExports.Verification }}
+
+-compile({nowarn_unused_function, [{verify_fields, 1},
+                                   {verify_value, 2},
+                                   {verify_elements, 3},
+                                   {verify_entries, 3},
+                                   {verify_iodata, 1},
+                                   {verify_chardata, 1},
+                                   {verify_string, 1},
+                                   {verify_error, 2},
+                                   {verify_expected, 1},
+                                   {verify_before_encode, 2}]}).
+{{- end }}
+{{- with .ErlExports.Deprecated }}
+
+-deprecated([
+  {{- range $i, $f := . }}
   {{- if gt $i 0 }},{{ end }}
-  encode_{{ $m.ErlName }}/1,
-  decode_{{ $m.ErlName }}/1
+  {{ "{" }}{{ $f.Name }}, {{ $f.Arity }}}
   {{- end }}
 ]).
+{{- end }}
+
+%% Return the serialized FileDescriptorSet containing the files of the
+%% package and all their dependencies.
+-spec descriptor() -> binary().
+descriptor() ->
+  iolist_to_binary([
+    {{- range $i, $f := .FileDescriptors }}
+    {{- if gt $i 0 }},{{ end }}
+    [{{ $f.ErlSetEntryData }}, file_descriptor_proto_data({{ $f.ErlName }})]
+    {{- end }}
+  ]).
+
+%% Return the serialized FileDescriptorProto of a file of the package or of
+%% one of its dependencies.
+-spec file_descriptor_proto(unicode:chardata()) -> {ok, binary()} | error.
+file_descriptor_proto(Name) ->
+  case file_descriptor_proto_data(unicode:characters_to_binary(Name)) of
+    undefined ->
+      error;
+    Data ->
+      {ok, Data}
+  end.
+
+-spec file_descriptor_proto_data(binary()) -> binary() | undefined.
+{{- range .FileDescriptors }}
+file_descriptor_proto_data({{ .ErlName }}) ->
+  {{ .ErlData }};
+{{- end }}
+file_descriptor_proto_data(_) ->
+  undefined.
+
+%% Return the names of the records of all message types of the package.
+-spec message_names() -> [atom()].
+message_names() ->
+  [
+  {{- range $i, $m := .PackageMessageTypes }}
+  {{- if gt $i 0 }},{{ end }}
+   {{ $m.ErlName }}
+  {{- end }}].
+
+%% Return the names of the types of all enum types of the package.
+-spec enum_names() -> [atom()].
+enum_names() ->
+  [
+  {{- range $i, $e := .PackageEnumTypes }}
+  {{- if gt $i 0 }},{{ end }}
+   {{ $e.ErlName }}
+  {{- end }}].
+
+%% Return the fields of a message type.
+-spec fields(atom()) -> [reflection_field()].
+{{- range $i, $m := .PackageMessageTypes }}
+fields({{ $m.ErlName }}) ->
+  [
+  {{- range $j, $f := $m.Fields }}
+  {{- if gt $j 0 }},{{ end }}
+   #{number => {{ $f.Number }}, name => {{ $f.ErlName }},
+     proto_name => <<"{{ $f.Name }}">>,
+     json_name => {{ $f.ErlJSONName }},
+     type => {{ $f.ErlReflectionType }}, label => {{ $f.ErlLabel }},
+     oneof => {{ if $f.OneofType }}{{ $f.OneofType.ErlName }}{{ else }}undefined{{ end }}}
+  {{- end }}];
+{{- end }}
+fields(MessageName) ->
+  error({unknown_message, MessageName}).
+
+%% Find a field of a message type by name, protobuf name or number.
+-spec find_field(atom(), atom() | binary() | pos_integer()) ->
+        {ok, reflection_field()} | error.
+find_field(MessageName, Name) when is_atom(Name) ->
+  find_field(name, Name, fields(MessageName));
+find_field(MessageName, ProtoName) when is_binary(ProtoName) ->
+  find_field(proto_name, ProtoName, fields(MessageName));
+find_field(MessageName, Number) when is_integer(Number) ->
+  find_field(number, Number, fields(MessageName)).
+
+-spec find_field(name | proto_name | number,
+                 atom() | binary() | pos_integer(),
+                 [reflection_field()]) ->
+        {ok, reflection_field()} | error.
+find_field(Key, Value, Fields) ->
+  case [F || F <- Fields, maps:get(Key, F) =:= Value] of
+    [Field | _] ->
+      {ok, Field};
+    [] ->
+      error
+  end.
 
 {{ range .PackageEnumTypes }}
 {{ template "erl_enum" . }}
+{{- if $.Options.JSON }}
+{{ template "erl_enum_json" . }}
+{{- end }}
 {{ end }}
 
 {{ range .PackageMessageTypes }}
 {{ template "erl_message" . }}
+{{ template "erl_message_merge" . }}
+{{- if $.Options.Accessors }}
+{{ template "erl_message_accessors" . }}
+{{- end }}
+{{- if $.Options.FieldMasks }}
+{{ template "erl_message_field_mask" . }}
+{{- end }}
+{{- if $.Options.JSON }}
+{{ template "erl_message_json" . }}
+{{- end }}
+{{- if $.Options.Validate }}
+{{ template "erl_message_validation" . }}
+{{- end }}
+{{- if $.Options.Verify }}
+{{ template "erl_message_verification" . }}
+{{- end }}
+{{ end }}
+{{ template "erl_codec_support" }}
+{{ template "erl_merge_support" }}
+{{- if .Options.FieldMasks }}
+{{ template "erl_field_mask_support" }}
+{{ end }}
+{{- if .Options.JSON }}
+{{ template "erl_json_support" }}
+{{ end }}
+{{- if .Options.Validate }}
+{{ template "erl_validation_support" }}
+{{ end }}
+{{- if .Options.Verify }}
+{{ template "erl_verification_support" }}
 {{ end }}
 `
 
@@ -83,5 +399,37 @@ func ErlModuleTemplate() (*template.Template, error) {
 		return nil, fmt.Errorf("cannot parse template: %w", err)
 	}
 
+	if _, err := tpl.Parse(erlCodecTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse codec template: %w", err)
+	}
+
+	if _, err := tpl.Parse(erlMergeTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse merge template: %w", err)
+	}
+
+	if _, err := tpl.Parse(erlAccessorTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse accessor template: %w",
+			err)
+	}
+
+	if _, err := tpl.Parse(erlFieldMaskTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse field mask template: %w",
+			err)
+	}
+
+	if _, err := tpl.Parse(erlJSONTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse json template: %w", err)
+	}
+
+	if _, err := tpl.Parse(erlValidationTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse validation template: %w",
+			err)
+	}
+
+	if _, err := tpl.Parse(erlVerificationTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse verification template: %w",
+			err)
+	}
+
 	return tpl, nil
 }
@@ -65,6 +65,44 @@ func (ot *OneofType) ResolveType(absNameResolver AbsoluteNameResolver) error {
 	return nil
 }
 
-func OneofTypeNameToErlName(name string, msg *MessageType) string {
-	return fmt.Sprintf("%s_%s")
+// ErlCodecCases returns the Erlang list describing the fields of the oneof
+// for generated protobuf encoding functions.
+func (ot *OneofType) ErlCodecCases() string {
+	cases := make([]string, len(ot.Fields))
+
+	for i, ft := range ot.Fields {
+		cases[i] = fmt.Sprintf("{%s, %d, %s}",
+			ft.ErlName, ft.Number, ft.erlCodecValueType())
+	}
+
+	return "[" + strings.Join(cases, ", ") + "]"
+}
+
+// ErlMergeType returns the Erlang term indicating how generated merge
+// functions combine values of the oneof: messages of the same case are
+// merged, other values replace the current one.
+func (ot *OneofType) ErlMergeType() string {
+	var cases []string
+
+	for _, ft := range ot.Fields {
+		if ft.TypeId == FieldTypeIdMessage {
+			cases = append(cases, fmt.Sprintf("{%s, %s}",
+				ft.ErlName, ft.erlMergeFunction()))
+		}
+	}
+
+	return "{oneof, [" + strings.Join(cases, ", ") + "]}"
+}
+
+// ErlVerifyType returns the Erlang term describing the type specification of
+// the oneof for generated verification functions.
+func (ot *OneofType) ErlVerifyType() string {
+	cases := make([]string, len(ot.Fields))
+
+	for i, ft := range ot.Fields {
+		cases[i] = fmt.Sprintf("{%s, %s}",
+			ft.ErlName, ft.erlVerifyValueType())
+	}
+
+	return "{oneof, [" + strings.Join(cases, ", ") + "]}"
 }
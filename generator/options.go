@@ -0,0 +1,146 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options are passed by protoc as a comma-separated list of names or
+// name=value pairs, e.g. "--erlang_out=strip_enum_prefix:." or
+// "--erlang_opt=strip_enum_prefix,doc_format=attributes".
+type Options struct {
+	StripEnumPrefix bool
+	HRLMacros       bool
+	GRPC            bool
+	GenServer       bool
+	JSON            bool
+	Twirp           bool
+	HTTP            bool
+	Validate        bool
+	Verify          bool
+	VerifyEncode    bool
+	Accessors       bool
+	FieldMasks      bool
+
+	SimpleIntegerSpecs bool
+
+	DocFormat DocFormat
+
+	StringRepr StringAs
+	BytesRepr  StringAs
+}
+
+func (opts *Options) Parse(s string) error {
+	opts.DocFormat = DocFormatEDoc
+	opts.StringRepr = StringAsIOData
+	opts.BytesRepr = StringAsIOData
+
+	for _, part := range strings.Split(s, ",") {
+		name := strings.TrimSpace(part)
+		if name == "" {
+			continue
+		}
+
+		var value string
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, value = name[:i], name[i+1:]
+
+			if err := opts.parseValue(name, value); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		switch name {
+		case "strip_enum_prefix":
+			opts.StripEnumPrefix = true
+		case "hrl_macros":
+			opts.HRLMacros = true
+		case "grpc":
+			opts.GRPC = true
+		case "gen_server":
+			opts.GenServer = true
+		case "json":
+			opts.JSON = true
+		case "twirp":
+			opts.Twirp = true
+		case "http":
+			opts.HTTP = true
+		case "validate":
+			opts.Validate = true
+		case "verify":
+			opts.Verify = true
+		case "verify_encode":
+			opts.VerifyEncode = true
+		case "accessors":
+			opts.Accessors = true
+		case "field_masks":
+			opts.FieldMasks = true
+		case "simple_integer_specs":
+			opts.SimpleIntegerSpecs = true
+		default:
+			return fmt.Errorf("unknown option %q", name)
+		}
+	}
+
+	// Twirp and HTTP handlers use JSON functions
+	if opts.Twirp || opts.HTTP {
+		opts.JSON = true
+	}
+
+	if opts.VerifyEncode {
+		opts.Verify = true
+	}
+
+	return nil
+}
+
+func (opts *Options) parseValue(name, value string) error {
+	switch name {
+	case "doc_format":
+		switch DocFormat(value) {
+		case DocFormatEDoc, DocFormatAttributes:
+			opts.DocFormat = DocFormat(value)
+		default:
+			return fmt.Errorf("invalid value %q for option %q",
+				value, name)
+		}
+	case "string_repr":
+		switch value {
+		case "iodata", "binary", "chardata":
+			opts.StringRepr = StringAs(value)
+		case "string":
+			opts.StringRepr = StringAsList
+		default:
+			return fmt.Errorf("invalid value %q for option %q",
+				value, name)
+		}
+	case "bytes_repr":
+		switch StringAs(value) {
+		case StringAsIOData, StringAsBinary:
+			opts.BytesRepr = StringAs(value)
+		default:
+			return fmt.Errorf("invalid value %q for option %q",
+				value, name)
+		}
+	default:
+		return fmt.Errorf("unknown option %q", name)
+	}
+
+	return nil
+}
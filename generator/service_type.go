@@ -0,0 +1,315 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+type MethodType struct {
+	Service *ServiceType
+
+	Name string
+	Path string // e.g. "/pkg.Service/Method"
+
+	InputTypeName  string
+	OutputTypeName string
+
+	InputType  *MessageType // available after type resolution
+	OutputType *MessageType // available after type resolution
+
+	ClientStreaming bool
+	ServerStreaming bool
+
+	// True if either the method or the service is deprecated.
+	Deprecated bool
+
+	HTTPRules HTTPRules // from google.api.http annotations
+
+	Comment *Comment
+
+	ErlName string
+}
+
+type MethodTypes []*MethodType
+
+func (methodType *MethodType) FromDescriptor(md *descriptor.MethodDescriptorProto, service *ServiceType) error {
+	m := MethodType{
+		Service: service,
+
+		Name: md.GetName(),
+
+		InputTypeName:  md.GetInputType(),
+		OutputTypeName: md.GetOutputType(),
+
+		ClientStreaming: md.GetClientStreaming(),
+		ServerStreaming: md.GetServerStreaming(),
+
+		Deprecated: md.GetOptions().GetDeprecated() || service.Deprecated,
+	}
+
+	m.Path = "/" + service.FullName + "/" + m.Name
+
+	m.ErlName = CamelCaseToSnakeCase(m.Name)
+
+	rules, err := HTTPRulesFromMethodOptions(md.GetOptions())
+	if err != nil {
+		return fmt.Errorf("invalid http annotation: %w", err)
+	}
+	m.HTTPRules = rules
+
+	*methodType = m
+	return nil
+}
+
+func (m *MethodType) ResolveTypes(absNameResolver AbsoluteNameResolver) error {
+	m.InputType = absNameResolver.FindMessageType(m.InputTypeName)
+	if m.InputType == nil {
+		return fmt.Errorf("unknown input message type %q",
+			m.InputTypeName)
+	}
+
+	m.OutputType = absNameResolver.FindMessageType(m.OutputTypeName)
+	if m.OutputType == nil {
+		return fmt.Errorf("unknown output message type %q",
+			m.OutputTypeName)
+	}
+
+	for _, rule := range m.HTTPRules {
+		if err := rule.ResolveTypes(m); err != nil {
+			return fmt.Errorf("invalid http rule %s %s: %w",
+				rule.Method, rule.Pattern, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *MethodType) Unary() bool {
+	return !m.ClientStreaming && !m.ServerStreaming
+}
+
+func (m *MethodType) BidiStreaming() bool {
+	return m.ClientStreaming && m.ServerStreaming
+}
+
+type ServiceType struct {
+	Package  string
+	Name     string
+	FullName string // e.g. "pkg.Service"
+
+	Methods MethodTypes
+
+	Deprecated bool
+
+	Comment *Comment
+
+	ErlPackage string
+	ErlName    string
+
+	ErlBehaviourModuleName string
+	ErlDispatchModuleName  string
+	ErlClientModuleName    string
+	ErlTransportModuleName string
+
+	ErlGenServerModuleName    string
+	ErlGenServerAPIModuleName string
+
+	ErlTwirpHandlerModuleName string
+	ErlTwirpClientModuleName  string
+
+	ErlHTTPHandlerModuleName string
+}
+
+type ServiceTypes []*ServiceType
+
+func (serviceType *ServiceType) FromDescriptor(fd *descriptor.FileDescriptorProto, sd *descriptor.ServiceDescriptorProto) error {
+	st := ServiceType{
+		Package: fd.GetPackage(),
+		Name:    sd.GetName(),
+
+		Deprecated: sd.GetOptions().GetDeprecated(),
+	}
+
+	st.FullName = st.Name
+	if st.Package != "" {
+		st.FullName = st.Package + "." + st.Name
+	}
+
+	erlPackage, err := ErlFileModuleName(fd)
+	if err != nil {
+		return err
+	}
+
+	st.ErlPackage = erlPackage
+	st.ErlName = CamelCaseToSnakeCase(st.Name)
+
+	st.ErlBehaviourModuleName = st.ErlPackage + "_" + st.ErlName + "_bhvr"
+	st.ErlDispatchModuleName = st.ErlPackage + "_" + st.ErlName + "_dispatch"
+	st.ErlClientModuleName = st.ErlPackage + "_" + st.ErlName + "_client"
+	st.ErlTransportModuleName = ErlGRPCTransportModuleName(st.ErlPackage)
+
+	st.ErlGenServerModuleName = st.ErlPackage + "_" + st.ErlName + "_server"
+	st.ErlGenServerAPIModuleName = st.ErlPackage + "_" + st.ErlName + "_api"
+
+	st.ErlTwirpHandlerModuleName =
+		st.ErlPackage + "_" + st.ErlName + "_twirp_handler"
+	st.ErlTwirpClientModuleName =
+		st.ErlPackage + "_" + st.ErlName + "_twirp_client"
+
+	st.ErlHTTPHandlerModuleName =
+		st.ErlPackage + "_" + st.ErlName + "_http_handler"
+
+	*serviceType = st
+
+	for _, md := range sd.Method {
+		var m MethodType
+		if err := m.FromDescriptor(md, serviceType); err != nil {
+			return fmt.Errorf("invalid method %q: %w",
+				md.GetName(), err)
+		}
+
+		serviceType.Methods = append(serviceType.Methods, &m)
+	}
+
+	return nil
+}
+
+func (st *ServiceType) ResolveTypes(absNameResolver AbsoluteNameResolver) error {
+	for _, m := range st.Methods {
+		if err := m.ResolveTypes(absNameResolver); err != nil {
+			return fmt.Errorf("cannot resolve types of method %q: %w",
+				m.Name, err)
+		}
+	}
+
+	// Message types are identified by their record name in generated
+	// code, which is only unique in a single package.
+	nameToMessageType := make(map[string]*MessageType)
+
+	for _, mt := range st.MessageTypes() {
+		if mt2, found := nameToMessageType[mt.ErlName]; found {
+			return fmt.Errorf("message types %s and %s have the "+
+				"same record name %q", mt2.AbsoluteName,
+				mt.AbsoluteName, mt.ErlName)
+		}
+
+		nameToMessageType[mt.ErlName] = mt
+	}
+
+	return nil
+}
+
+func (st *ServiceType) UnaryMethods() MethodTypes {
+	var ms MethodTypes
+
+	for _, m := range st.Methods {
+		if m.Unary() {
+			ms = append(ms, m)
+		}
+	}
+
+	return ms
+}
+
+// HTTPMethods returns unary methods with at least one HTTP rule.
+func (st *ServiceType) HTTPMethods() MethodTypes {
+	var ms MethodTypes
+
+	for _, m := range st.Methods {
+		if m.Unary() && len(m.HTTPRules) > 0 {
+			ms = append(ms, m)
+		}
+	}
+
+	return ms
+}
+
+// CowboyRoutes returns the cowboy paths of all HTTP rules without
+// duplicates.
+func (st *ServiceType) CowboyRoutes() []string {
+	var routes []string
+	seen := make(map[string]bool)
+
+	for _, m := range st.HTTPMethods() {
+		for _, rule := range m.HTTPRules {
+			route := rule.PathTemplate.CowboyRoute()
+			if !seen[route] {
+				routes = append(routes, route)
+				seen[route] = true
+			}
+		}
+	}
+
+	return routes
+}
+
+func (st *ServiceType) DeprecatedMethods() MethodTypes {
+	var ms MethodTypes
+
+	for _, m := range st.Methods {
+		if m.Deprecated {
+			ms = append(ms, m)
+		}
+	}
+
+	return ms
+}
+
+func (st *ServiceType) DeprecatedUnaryMethods() MethodTypes {
+	var ms MethodTypes
+
+	for _, m := range st.UnaryMethods() {
+		if m.Deprecated {
+			ms = append(ms, m)
+		}
+	}
+
+	return ms
+}
+
+func (st *ServiceType) HasStreamingMethods() bool {
+	for _, m := range st.Methods {
+		if !m.Unary() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MessageTypes returns the input and output message types of all methods
+// without duplicates.
+func (st *ServiceType) MessageTypes() MessageTypes {
+	var mts MessageTypes
+	seen := make(map[*MessageType]bool)
+
+	for _, m := range st.Methods {
+		for _, mt := range []*MessageType{m.InputType, m.OutputType} {
+			if !seen[mt] {
+				mts = append(mts, mt)
+				seen[mt] = true
+			}
+		}
+	}
+
+	return mts
+}
+
+func ErlGRPCTransportModuleName(erlPackage string) string {
+	return erlPackage + "_grpc_transport"
+}
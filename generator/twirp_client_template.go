@@ -0,0 +1,198 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlTwirpClientTemplateContent = `
+{{- define "erl_input" }}{{ .InputType.ErlPackage }}:{{ .InputType.ErlTypeName }}(){{ end }}
+{{- define "erl_output" }}{{ .OutputType.ErlPackage }}:{{ .OutputType.ErlTypeName }}(){{ end }}
+
+%%% Generated from protobuf service {{ .FullName }}.
+%%% DO NOT EDIT.
+
+%%% Twirp client functions based on httpc.
+%%%
+%%% Options:
+%%% - encoding: either protobuf (default) or json;
+%%% - timeout: the request timeout (default: 5000);
+%%% - prefix: the route prefix (default: <<"/twirp">>);
+%%% - headers: additional HTTP request headers;
+%%% - http_options: additional httpc HTTP options.
+{{- with .Comment }}{{ .ErlModuleComment }}{{ end }}
+
+-module({{ .ErlTwirpClientModuleName }}).
+{{- with .Comment }}{{ .ErlModuleDoc }}{{ end }}
+
+-export([
+  {{- range $i, $m := .UnaryMethods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ $m.ErlName }}/2,
+  {{ $m.ErlName }}/3
+  {{- end }}
+]).
+{{- if .DeprecatedUnaryMethods }}
+
+-deprecated([
+  {{- range $i, $m := .DeprecatedUnaryMethods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ "{" }}{{ $m.ErlName }}, '_'}
+  {{- end }}
+]).
+{{- end }}
+
+-export_type([call_options/0, call_error/0]).
+
+-type call_options() :: #{encoding => protobuf | json,
+                          timeout => timeout(),
+                          prefix => binary(),
+                          headers => [{string(), string()}],
+                          http_options => list()}.
+
+-type call_error() ::
+        {{ .ErlTwirpHandlerModuleName }}:twirp_error()
+      | {http, term()}.
+{{ range .UnaryMethods }}
+%% Generated for method {{ .Name }}.
+{{ with .Comment }}{{ .ErlDoc }}
+{{ end }}-spec {{ .ErlName }}(uri_string:uri_string(), {{ template "erl_input" . }}) ->
+        {ok, {{ template "erl_output" . }}} | {error, call_error()}.
+{{ .ErlName }}(BaseURI, Request) ->
+  {{ .ErlName }}(BaseURI, Request, #{}).
+
+-spec {{ .ErlName }}(uri_string:uri_string(), {{ template "erl_input" . }},
+          call_options()) ->
+        {ok, {{ template "erl_output" . }}} | {error, call_error()}.
+{{ .ErlName }}(BaseURI, Request, Options) ->
+  call(BaseURI, <<"/{{ .Service.FullName }}/{{ .Name }}">>, Request, Options,
+       fun {{ .InputType.ErlPackage }}:encode_{{ .InputType.ErlName }}/1,
+       fun {{ .InputType.ErlPackage }}:to_json_{{ .InputType.ErlName }}/1,
+       fun {{ .OutputType.ErlPackage }}:decode_{{ .OutputType.ErlName }}/1,
+       fun {{ .OutputType.ErlPackage }}:from_json_{{ .OutputType.ErlName }}/1).
+{{ end }}
+{{- if .UnaryMethods }}
+-spec call(uri_string:uri_string(), binary(), tuple(), call_options(),
+           fun((tuple()) -> iodata()),
+           fun((tuple()) -> json:encode_value()),
+           fun((binary()) -> {tuple(), binary()}),
+           fun((json:decode_value()) -> tuple())) ->
+        {ok, tuple()} | {error, call_error()}.
+call(BaseURI, Path, Request, Options,
+     EncodeProtobuf, EncodeJSON, DecodeProtobuf, DecodeJSON) ->
+  Prefix = maps:get(prefix, Options, <<"/twirp">>),
+  URI = unicode:characters_to_list([BaseURI, Prefix, Path]),
+  Encoding = maps:get(encoding, Options, protobuf),
+  {ContentType, Body} =
+    case Encoding of
+      protobuf ->
+        {"application/protobuf", iolist_to_binary(EncodeProtobuf(Request))};
+      json ->
+        {"application/json", iolist_to_binary(json:encode(EncodeJSON(Request)))}
+    end,
+  Headers = maps:get(headers, Options, []),
+  HTTPOptions = [{timeout, maps:get(timeout, Options, 5000)} |
+                 maps:get(http_options, Options, [])],
+  case httpc:request(post, {URI, Headers, ContentType, Body}, HTTPOptions,
+                     [{body_format, binary}]) of
+    {ok, {{"{{"}}_, 200, _}, _, ResponseBody}} ->
+      try
+        decode_response(ResponseBody, Encoding, DecodeProtobuf, DecodeJSON)
+      of
+        Response ->
+          {ok, Response}
+      catch
+        _:_ ->
+          {error, {internal, <<"cannot decode response">>, #{}}}
+      end;
+    {ok, {{"{{"}}_, Status, _}, _, ResponseBody}} ->
+      {error, decode_error(Status, ResponseBody)};
+    {error, Reason} ->
+      {error, {http, Reason}}
+  end.
+
+-spec decode_response(binary(), protobuf | json,
+                      fun((binary()) -> {tuple(), binary()}),
+                      fun((json:decode_value()) -> tuple())) ->
+        tuple().
+decode_response(Body, protobuf, DecodeProtobuf, _DecodeJSON) ->
+  {Response, _} = DecodeProtobuf(Body),
+  Response;
+decode_response(Body, json, _DecodeProtobuf, DecodeJSON) ->
+  DecodeJSON(json:decode(Body)).
+
+-spec decode_error(non_neg_integer(), binary()) ->
+        {{ .ErlTwirpHandlerModuleName }}:twirp_error().
+decode_error(Status, Body) ->
+  try json:decode(Body) of
+    Value = #{<<"code">> := Code, <<"msg">> := Msg} when is_binary(Code),
+                                                         is_binary(Msg) ->
+      {error_code(Code), Msg, maps:get(<<"meta">>, Value, #{})};
+    _ ->
+      {status_error_code(Status), <<"invalid error response">>, #{}}
+  catch
+    _:_ ->
+      {status_error_code(Status), <<"invalid error response">>, #{}}
+  end.
+
+-spec error_code(binary()) ->
+        {{ .ErlTwirpHandlerModuleName }}:twirp_error_code().
+error_code(<<"canceled">>) -> canceled;
+error_code(<<"invalid_argument">>) -> invalid_argument;
+error_code(<<"malformed">>) -> malformed;
+error_code(<<"deadline_exceeded">>) -> deadline_exceeded;
+error_code(<<"not_found">>) -> not_found;
+error_code(<<"bad_route">>) -> bad_route;
+error_code(<<"already_exists">>) -> already_exists;
+error_code(<<"permission_denied">>) -> permission_denied;
+error_code(<<"unauthenticated">>) -> unauthenticated;
+error_code(<<"resource_exhausted">>) -> resource_exhausted;
+error_code(<<"failed_precondition">>) -> failed_precondition;
+error_code(<<"aborted">>) -> aborted;
+error_code(<<"out_of_range">>) -> out_of_range;
+error_code(<<"unimplemented">>) -> unimplemented;
+error_code(<<"internal">>) -> internal;
+error_code(<<"unavailable">>) -> unavailable;
+error_code(<<"data_loss">>) -> data_loss;
+error_code(_) -> unknown.
+
+%% Error codes for responses which do not contain a Twirp error, as
+%% specified by the Twirp protocol.
+-spec status_error_code(non_neg_integer()) ->
+        {{ .ErlTwirpHandlerModuleName }}:twirp_error_code().
+status_error_code(Status) when Status >= 300, Status < 400 -> internal;
+status_error_code(400) -> internal;
+status_error_code(401) -> unauthenticated;
+status_error_code(403) -> permission_denied;
+status_error_code(404) -> bad_route;
+status_error_code(Status) when Status =:= 429;
+                               Status =:= 502;
+                               Status =:= 503;
+                               Status =:= 504 -> unavailable;
+status_error_code(_) -> unknown.
+{{- end }}
+`
+
+func ErlTwirpClientTemplate() (*template.Template, error) {
+	tpl := template.New("erl_twirp_client")
+
+	if _, err := tpl.Parse(erlTwirpClientTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"fmt"
+	"text/template"
+)
+
+var erlTwirpHandlerTemplateContent = `
+{{- define "erl_input" }}{{ .InputType.ErlPackage }}:{{ .InputType.ErlTypeName }}(){{ end }}
+{{- define "erl_output" }}{{ .OutputType.ErlPackage }}:{{ .OutputType.ErlTypeName }}(){{ end }}
+
+%%% Generated from protobuf service {{ .FullName }}.
+%%% DO NOT EDIT.
+
+%%% A cowboy handler serving the service with the Twirp protocol. Requests
+%%% are forwarded to an implementation module providing the callbacks of
+%%% this module. Both protobuf and JSON content types are supported.
+%%%
+%%% Use routes/1 or routes/2 to obtain cowboy routes for the service.
+{{- with .Comment }}{{ .ErlModuleComment }}{{ end }}
+
+-module({{ .ErlTwirpHandlerModuleName }}).
+{{- with .Comment }}{{ .ErlModuleDoc }}{{ end }}
+
+-behaviour(cowboy_handler).
+
+-export([routes/1, routes/2, init/2]).
+
+-export_type([twirp_error_code/0, twirp_error/0, context/0]).
+{{- if .DeprecatedUnaryMethods }}
+
+-deprecated_callback([
+  {{- range $i, $m := .DeprecatedUnaryMethods }}
+  {{- if gt $i 0 }},{{ end }}
+  {{ "{" }}{{ $m.ErlName }}, 2}
+  {{- end }}
+]).
+{{- end }}
+
+-type twirp_error_code() :: canceled
+                          | unknown
+                          | invalid_argument
+                          | malformed
+                          | deadline_exceeded
+                          | not_found
+                          | bad_route
+                          | already_exists
+                          | permission_denied
+                          | unauthenticated
+                          | resource_exhausted
+                          | failed_precondition
+                          | aborted
+                          | out_of_range
+                          | unimplemented
+                          | internal
+                          | unavailable
+                          | data_loss.
+
+-type twirp_error() ::
+        {twirp_error_code(), Msg :: unicode:chardata()}
+      | {twirp_error_code(), Msg :: unicode:chardata(),
+         Meta :: #{binary() => binary()}}.
+
+-type context() :: #{req := cowboy_req:req(),
+                     options := map()}.
+{{- range .UnaryMethods }}
+
+%% Generated for method {{ .Name }}.
+{{ with .Comment }}{{ .ErlDoc }}
+{{ end }}-callback {{ .ErlName }}({{ template "erl_input" . }}, context()) ->
+  {ok, {{ template "erl_output" . }}} | {error, twirp_error()}.
+{{- end }}
+
+-spec routes(module()) -> [{binary(), module(), map()}].
+routes(Impl) ->
+  routes(Impl, <<"/twirp">>).
+
+-spec routes(module(), binary()) -> [{binary(), module(), map()}].
+routes(Impl, Prefix) ->
+  [{<<Prefix/binary, "/{{ .FullName }}/:method">>, ?MODULE,
+    #{impl => Impl}}].
+
+-spec init(cowboy_req:req(), map()) -> {ok, cowboy_req:req(), map()}.
+init(Req0, Options) ->
+  Req = case cowboy_req:method(Req0) of
+          <<"POST">> ->
+            handle(cowboy_req:binding(method, Req0), Req0, Options);
+          Method ->
+            reply_error({bad_route, [<<"unsupported method ">>, Method]},
+                        Req0)
+        end,
+  {ok, Req, Options}.
+
+-spec handle(binary(), cowboy_req:req(), map()) -> cowboy_req:req().
+{{- range .UnaryMethods }}
+handle(<<"{{ .Name }}">>, Req, Options) ->
+  call({{ .ErlName }}, Req, Options,
+       fun {{ .InputType.ErlPackage }}:decode_{{ .InputType.ErlName }}/1,
+       fun {{ .InputType.ErlPackage }}:from_json_{{ .InputType.ErlName }}/1,
+       fun {{ .OutputType.ErlPackage }}:encode_{{ .OutputType.ErlName }}/1,
+       fun {{ .OutputType.ErlPackage }}:to_json_{{ .OutputType.ErlName }}/1);
+{{- end }}
+handle(Method, Req, _Options) ->
+  reply_error({bad_route, [<<"unknown method ">>, Method]}, Req).
+{{- if .UnaryMethods }}
+
+-spec call(atom(), cowboy_req:req(), map(),
+           fun((binary()) -> {tuple(), binary()}),
+           fun((json:decode_value()) -> tuple()),
+           fun((tuple()) -> iodata()),
+           fun((tuple()) -> json:encode_value())) ->
+        cowboy_req:req().
+call(Function, Req0, Options = #{impl := Impl},
+     DecodeProtobuf, DecodeJSON, EncodeProtobuf, EncodeJSON) ->
+  case content_encoding(Req0) of
+    {ok, Encoding} ->
+      {ok, Body, Req} = read_body(Req0),
+      try
+        decode_request(Body, Encoding, DecodeProtobuf, DecodeJSON)
+      of
+        Request ->
+          Context = #{req => Req, options => Options},
+          try Impl:Function(Request, Context) of
+            {ok, Response} ->
+              reply(Response, Encoding, EncodeProtobuf, EncodeJSON, Req);
+            {error, Error} ->
+              reply_error(Error, Req)
+          catch
+            Class:Reason:Stacktrace ->
+              logger:error("~p:~p failed: ~p:~p~n~p",
+                           [Impl, Function, Class, Reason, Stacktrace]),
+              reply_error({internal, <<"internal error">>}, Req)
+          end
+      catch
+        _:_ ->
+          reply_error({malformed, <<"cannot decode request">>}, Req)
+      end;
+    error ->
+      reply_error({bad_route, <<"unsupported content type">>}, Req0)
+  end.
+
+-spec content_encoding(cowboy_req:req()) -> {ok, protobuf | json} | error.
+content_encoding(Req) ->
+  try cowboy_req:parse_header(<<"content-type">>, Req) of
+    {<<"application">>, <<"protobuf">>, _} ->
+      {ok, protobuf};
+    {<<"application">>, <<"json">>, _} ->
+      {ok, json};
+    _ ->
+      error
+  catch
+    _:_ ->
+      error
+  end.
+
+-spec read_body(cowboy_req:req()) -> {ok, binary(), cowboy_req:req()}.
+read_body(Req) ->
+  read_body(Req, <<>>).
+
+-spec read_body(cowboy_req:req(), binary()) ->
+        {ok, binary(), cowboy_req:req()}.
+read_body(Req0, Acc) ->
+  case cowboy_req:read_body(Req0) of
+    {ok, Data, Req} ->
+      {ok, <<Acc/binary, Data/binary>>, Req};
+    {more, Data, Req} ->
+      read_body(Req, <<Acc/binary, Data/binary>>)
+  end.
+
+-spec decode_request(binary(), protobuf | json,
+                     fun((binary()) -> {tuple(), binary()}),
+                     fun((json:decode_value()) -> tuple())) ->
+        tuple().
+decode_request(Body, protobuf, DecodeProtobuf, _DecodeJSON) ->
+  {Request, _} = DecodeProtobuf(Body),
+  Request;
+decode_request(Body, json, _DecodeProtobuf, DecodeJSON) ->
+  DecodeJSON(json:decode(Body)).
+
+-spec reply(tuple(), protobuf | json,
+            fun((tuple()) -> iodata()),
+            fun((tuple()) -> json:encode_value()),
+            cowboy_req:req()) ->
+        cowboy_req:req().
+reply(Response, protobuf, EncodeProtobuf, _EncodeJSON, Req) ->
+  cowboy_req:reply(200, #{<<"content-type">> => <<"application/protobuf">>},
+                   EncodeProtobuf(Response), Req);
+reply(Response, json, _EncodeProtobuf, EncodeJSON, Req) ->
+  cowboy_req:reply(200, #{<<"content-type">> => <<"application/json">>},
+                   json:encode(EncodeJSON(Response)), Req).
+{{- end }}
+
+-spec reply_error(twirp_error() | term(), cowboy_req:req()) ->
+        cowboy_req:req().
+reply_error({Code, Msg}, Req) ->
+  reply_error({Code, Msg, #{}}, Req);
+reply_error(Error = {Code, Msg, Meta}, Req) when is_atom(Code),
+                                                 is_map(Meta) ->
+  case http_status(Code) of
+    {ok, Status} ->
+      Value0 = #{<<"code">> => atom_to_binary(Code),
+                 <<"msg">> => unicode:characters_to_binary(Msg)},
+      Value = case map_size(Meta) of
+                0 -> Value0;
+                _ -> Value0#{<<"meta">> => Meta}
+              end,
+      cowboy_req:reply(Status,
+                       #{<<"content-type">> => <<"application/json">>},
+                       json:encode(Value), Req);
+    error ->
+      reply_invalid_error(Error, Req)
+  end;
+reply_error(Error, Req) ->
+  reply_invalid_error(Error, Req).
+
+-spec reply_invalid_error(term(), cowboy_req:req()) -> cowboy_req:req().
+reply_invalid_error(Error, Req) ->
+  logger:error("invalid twirp error ~p", [Error]),
+  reply_error({internal, <<"internal error">>}, Req).
+
+-spec http_status(atom()) -> {ok, cowboy:http_status()} | error.
+http_status(canceled) -> {ok, 408};
+http_status(unknown) -> {ok, 500};
+http_status(invalid_argument) -> {ok, 400};
+http_status(malformed) -> {ok, 400};
+http_status(deadline_exceeded) -> {ok, 408};
+http_status(not_found) -> {ok, 404};
+http_status(bad_route) -> {ok, 404};
+http_status(already_exists) -> {ok, 409};
+http_status(permission_denied) -> {ok, 403};
+http_status(unauthenticated) -> {ok, 401};
+http_status(resource_exhausted) -> {ok, 429};
+http_status(failed_precondition) -> {ok, 412};
+http_status(aborted) -> {ok, 409};
+http_status(out_of_range) -> {ok, 400};
+http_status(unimplemented) -> {ok, 501};
+http_status(internal) -> {ok, 500};
+http_status(unavailable) -> {ok, 503};
+http_status(data_loss) -> {ok, 500};
+http_status(_) -> error.
+`
+
+func ErlTwirpHandlerTemplate() (*template.Template, error) {
+	tpl := template.New("erl_twirp_handler")
+
+	if _, err := tpl.Parse(erlTwirpHandlerTemplateContent); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+
+	return tpl, nil
+}
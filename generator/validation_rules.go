@@ -0,0 +1,808 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Field constraints of protovalidate (the buf.validate.field extension, see
+// https://github.com/bufbuild/protovalidate) and of its predecessor
+// protoc-gen-validate (the validate.rules extension, see
+// https://github.com/bufbuild/protoc-gen-validate).
+//
+// Both use the same field numbers for type-specific rules, so we decode them
+// the same way. Rules we do not support, e.g. CEL expressions or well-known
+// string formats, and rules we do not know are listed in the Unsupported
+// member so that the generator can report them.
+const (
+	ProtoValidateExtensionNumber = 1159
+	PGVExtensionNumber           = 1071
+)
+
+type ValidationRule struct {
+	Name     string
+	ErlValue string // empty for rules without argument
+}
+
+type ValidationRules struct {
+	Type string // name of the type-specific rules, e.g. "string"
+
+	Required bool
+	Skip     bool // do not validate nested messages
+
+	// Do not validate empty values and, for IgnoreDefault, values equal to
+	// the default value of the field.
+	IgnoreEmpty   bool
+	IgnoreDefault bool
+
+	// The explicit default value of the field for IgnoreDefault, see
+	// SetDefaultValue.
+	ErlDefaultValue string
+
+	Rules []ValidationRule
+
+	Items  *ValidationRules // for repeated fields
+	Keys   *ValidationRules // for map fields
+	Values *ValidationRules // for map fields
+
+	Unsupported []string
+}
+
+var validationRuleTypes = map[int32]string{
+	1:  "float",
+	2:  "double",
+	3:  "int32",
+	4:  "int64",
+	5:  "uint32",
+	6:  "uint64",
+	7:  "sint32",
+	8:  "sint64",
+	9:  "fixed32",
+	10: "fixed64",
+	11: "sfixed32",
+	12: "sfixed64",
+	13: "bool",
+	14: "string",
+	15: "bytes",
+	16: "enum",
+	18: "repeated",
+	19: "map",
+	20: "any",
+	21: "duration",
+	22: "timestamp",
+}
+
+// ValidationRulesFromFieldOptions returns the validation rules of a field,
+// or nil if it has none. Protovalidate constraints have precedence over
+// protoc-gen-validate rules.
+func ValidationRulesFromFieldOptions(opts *descriptor.FieldOptions) (*ValidationRules, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	exts, err := RawExtension(opts, ProtoValidateExtensionNumber,
+		"buf.validate.field")
+	if err != nil {
+		return nil, err
+	}
+
+	protovalidate := len(exts) > 0
+
+	if !protovalidate {
+		exts, err = RawExtension(opts, PGVExtensionNumber,
+			"validate.rules")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(exts) == 0 {
+		return nil, nil
+	}
+
+	var fields RawFields
+	for _, ext := range exts {
+		extFields, err := DecodeRawFields(ext.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode validation rules: %w",
+				err)
+		}
+
+		fields = append(fields, extFields...)
+	}
+
+	var rules ValidationRules
+	if err := rules.FromRawFields(fields, protovalidate); err != nil {
+		return nil, err
+	}
+
+	return &rules, nil
+}
+
+func (rules *ValidationRules) FromRawFields(fields RawFields, protovalidate bool) error {
+	var r ValidationRules
+
+	for _, field := range fields {
+		if name, found := validationRuleTypes[field.Number]; found {
+			typeFields, err := DecodeRawFields(field.Bytes)
+			if err != nil {
+				return fmt.Errorf("cannot decode %s rules: %w",
+					name, err)
+			}
+
+			r.Type = name
+
+			if err := r.parseTypeRules(typeFields, protovalidate); err != nil {
+				return fmt.Errorf("invalid %s rules: %w", name, err)
+			}
+
+			continue
+		}
+
+		if protovalidate {
+			switch field.Number {
+			case 23, 29:
+				r.Unsupported = append(r.Unsupported, "cel")
+			case 24: // skipped, replaced by ignore
+				r.Skip = r.Skip || field.Varint != 0
+			case 25:
+				r.Required = field.Varint != 0
+			case 26: // ignore_empty, replaced by ignore
+				r.IgnoreEmpty = r.IgnoreEmpty || field.Varint != 0
+			case 27:
+				switch field.Varint {
+				case 1: // IGNORE_IF_UNPOPULATED
+					r.IgnoreEmpty = true
+				case 2: // IGNORE_IF_DEFAULT_VALUE
+					r.IgnoreDefault = true
+				case 3: // IGNORE_ALWAYS
+					r.Skip = true
+				}
+			case 28:
+				r.Unsupported = append(r.Unsupported, "field_mask")
+			default:
+				r.addUnsupportedField(field.Number)
+			}
+		} else if field.Number == 17 {
+			messageFields, err := DecodeRawFields(field.Bytes)
+			if err != nil {
+				return fmt.Errorf("cannot decode message rules: %w",
+					err)
+			}
+
+			for _, messageField := range messageFields {
+				switch messageField.Number {
+				case 1:
+					r.Skip = messageField.Varint != 0
+				case 2:
+					r.Required = messageField.Varint != 0
+				default:
+					r.Unsupported = append(r.Unsupported,
+						fmt.Sprintf("message.#%d", messageField.Number))
+				}
+			}
+		} else {
+			r.addUnsupportedField(field.Number)
+		}
+	}
+
+	// Ignored fields are not validated at all
+	if protovalidate && r.Skip {
+		r = ValidationRules{Type: r.Type, Skip: true}
+	}
+
+	*rules = r
+	return nil
+}
+
+func (r *ValidationRules) parseTypeRules(fields RawFields, protovalidate bool) error {
+	switch r.Type {
+	case "float", "double", "int32", "int64", "uint32", "uint64",
+		"sint32", "sint64", "fixed32", "fixed64", "sfixed32", "sfixed64":
+		return r.parseNumberRules(fields, protovalidate)
+
+	case "bool":
+		for _, field := range fields {
+			if field.Number == 1 {
+				r.addRule("const", strconv.FormatBool(field.Varint != 0))
+			} else {
+				r.parseOtherRule(field, protovalidate, 0, 2)
+			}
+		}
+
+	case "string":
+		return r.parseStringRules(fields, protovalidate)
+
+	case "bytes":
+		return r.parseBytesRules(fields, protovalidate)
+
+	case "enum":
+		for _, field := range fields {
+			switch field.Number {
+			case 1:
+				r.addRule("const", strconv.FormatInt(int64(field.Varint), 10))
+			case 2:
+				if field.Varint != 0 {
+					r.addRule("defined_only", "")
+				}
+			case 3, 4:
+				// See parseNumberListRules
+			default:
+				r.parseOtherRule(field, protovalidate, 0, 5)
+			}
+		}
+
+		return r.parseNumberListRules(fields, "int32", 3, 4)
+
+	case "repeated":
+		for _, field := range fields {
+			switch field.Number {
+			case 1:
+				r.addRule("min_items", strconv.FormatUint(field.Varint, 10))
+			case 2:
+				r.addRule("max_items", strconv.FormatUint(field.Varint, 10))
+			case 3:
+				if field.Varint != 0 {
+					r.addRule("unique", "")
+				}
+			case 4:
+				items, err := nestedValidationRules(field, protovalidate)
+				if err != nil {
+					return fmt.Errorf("invalid item rules: %w", err)
+				}
+
+				r.Items = items
+			default:
+				r.parseOtherRule(field, protovalidate, 5, 0)
+			}
+		}
+
+	case "map":
+		for _, field := range fields {
+			switch field.Number {
+			case 1:
+				r.addRule("min_pairs", strconv.FormatUint(field.Varint, 10))
+			case 2:
+				r.addRule("max_pairs", strconv.FormatUint(field.Varint, 10))
+			case 3:
+				if !protovalidate {
+					r.Unsupported = append(r.Unsupported, "map.no_sparse")
+				} else {
+					r.addUnsupportedField(field.Number)
+				}
+			case 4:
+				keys, err := nestedValidationRules(field, protovalidate)
+				if err != nil {
+					return fmt.Errorf("invalid key rules: %w", err)
+				}
+
+				r.Keys = keys
+			case 5:
+				values, err := nestedValidationRules(field, protovalidate)
+				if err != nil {
+					return fmt.Errorf("invalid value rules: %w", err)
+				}
+
+				r.Values = values
+			default:
+				r.parseOtherRule(field, protovalidate, 6, 0)
+			}
+		}
+
+	default:
+		r.Unsupported = append(r.Unsupported, r.Type)
+	}
+
+	return nil
+}
+
+func nestedValidationRules(field RawField, protovalidate bool) (*ValidationRules, error) {
+	fields, err := DecodeRawFields(field.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules ValidationRules
+	if err := rules.FromRawFields(fields, protovalidate); err != nil {
+		return nil, err
+	}
+
+	return &rules, nil
+}
+
+func (r *ValidationRules) parseNumberRules(fields RawFields, protovalidate bool) error {
+	names := map[int32]string{1: "const", 2: "lt", 3: "lte", 4: "gt", 5: "gte"}
+
+	isFloat := r.Type == "float" || r.Type == "double"
+
+	example := int32(8)
+	if isFloat {
+		example = 9
+	}
+
+	var lowerBounds, upperBounds []ValidationRule
+
+	for _, field := range fields {
+		name, found := names[field.Number]
+		if !found {
+			switch {
+			case field.Number == 6, field.Number == 7:
+				// See parseNumberListRules
+			case protovalidate && isFloat && field.Number == 8:
+				if field.Varint != 0 {
+					r.addRule("finite", "")
+				}
+			default:
+				r.parseOtherRule(field, protovalidate, 8, example)
+			}
+
+			continue
+		}
+
+		values, err := decodeValidationNumbers(field, r.Type)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %w", name, err)
+		}
+
+		for _, value := range values {
+			rule := ValidationRule{Name: name, ErlValue: value}
+
+			switch name {
+			case "lt", "lte":
+				upperBounds = append(upperBounds, rule)
+			case "gt", "gte":
+				lowerBounds = append(lowerBounds, rule)
+			default:
+				r.Rules = append(r.Rules, rule)
+			}
+		}
+	}
+
+	if err := r.addBoundRules(lowerBounds, upperBounds); err != nil {
+		return err
+	}
+
+	return r.parseNumberListRules(fields, r.Type, 6, 7)
+}
+
+// A lower and an upper bound define a range. If the upper bound is less than
+// the lower bound, the range is exclusive, i.e. values must be outside of the
+// range, e.g. {range, {gt, 10}, {lt, 5}, true} matches values less than 5 or
+// greater than 10.
+func (r *ValidationRules) addBoundRules(lowerBounds, upperBounds []ValidationRule) error {
+	if len(lowerBounds) != 1 || len(upperBounds) != 1 {
+		r.Rules = append(r.Rules, lowerBounds...)
+		r.Rules = append(r.Rules, upperBounds...)
+		return nil
+	}
+
+	lower, upper := lowerBounds[0], upperBounds[0]
+
+	cmp, err := compareValidationNumbers(upper.ErlValue, lower.ErlValue)
+	if err != nil {
+		return fmt.Errorf("invalid range: %w", err)
+	}
+
+	r.addRule("range", fmt.Sprintf("{%s, %s}, {%s, %s}, %t",
+		lower.Name, lower.ErlValue, upper.Name, upper.ErlValue, cmp < 0))
+
+	return nil
+}
+
+func (r *ValidationRules) parseNumberListRules(fields RawFields, typeName string, in, notIn int32) error {
+	var inValues, notInValues []string
+
+	for _, field := range fields {
+		if field.Number != in && field.Number != notIn {
+			continue
+		}
+
+		values, err := decodeValidationNumbers(field, typeName)
+		if err != nil {
+			return fmt.Errorf("invalid list value: %w", err)
+		}
+
+		if field.Number == in {
+			inValues = append(inValues, values...)
+		} else {
+			notInValues = append(notInValues, values...)
+		}
+	}
+
+	r.addListRules(inValues, notInValues)
+
+	return nil
+}
+
+func (r *ValidationRules) parseStringRules(fields RawFields, protovalidate bool) error {
+	lengths := map[int32]string{
+		19: "len", 2: "min_len", 3: "max_len",
+		20: "len_bytes", 4: "min_bytes", 5: "max_bytes",
+	}
+
+	strs := map[int32]string{
+		1: "const", 6: "pattern", 7: "prefix", 8: "suffix",
+		9: "contains", 23: "not_contains",
+	}
+
+	formats := map[int32]string{
+		12: "email", 13: "hostname", 14: "ip", 15: "ipv4", 16: "ipv6",
+		17: "uri", 18: "uri_ref", 21: "address", 22: "uuid",
+		24: "well_known_regex",
+	}
+
+	if protovalidate {
+		formats[26] = "ip_with_prefixlen"
+		formats[27] = "ipv4_with_prefixlen"
+		formats[28] = "ipv6_with_prefixlen"
+		formats[29] = "ip_prefix"
+		formats[30] = "ipv4_prefix"
+		formats[31] = "ipv6_prefix"
+		formats[32] = "host_and_port"
+		formats[33] = "tuuid"
+		formats[35] = "ulid"
+		formats[37] = "protobuf_fqn"
+		formats[38] = "protobuf_dot_fqn"
+	}
+
+	var inValues, notInValues []string
+
+	for _, field := range fields {
+		if name, found := lengths[field.Number]; found {
+			r.addRule(name, strconv.FormatUint(field.Varint, 10))
+		} else if name, found := strs[field.Number]; found {
+			r.addRule(name, ErlBinaryString(string(field.Bytes)))
+		} else if name, found := formats[field.Number]; found {
+			r.Unsupported = append(r.Unsupported, "string."+name)
+		} else if field.Number == 10 {
+			inValues = append(inValues,
+				ErlBinaryString(string(field.Bytes)))
+		} else if field.Number == 11 {
+			notInValues = append(notInValues,
+				ErlBinaryString(string(field.Bytes)))
+		} else if field.Number != 25 { // strict only applies to formats
+			r.parseOtherRule(field, protovalidate, 26, 34)
+		}
+	}
+
+	r.addListRules(inValues, notInValues)
+
+	return nil
+}
+
+func (r *ValidationRules) parseBytesRules(fields RawFields, protovalidate bool) error {
+	lengths := map[int32]string{13: "len", 2: "min_len", 3: "max_len"}
+
+	data := map[int32]string{
+		1: "const", 5: "prefix", 6: "suffix", 7: "contains",
+	}
+
+	formats := map[int32]string{10: "ip", 11: "ipv4", 12: "ipv6"}
+	if protovalidate {
+		formats[15] = "uuid"
+	}
+
+	var inValues, notInValues []string
+
+	for _, field := range fields {
+		if name, found := lengths[field.Number]; found {
+			r.addRule(name, strconv.FormatUint(field.Varint, 10))
+		} else if name, found := data[field.Number]; found {
+			r.addRule(name, ErlBinaryLiteral(field.Bytes, "      "))
+		} else if name, found := formats[field.Number]; found {
+			r.Unsupported = append(r.Unsupported, "bytes."+name)
+		} else if field.Number == 4 {
+			r.addRule("pattern", ErlBinaryString(string(field.Bytes)))
+		} else if field.Number == 8 {
+			inValues = append(inValues,
+				ErlBinaryLiteral(field.Bytes, "      "))
+		} else if field.Number == 9 {
+			notInValues = append(notInValues,
+				ErlBinaryLiteral(field.Bytes, "      "))
+		} else {
+			r.parseOtherRule(field, protovalidate, 14, 14)
+		}
+	}
+
+	r.addListRules(inValues, notInValues)
+
+	return nil
+}
+
+// parseOtherRule handles the ignore_empty rule of protoc-gen-validate and the
+// example rule of protovalidate, which has no effect on validation. Other
+// rules are unknown. Zero is used for rules which do not exist for the type.
+func (r *ValidationRules) parseOtherRule(field RawField, protovalidate bool, ignoreEmpty, example int32) {
+	switch {
+	case !protovalidate && field.Number == ignoreEmpty:
+		r.IgnoreEmpty = field.Varint != 0
+	case protovalidate && field.Number == example:
+		// Nothing to validate
+	default:
+		r.addUnsupportedField(field.Number)
+	}
+}
+
+// addUnsupportedField records an unknown rule by field number, e.g.
+// "string.#32".
+func (r *ValidationRules) addUnsupportedField(number int32) {
+	name := "#" + strconv.Itoa(int(number))
+	if r.Type != "" {
+		name = r.Type + "." + name
+	}
+
+	r.Unsupported = append(r.Unsupported, name)
+}
+
+func (r *ValidationRules) addRule(name, erlValue string) {
+	r.Rules = append(r.Rules, ValidationRule{Name: name, ErlValue: erlValue})
+}
+
+func (r *ValidationRules) addListRules(inValues, notInValues []string) {
+	if len(inValues) > 0 {
+		r.addRule("in", "["+strings.Join(inValues, ", ")+"]")
+	}
+
+	if len(notInValues) > 0 {
+		r.addRule("not_in", "["+strings.Join(notInValues, ", ")+"]")
+	}
+}
+
+// Numeric rules have the type of the field they apply to, and lists of
+// values can be packed or not.
+func decodeValidationNumbers(field RawField, typeName string) ([]string, error) {
+	if field.WireType != proto.WireBytes {
+		value, err := formatValidationNumber(field.Varint, typeName)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{value}, nil
+	}
+
+	var values []string
+
+	data := field.Bytes
+	for len(data) > 0 {
+		var n int
+		var number uint64
+
+		switch typeName {
+		case "float", "fixed32", "sfixed32":
+			if len(data) >= 4 {
+				number = uint64(binary.LittleEndian.Uint32(data))
+				n = 4
+			}
+		case "double", "fixed64", "sfixed64":
+			if len(data) >= 8 {
+				number = binary.LittleEndian.Uint64(data)
+				n = 8
+			}
+		default:
+			number, n = proto.DecodeVarint(data)
+		}
+
+		if n == 0 {
+			return nil, fmt.Errorf("truncated packed value")
+		}
+		data = data[n:]
+
+		value, err := formatValidationNumber(number, typeName)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func formatValidationNumber(number uint64, typeName string) (string, error) {
+	switch typeName {
+	case "float", "double":
+		var f float64
+		if typeName == "float" {
+			f = float64(math.Float32frombits(uint32(number)))
+		} else {
+			f = math.Float64frombits(number)
+		}
+
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("unsupported floating point value")
+		}
+
+		return ErlFloat(f), nil
+
+	case "int32", "int64":
+		return strconv.FormatInt(int64(number), 10), nil
+
+	case "sint32", "sint64":
+		return strconv.FormatInt(int64(number>>1)^-int64(number&1), 10), nil
+
+	case "sfixed32":
+		return strconv.FormatInt(int64(int32(uint32(number))), 10), nil
+
+	case "sfixed64":
+		return strconv.FormatInt(int64(number), 10), nil
+
+	default:
+		return strconv.FormatUint(number, 10), nil
+	}
+}
+
+func compareValidationNumbers(s1, s2 string) (int, error) {
+	n1, _, err := big.ParseFloat(s1, 10, 128, big.ToNearestEven)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q: %w", s1, err)
+	}
+
+	n2, _, err := big.ParseFloat(s2, 10, 128, big.ToNearestEven)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q: %w", s2, err)
+	}
+
+	return n1.Cmp(n2), nil
+}
+
+// SetDefaultValue records the explicit default value of the field the rules
+// apply to, if any, for IgnoreDefault. Values are represented as they are
+// compared by generated validation functions, i.e. strings as binaries and
+// enum values as integers.
+func (r *ValidationRules) SetDefaultValue(ft *FieldType) {
+	if !r.IgnoreDefault || ft.DefaultValue == "" {
+		return
+	}
+
+	switch ft.TypeId {
+	case FieldTypeIdString:
+		r.ErlDefaultValue = ErlBinaryString(ft.DefaultValue)
+	case FieldTypeIdEnum:
+		for _, v := range ft.EnumType.Values {
+			if v.Name == ft.DefaultValue {
+				r.ErlDefaultValue = strconv.Itoa(v.Number)
+				break
+			}
+		}
+	default:
+		r.ErlDefaultValue = ft.ErlDefaultValue
+	}
+}
+
+// CheckFieldType returns an error if the rules cannot be applied to a field
+// of a specific type.
+func (r *ValidationRules) CheckFieldType(ft *FieldType, repeated bool) error {
+	if r.Type == "" {
+		return nil
+	}
+
+	var expected string
+
+	switch {
+	case repeated && ft.IsMap():
+		expected = "map"
+	case repeated:
+		expected = "repeated"
+	case ft.TypeId == FieldTypeIdMessage:
+		switch ft.MessageType.WellKnownType {
+		case "Any", "Duration", "Timestamp":
+			expected = strings.ToLower(ft.MessageType.WellKnownType)
+		}
+	default:
+		expected = string(ft.TypeId)
+	}
+
+	if r.Type != expected {
+		return fmt.Errorf("%s rules cannot be applied to this field",
+			r.Type)
+	}
+
+	if r.Items != nil {
+		if err := r.Items.CheckFieldType(ft, false); err != nil {
+			return fmt.Errorf("invalid item rules: %w", err)
+		}
+	}
+
+	if r.Keys != nil || r.Values != nil {
+		key, value := ft.MessageType.MapEntryFields()
+
+		if r.Keys != nil {
+			if err := r.Keys.CheckFieldType(key, false); err != nil {
+				return fmt.Errorf("invalid key rules: %w", err)
+			}
+		}
+
+		if r.Values != nil {
+			if err := r.Values.CheckFieldType(value, false); err != nil {
+				return fmt.Errorf("invalid value rules: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AllUnsupported returns the unsupported rules of the field and of its
+// items, keys and values.
+func (r *ValidationRules) AllUnsupported() []string {
+	if r == nil {
+		return nil
+	}
+
+	names := r.Unsupported
+	names = append(names, r.Items.AllUnsupported()...)
+	names = append(names, r.Keys.AllUnsupported()...)
+	names = append(names, r.Values.AllUnsupported()...)
+
+	return names
+}
+
+// ErlTerm returns the list of rules used by generated validation functions,
+// e.g. [required, {min_len, 1}].
+func (r *ValidationRules) ErlTerm() string {
+	if r == nil {
+		return "[]"
+	}
+
+	var terms []string
+
+	if r.Required {
+		terms = append(terms, "required")
+	}
+
+	if r.Skip {
+		terms = append(terms, "skip")
+	}
+
+	if r.IgnoreEmpty || r.IgnoreDefault {
+		terms = append(terms, "ignore_empty")
+	}
+
+	if r.ErlDefaultValue != "" {
+		terms = append(terms, "{ignore_default, "+r.ErlDefaultValue+"}")
+	}
+
+	for _, rule := range r.Rules {
+		if rule.ErlValue == "" {
+			terms = append(terms, rule.Name)
+		} else {
+			terms = append(terms,
+				"{"+rule.Name+", "+rule.ErlValue+"}")
+		}
+	}
+
+	if r.Items != nil {
+		terms = append(terms, "{items, "+r.Items.ErlTerm()+"}")
+	}
+
+	if r.Keys != nil {
+		terms = append(terms, "{keys, "+r.Keys.ErlTerm()+"}")
+	}
+
+	if r.Values != nil {
+		terms = append(terms, "{values, "+r.Values.ErlTerm()+"}")
+	}
+
+	return "[" + strings.Join(terms, ", ") + "]"
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestValidationRulesFromRawFields(t *testing.T) {
+	varint := func(number int32, value int64) RawField {
+		return RawField{Number: number, WireType: proto.WireVarint,
+			Varint: uint64(value)}
+	}
+
+	double := func(number int32, value float64) RawField {
+		return RawField{Number: number, WireType: proto.WireFixed64,
+			Varint: math.Float64bits(value)}
+	}
+
+	str := func(number int32, value string) RawField {
+		return RawField{Number: number, WireType: proto.WireBytes,
+			Bytes: []byte(value)}
+	}
+
+	message := func(number int32, fields ...RawField) RawField {
+		return RawField{Number: number, WireType: proto.WireBytes,
+			Bytes: encodeRawFields(fields)}
+	}
+
+	tests := []struct {
+		name          string
+		fields        RawFields
+		protovalidate bool
+		term          string
+		unsupported   []string
+	}{
+		{"required string",
+			RawFields{
+				varint(25, 1),
+				message(14, varint(2, 1), varint(3, 64))},
+			true, "[required, {min_len, 1}, {max_len, 64}]", nil},
+		{"lower bound",
+			RawFields{message(3, varint(4, 1))},
+			true, "[{gt, 1}]", nil},
+		{"range",
+			RawFields{message(3, varint(5, 1), varint(3, 5))},
+			true, "[{range, {gte, 1}, {lte, 5}, false}]", nil},
+		{"exclusive range",
+			RawFields{message(3, varint(4, 10), varint(2, 5))},
+			true, "[{range, {gt, 10}, {lt, 5}, true}]", nil},
+		{"negative exclusive range",
+			RawFields{message(3, varint(4, -3), varint(2, -5))},
+			true, "[{range, {gt, -3}, {lt, -5}, true}]", nil},
+		{"finite double range",
+			RawFields{
+				message(2, double(4, 0.0), double(2, 1.5), varint(8, 1))},
+			true, "[finite, {range, {gt, 0.0}, {lt, 1.5}, false}]", nil},
+		{"ignore if unpopulated",
+			RawFields{varint(27, 1), message(3, varint(5, 1))},
+			true, "[ignore_empty, {gte, 1}]", nil},
+		{"ignore if default value",
+			RawFields{varint(27, 2), message(3, varint(5, 1))},
+			true, "[ignore_empty, {gte, 1}]", nil},
+		{"ignore always",
+			RawFields{varint(27, 3), message(3, varint(5, 1))},
+			true, "[skip]", nil},
+		{"pgv string ignore_empty",
+			RawFields{message(14, varint(2, 2), varint(26, 1))},
+			false, "[ignore_empty, {min_len, 2}]", nil},
+		{"pgv bytes ignore_empty",
+			RawFields{message(15, varint(3, 4), varint(14, 1))},
+			false, "[ignore_empty, {max_len, 4}]", nil},
+		{"pgv message rules",
+			RawFields{message(17, varint(1, 1), varint(2, 1))},
+			false, "[required, skip]", nil},
+		{"example",
+			RawFields{message(14, varint(2, 1), str(34, "foo"))},
+			true, "[{min_len, 1}]", nil},
+		{"repeated items",
+			RawFields{
+				message(18, varint(1, 1),
+					message(4, message(14, str(7, "k"))))},
+			true, `[{min_items, 1}, {items, [{prefix, <<"k">>}]}]`, nil},
+		{"unsupported format",
+			RawFields{message(14, varint(32, 1))},
+			true, "[]", []string{"string.host_and_port"}},
+		{"unknown string rule",
+			RawFields{message(14, varint(99, 1))},
+			true, "[]", []string{"string.#99"}},
+		{"unknown pgv string rule",
+			RawFields{message(14, varint(32, 1))},
+			false, "[]", []string{"string.#32"}},
+		{"cel",
+			RawFields{message(23, str(1, "x"))},
+			true, "[]", []string{"cel"}},
+		{"unknown field rule",
+			RawFields{varint(99, 1)},
+			true, "[]", []string{"#99"}},
+		{"unsupported item rule",
+			RawFields{
+				message(18, message(4, message(14, varint(12, 1))))},
+			true, "[{items, []}]", []string{"string.email"}},
+	}
+
+	for _, test := range tests {
+		var rules ValidationRules
+		if err := rules.FromRawFields(test.fields, test.protovalidate); err != nil {
+			t.Errorf("%s: cannot parse rules: %v", test.name, err)
+			continue
+		}
+
+		if term := rules.ErlTerm(); term != test.term {
+			t.Errorf("%s: term is %s but should be %s",
+				test.name, term, test.term)
+		}
+
+		unsupported := rules.AllUnsupported()
+		if !reflect.DeepEqual(unsupported, test.unsupported) {
+			t.Errorf("%s: unsupported rules are %q but should be %q",
+				test.name, unsupported, test.unsupported)
+		}
+	}
+}
+
+func encodeRawFields(fields RawFields) []byte {
+	var data []byte
+
+	for _, field := range fields {
+		key := uint64(field.Number)<<3 | uint64(field.WireType)
+		data = append(data, proto.EncodeVarint(key)...)
+
+		switch field.WireType {
+		case proto.WireVarint:
+			data = append(data, proto.EncodeVarint(field.Varint)...)
+		case proto.WireFixed64:
+			for i := 0; i < 8; i++ {
+				data = append(data, byte(field.Varint>>(8*i)))
+			}
+		case proto.WireBytes:
+			data = append(data,
+				proto.EncodeVarint(uint64(len(field.Bytes)))...)
+			data = append(data, field.Bytes...)
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+// Validation functions check messages against the rules of the
+// buf.validate.field and validate.rules field options. Nested messages are
+// validated with the validation function of their own package, so all
+// packages must be generated with the validate option.
+//
+// Field paths are lists of protobuf field names, indexes of repeated field
+// elements and keys of map field entries.
+var erlValidationTemplateContent = `
+{{- define "erl_message_validation" }}
+-spec validate_{{ .ErlName }}({{ .ErlTypeName }}()) ->
+        ok | {error, [validation_error()]}.
+{{- if .ValidatedFields }}
+validate_{{ .ErlName }}(Message) ->
+  validate_fields(
+    [
+    {{- $mt := . }}
+    {{- range $i, $f := .ValidatedFields }}
+    {{- if gt $i 0 }},
+     {{ end }}
+    {{- "" }}{<<"{{ $f.Name }}">>, Message#{{ $mt.ErlName }}.{{ if $f.OneofType }}{{ $f.OneofType.ErlName }}{{ else }}{{ $f.ErlName }}{{ end }},
+      {{ $f.ErlValidationType }},
+      {{ $f.ValidationRules.ErlTerm }}}
+    {{- end }}]).
+{{- else }}
+validate_{{ .ErlName }}(_) ->
+  ok.
+{{- end }}
+{{- end }}
+
+{{- define "erl_validation_support" }}
+-type validation_error() :: {Path :: [term()], Rule :: term(),
+                             Reason :: binary()}.
+
+-spec validate_fields([{binary(), term(), term(), [term()]}]) ->
+        ok | {error, [validation_error()]}.
+validate_fields(Fields) ->
+  case lists:append([validate_value([Name], Value, Type, Rules)
+                     || {Name, Value, Type, Rules} <- Fields]) of
+    [] ->
+      ok;
+    Errors ->
+      {error, Errors}
+  end.
+
+-spec validate_value([term()], term(), term(), [term()]) ->
+        [validation_error()].
+validate_value(Path, {Case, Value}, {oneof, Case, Type}, Rules) ->
+  validate_value(Path, Value, Type, Rules);
+validate_value(Path, _, {oneof, _, _}, Rules) ->
+  validate_unset(Path, Rules);
+validate_value(Path, undefined, Type, Rules)
+  when Type =:= message; element(1, Type) =:= message ->
+  validate_unset(Path, Rules);
+validate_value(Path, Value, Type, Rules) ->
+  case validation_is_ignored(Value, Type, Rules) of
+    true ->
+      validate_unset(Path, Rules);
+    false ->
+      Errors = [{Path, Rule, validation_reason(Rule)}
+                || Rule <- Rules, not validate_rule(Value, Type, Rule)],
+      Errors ++ validate_children(Path, Value, Type, Rules)
+  end.
+
+%% Ignored values are handled as unset values.
+-spec validation_is_ignored(term(), term(), [term()]) -> boolean().
+validation_is_ignored(Value, Type, Rules) ->
+  lists:any(fun (ignore_empty) ->
+                validation_is_empty(Value, Type);
+                ({ignore_default, Default}) ->
+                validate_rule(Value, Type, {const, Default});
+                (_) ->
+                false
+            end, Rules).
+
+-spec validate_unset([term()], [term()]) -> [validation_error()].
+validate_unset(Path, Rules) ->
+  case lists:member(required, Rules) of
+    true ->
+      [{Path, required, validation_reason(required)}];
+    false ->
+      []
+  end.
+
+-spec validate_children([term()], term(), term(), [term()]) ->
+        [validation_error()].
+validate_children(Path, Value, {message, Validate}, Rules) ->
+  case lists:member(skip, Rules) orelse Validate(Value) of
+    {error, Errors} ->
+      [{Path ++ ChildPath, Rule, Reason}
+       || {ChildPath, Rule, Reason} <- Errors];
+    _ ->
+      []
+  end;
+validate_children(Path, Values, {repeated, Type}, Rules) ->
+  ItemRules = proplists:get_value(items, Rules, []),
+  lists:append([validate_value(Path ++ [I], Value, Type, ItemRules)
+                || {I, Value} <- lists:enumerate(0, Values)]);
+validate_children(Path, Entries, {map, KeyType, ValueType}, Rules) ->
+  KeyRules = proplists:get_value(keys, Rules, []),
+  ValueRules = proplists:get_value(values, Rules, []),
+  lists:append([validate_value(Path ++ [Key], Key, KeyType, KeyRules) ++
+                  validate_value(Path ++ [Key], Value, ValueType, ValueRules)
+                || {Key, Value} <- validation_map_entries(Entries)]);
+validate_children(_, _, _, _) ->
+  [].
+
+-spec validate_rule(term(), term(), term()) -> boolean().
+validate_rule(_, _, Rule) when Rule =:= skip; Rule =:= ignore_empty ->
+  true;
+validate_rule(Value, Type, required) ->
+  not validation_is_empty(Value, Type);
+validate_rule(_, _, {Rule, _}) when Rule =:= items; Rule =:= keys;
+                                    Rule =:= values;
+                                    Rule =:= ignore_default ->
+  true;
+validate_rule(Value, {enum, _, Values}, defined_only) ->
+  lists:member(Value, Values());
+validate_rule(Value, {enum, ToInt, _}, Rule) ->
+  validate_rule(validation_enum_int(Value, ToInt), number, Rule);
+validate_rule(Value, string, Rule) ->
+  validate_string_rule(unicode:characters_to_binary(Value), string, Rule);
+validate_rule(Value, bytes, Rule) ->
+  validate_string_rule(iolist_to_binary(Value), bytes, Rule);
+validate_rule(Values, {repeated, _}, {min_items, N}) ->
+  length(Values) >= N;
+validate_rule(Values, {repeated, _}, {max_items, N}) ->
+  length(Values) =< N;
+validate_rule(Values, {repeated, _}, unique) ->
+  length(lists:usort(Values)) =:= length(Values);
+validate_rule(Entries, {map, _, _}, {min_pairs, N}) ->
+  length(validation_map_entries(Entries)) >= N;
+validate_rule(Entries, {map, _, _}, {max_pairs, N}) ->
+  length(validation_map_entries(Entries)) =< N;
+validate_rule(Value, _, finite) ->
+  is_number(Value);
+validate_rule(Value, Type, {range, Lower, Upper, false}) ->
+  validate_rule(Value, Type, Lower) andalso validate_rule(Value, Type, Upper);
+validate_rule(Value, Type, {range, Lower, Upper, true}) ->
+  validate_rule(Value, Type, Lower) orelse validate_rule(Value, Type, Upper);
+validate_rule(Value, _, {const, Const}) ->
+  Value == Const;
+validate_rule(nan, _, {Rule, _}) when Rule =:= lt; Rule =:= lte;
+                                      Rule =:= gt; Rule =:= gte ->
+  false;
+validate_rule(infinity, _, {Rule, _}) when Rule =:= lt; Rule =:= lte;
+                                           Rule =:= gt; Rule =:= gte ->
+  Rule =:= gt orelse Rule =:= gte;
+validate_rule('-infinity', _, {Rule, _}) when Rule =:= lt; Rule =:= lte;
+                                              Rule =:= gt; Rule =:= gte ->
+  Rule =:= lt orelse Rule =:= lte;
+validate_rule(Value, _, {lt, N}) ->
+  Value < N;
+validate_rule(Value, _, {lte, N}) ->
+  Value =< N;
+validate_rule(Value, _, {gt, N}) ->
+  Value > N;
+validate_rule(Value, _, {gte, N}) ->
+  Value >= N;
+validate_rule(Value, _, {in, Values}) ->
+  lists:any(fun (V) -> V == Value end, Values);
+validate_rule(Value, _, {not_in, Values}) ->
+  not lists:any(fun (V) -> V == Value end, Values).
+
+-spec validate_string_rule(binary(), string | bytes, term()) -> boolean().
+validate_string_rule(Value, Type, {len, N}) ->
+  validation_length(Value, Type) =:= N;
+validate_string_rule(Value, Type, {min_len, N}) ->
+  validation_length(Value, Type) >= N;
+validate_string_rule(Value, Type, {max_len, N}) ->
+  validation_length(Value, Type) =< N;
+validate_string_rule(Value, _, {len_bytes, N}) ->
+  byte_size(Value) =:= N;
+validate_string_rule(Value, _, {min_bytes, N}) ->
+  byte_size(Value) >= N;
+validate_string_rule(Value, _, {max_bytes, N}) ->
+  byte_size(Value) =< N;
+validate_string_rule(Value, string, {pattern, Pattern}) ->
+  re:run(Value, Pattern, [unicode, {capture, none}]) =:= match;
+validate_string_rule(Value, bytes, {pattern, Pattern}) ->
+  re:run(Value, Pattern, [{capture, none}]) =:= match;
+validate_string_rule(Value, _, {prefix, Prefix}) ->
+  binary:longest_common_prefix([Value, Prefix]) =:= byte_size(Prefix);
+validate_string_rule(Value, _, {suffix, Suffix}) ->
+  binary:longest_common_suffix([Value, Suffix]) =:= byte_size(Suffix);
+validate_string_rule(_, _, {contains, <<>>}) ->
+  true;
+validate_string_rule(Value, _, {contains, Part}) ->
+  binary:match(Value, Part) =/= nomatch;
+validate_string_rule(Value, Type, {not_contains, Part}) ->
+  not validate_string_rule(Value, Type, {contains, Part});
+validate_string_rule(Value, _, {const, Const}) ->
+  Value =:= Const;
+validate_string_rule(Value, _, {in, Values}) ->
+  lists:member(Value, Values);
+validate_string_rule(Value, _, {not_in, Values}) ->
+  not lists:member(Value, Values).
+
+%% The length of strings is their number of code points.
+-spec validation_length(binary(), string | bytes) -> non_neg_integer().
+validation_length(Value, string) ->
+  length(unicode:characters_to_list(Value));
+validation_length(Value, bytes) ->
+  byte_size(Value).
+
+-spec validation_is_empty(term(), term()) -> boolean().
+validation_is_empty(Value, string) ->
+  unicode:characters_to_binary(Value) =:= <<>>;
+validation_is_empty(Value, bytes) ->
+  iolist_size(Value) =:= 0;
+validation_is_empty(Value, bool) ->
+  Value =:= false;
+validation_is_empty(Value, number) ->
+  Value == 0;
+validation_is_empty(Value, {enum, ToInt, _}) ->
+  validation_enum_int(Value, ToInt) =:= 0;
+validation_is_empty(Value, {repeated, _}) ->
+  Value =:= [];
+validation_is_empty(Value, {map, _, _}) ->
+  validation_map_entries(Value) =:= [];
+validation_is_empty(_, _) ->
+  false.
+
+-spec validation_enum_int(atom() | integer(), fun((atom()) -> integer())) ->
+        integer().
+validation_enum_int(Value, _) when is_integer(Value) ->
+  Value;
+validation_enum_int(Value, ToInt) ->
+  ToInt(Value).
+
+-spec validation_map_entries(list() | map()) -> [{term(), term()}].
+validation_map_entries(Map) when is_map(Map) ->
+  lists:sort(maps:to_list(Map));
+validation_map_entries(Entries) ->
+  [{element(2, Entry), element(3, Entry)} || Entry <- Entries].
+
+-spec validation_reason(term()) -> binary().
+validation_reason(required) ->
+  <<"value is required">>;
+validation_reason(defined_only) ->
+  <<"value must be a defined enum value">>;
+validation_reason(unique) ->
+  <<"repeated value must contain unique items">>;
+validation_reason(finite) ->
+  <<"value must be finite">>;
+validation_reason({range, Lower, Upper, Exclusive}) ->
+  Operator = case Exclusive of
+               true -> <<" or ">>;
+               false -> <<" and ">>
+             end,
+  <<(validation_reason(Lower))/binary, Operator/binary,
+    (validation_reason(Upper))/binary>>;
+validation_reason({Rule, Value}) ->
+  Format = validation_reason_format(Rule),
+  unicode:characters_to_binary(io_lib:format(Format, [Value])).
+
+-spec validation_reason_format(atom()) -> string().
+validation_reason_format(const) -> "value must equal ~tp";
+validation_reason_format(lt) -> "value must be less than ~tp";
+validation_reason_format(lte) -> "value must be less than or equal to ~tp";
+validation_reason_format(gt) -> "value must be greater than ~tp";
+validation_reason_format(gte) -> "value must be greater than or equal to ~tp";
+validation_reason_format(in) -> "value must be in list ~tp";
+validation_reason_format(not_in) -> "value must not be in list ~tp";
+validation_reason_format(len) -> "value length must be ~b";
+validation_reason_format(min_len) -> "value length must be at least ~b";
+validation_reason_format(max_len) -> "value length must be at most ~b";
+validation_reason_format(len_bytes) -> "value length must be ~b bytes";
+validation_reason_format(min_bytes) -> "value length must be at least ~b bytes";
+validation_reason_format(max_bytes) -> "value length must be at most ~b bytes";
+validation_reason_format(pattern) -> "value does not match regex pattern ~tp";
+validation_reason_format(prefix) -> "value does not have prefix ~tp";
+validation_reason_format(suffix) -> "value does not have suffix ~tp";
+validation_reason_format(contains) -> "value does not contain ~tp";
+validation_reason_format(not_contains) -> "value contains ~tp";
+validation_reason_format(min_items) -> "value must contain at least ~b item(s)";
+validation_reason_format(max_items) -> "value must contain no more than ~b item(s)";
+validation_reason_format(min_pairs) -> "map must have at least ~b entries";
+validation_reason_format(max_pairs) -> "map must have at most ~b entries".
+{{- end }}
+`
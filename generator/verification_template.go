@@ -0,0 +1,245 @@
+// Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package generator
+
+// Verification functions check that the values of a record match the type
+// specifications of the record, as opposed to validation functions which
+// check schema-level rules. Nested messages are verified with the
+// verification function of their own package, so all packages must be
+// generated with the verify option.
+//
+// Errors contain the path of the invalid value: record field names, indexes
+// of list elements, keys of maps and cases of oneofs.
+var erlVerificationTemplateContent = `
+{{- define "erl_message_verification" }}
+-spec verify_{{ .ErlName }}(term()) -> ok | {error, verification_error()}.
+{{- $mt := . }}
+verify_{{ .ErlName }}(Message) when is_record(Message, {{ .ErlName }}) ->
+  verify_fields(
+    [
+    {{- $first := true }}
+    {{- range .ErlVerifyFields }}
+    {{- if $first }}{{ $first = false }}{{ else }},
+     {{ end }}
+    {{- "" }}{ {{- .ErlName }}, Message#{{ $mt.ErlName }}.{{ .ErlName }},
+      {{ .ErlVerifyType }}}
+    {{- end }}
+    {{- range .Oneofs }}
+    {{- if $first }}{{ $first = false }}{{ else }},
+     {{ end }}
+    {{- "" }}{ {{- .ErlName }}, Message#{{ $mt.ErlName }}.{{ .ErlName }},
+      {{ .ErlVerifyType }}}
+    {{- end }}
+    {{- if not $first }},
+     {{ end }}
+    {{- "" }}{'$unknown', Message#{{ $mt.ErlName }}.'$unknown',
+      {repeated, unknown_field}}]);
+verify_{{ .ErlName }}(Value) ->
+  verify_error(Value, {message, {{ .ErlPackage }}, {{ .ErlName }}, undefined}).
+{{- end }}
+
+{{- define "erl_verification_support" }}
+-type verification_error() :: {Path :: [term()], Reason :: term()}.
+
+-spec verify_fields([{atom(), term(), term()}]) ->
+        ok | {error, verification_error()}.
+verify_fields([]) ->
+  ok;
+verify_fields([{Name, Value, Type} | Fields]) ->
+  case verify_value(Value, Type) of
+    ok ->
+      verify_fields(Fields);
+    {error, {Path, Reason}} ->
+      {error, {[Name | Path], Reason}}
+  end.
+
+-spec verify_value(term(), term()) -> ok | {error, verification_error()}.
+verify_value(undefined, {optional, _}) ->
+  ok;
+verify_value(Value, {optional, Type}) ->
+  verify_value(Value, Type);
+verify_value(undefined, {oneof, _}) ->
+  ok;
+verify_value({Case, Value}, {oneof, Cases} = Type) when is_atom(Case) ->
+  case lists:keyfind(Case, 1, Cases) of
+    {Case, CaseType} ->
+      case verify_value(Value, CaseType) of
+        ok ->
+          ok;
+        {error, {Path, Reason}} ->
+          {error, {[Case | Path], Reason}}
+      end;
+    false ->
+      verify_error({Case, Value}, Type)
+  end;
+verify_value(Values, {repeated, Type}) when is_list(Values) ->
+  verify_elements(Values, Type, 0);
+verify_value(Map, {map, KeyType, ValueType}) when is_map(Map) ->
+  verify_entries(lists:sort(maps:to_list(Map)), KeyType, ValueType);
+verify_value(Value, {message, _, Name, Verify})
+  when is_tuple(Value), tuple_size(Value) > 0, element(1, Value) =:= Name ->
+  Verify(Value);
+verify_value(Value, {record, _, Name})
+  when is_tuple(Value), tuple_size(Value) > 0, element(1, Value) =:= Name ->
+  ok;
+verify_value(Value, {enum, _, _, Values} = Type) when is_atom(Value) ->
+  case lists:member(Value, Values()) of
+    true ->
+      ok;
+    false ->
+      verify_error(Value, Type)
+  end;
+verify_value(Value, {enum, _, _, _})
+  when is_integer(Value), Value >= -16#80000000, Value =< 16#7fffffff ->
+  ok;
+verify_value(Value, {integer, Min, Max})
+  when is_integer(Value), Value >= Min, Value =< Max ->
+  ok;
+verify_value(Value, float) when is_float(Value) ->
+  ok;
+verify_value(Value, float) when Value =:= infinity; Value =:= '-infinity';
+                                Value =:= nan ->
+  ok;
+verify_value({Number, Data}, unknown_field)
+  when is_integer(Number), Number > 0, is_binary(Data) ->
+  ok;
+verify_value(Value, boolean) when is_boolean(Value) ->
+  ok;
+verify_value(Value, binary) when is_binary(Value) ->
+  ok;
+verify_value(Value, iodata) ->
+  case verify_iodata(Value) of
+    true ->
+      ok;
+    false ->
+      verify_error(Value, iodata)
+  end;
+verify_value(Value, chardata) ->
+  case verify_chardata(Value) of
+    true ->
+      ok;
+    false ->
+      verify_error(Value, chardata)
+  end;
+verify_value(Value, string) ->
+  case verify_string(Value) of
+    true ->
+      ok;
+    false ->
+      verify_error(Value, string)
+  end;
+verify_value(Value, Type) ->
+  verify_error(Value, Type).
+
+-spec verify_elements(list(), term(), non_neg_integer()) ->
+        ok | {error, verification_error()}.
+verify_elements([], _, _) ->
+  ok;
+verify_elements([Value | Values], Type, I) ->
+  case verify_value(Value, Type) of
+    ok ->
+      verify_elements(Values, Type, I + 1);
+    {error, {Path, Reason}} ->
+      {error, {[I | Path], Reason}}
+  end.
+
+-spec verify_entries([{term(), term()}], term(), term()) ->
+        ok | {error, verification_error()}.
+verify_entries([], _, _) ->
+  ok;
+verify_entries([{Key, Value} | Entries], KeyType, ValueType) ->
+  case verify_value(Key, KeyType) of
+    ok ->
+      case verify_value(Value, ValueType) of
+        ok ->
+          verify_entries(Entries, KeyType, ValueType);
+        {error, {Path, Reason}} ->
+          {error, {[Key | Path], Reason}}
+      end;
+    {error, {_, Reason}} ->
+      {error, {[Key], Reason}}
+  end.
+
+-spec verify_iodata(term()) -> boolean().
+verify_iodata(Value) when is_binary(Value) ->
+  true;
+verify_iodata(Value) when is_list(Value) ->
+  try
+    _ = iolist_size(Value),
+    true
+  catch
+    error:badarg ->
+      false
+  end;
+verify_iodata(_) ->
+  false.
+
+-spec verify_chardata(term()) -> boolean().
+verify_chardata(Value) when is_binary(Value); is_list(Value) ->
+  try
+    is_binary(unicode:characters_to_binary(Value))
+  catch
+    error:badarg ->
+      false
+  end;
+verify_chardata(_) ->
+  false.
+
+-spec verify_string(term()) -> boolean().
+verify_string(Value) when is_list(Value) ->
+  lists:all(fun (C) -> is_integer(C) andalso C >= 0 andalso C =< 16#10ffff end,
+            Value);
+verify_string(_) ->
+  false.
+
+-spec verify_error(term(), term()) -> {error, verification_error()}.
+verify_error(Value, Type) ->
+  {error, {[], {invalid_value, Value, verify_expected(Type)}}}.
+
+%% Return the type specification a value was expected to match.
+-spec verify_expected(term()) -> binary().
+verify_expected({optional, Type}) ->
+  <<"undefined | ", (verify_expected(Type))/binary>>;
+verify_expected({oneof, Cases}) ->
+  Specs = [io_lib:format("{~p, ~s}", [Case, verify_expected(Type)])
+           || {Case, Type} <- Cases],
+  iolist_to_binary(lists:join(" | ", ["undefined" | Specs]));
+verify_expected({repeated, Type}) ->
+  <<"list(", (verify_expected(Type))/binary, ")">>;
+verify_expected({map, KeyType, ValueType}) ->
+  <<"#{", (verify_expected(KeyType))/binary, " => ",
+    (verify_expected(ValueType))/binary, "}">>;
+verify_expected({message, Module, Name, _}) ->
+  iolist_to_binary(io_lib:format("~p:~p()", [Module, Name]));
+verify_expected({record, Module, Name}) ->
+  iolist_to_binary(io_lib:format("~p:~p()", [Module, Name]));
+verify_expected({enum, Module, Name, _}) ->
+  iolist_to_binary(io_lib:format("~p:~p() | integer()", [Module, Name]));
+verify_expected(unknown_field) ->
+  <<"{pos_integer(), binary()}">>;
+verify_expected(chardata) ->
+  <<"unicode:chardata()">>;
+verify_expected({integer, Min, Max}) ->
+  iolist_to_binary(io_lib:format("~b..~b", [Min, Max]));
+verify_expected(Type) ->
+  <<(atom_to_binary(Type))/binary, "()">>.
+
+-spec verify_before_encode(atom(), ok | {error, verification_error()}) -> ok.
+verify_before_encode(_, ok) ->
+  ok;
+verify_before_encode(MessageName, {error, Error}) ->
+  error({invalid_message, MessageName, Error}).
+{{- end }}
+`
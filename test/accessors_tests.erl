@@ -0,0 +1,75 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of generated record constructors and accessors.
+
+-module(accessors_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("accessors.hrl").
+
+new_test() ->
+  ?assertEqual(#book{}, accessors:new_book()),
+  ?assertEqual(#book_author{}, accessors:new_book_author()).
+
+new_from_list_test() ->
+  Author = accessors:new_book_author([{name, <<"Jane">>}]),
+  ?assertEqual(#book{title = <<"Dune">>, tags = [<<"sf">>], author = Author},
+               accessors:new_book([{title, <<"Dune">>},
+                                   {tags, [<<"sf">>]},
+                                   {author, Author}])).
+
+new_from_map_test() ->
+  ?assertEqual(#book{title = <<"Dune">>, price = {cents, 999}},
+               accessors:new_book(#{title => <<"Dune">>, cents => 999})).
+
+new_oneof_test() ->
+  ?assertEqual(#book{price = {free, true}},
+               accessors:new_book([{price, {free, true}}])),
+  %% Later values select another case of the oneof
+  ?assertEqual(#book{price = {free, true}},
+               accessors:new_book([{cents, 999}, {free, true}])).
+
+new_unknown_field_test() ->
+  ?assertError({unknown_field, book, unknown},
+               accessors:new_book([{unknown, 1}])),
+  ?assertError({unknown_field, book, unknown},
+               accessors:new_book(#{unknown => 1})),
+  %% Deprecated fields cannot be set
+  ?assertError({unknown_field, book, isbn},
+               accessors:new_book([{isbn, <<"0441013597">>}])).
+
+get_set_test() ->
+  Book = accessors:set_book_title(<<"Dune">>, accessors:new_book()),
+  ?assertEqual(<<"Dune">>, accessors:get_book_title(Book)),
+  ?assertEqual([], accessors:get_book_tags(Book)),
+  ?assertEqual(undefined, accessors:get_book_author(Book)),
+  Book2 = accessors:set_book_tags([<<"sf">>, <<"classic">>], Book),
+  ?assertEqual([<<"sf">>, <<"classic">>], accessors:get_book_tags(Book2)),
+  ?assertEqual(<<"Dune">>, accessors:get_book_title(Book2)).
+
+oneof_test() ->
+  Book = accessors:new_book(),
+  ?assertEqual(undefined, accessors:which_book_price(Book)),
+  ?assertEqual(undefined, accessors:get_book_cents(Book)),
+  Book2 = accessors:set_book_cents(999, Book),
+  ?assertEqual(cents, accessors:which_book_price(Book2)),
+  ?assertEqual(999, accessors:get_book_cents(Book2)),
+  ?assertEqual(undefined, accessors:get_book_free(Book2)),
+  Book3 = accessors:set_book_free(true, Book2),
+  ?assertEqual(free, accessors:which_book_price(Book3)),
+  ?assertEqual(undefined, accessors:get_book_cents(Book3)),
+  ?assertEqual(true, accessors:get_book_free(Book3)),
+  ?assertEqual(#book{price = {free, true}}, Book3).
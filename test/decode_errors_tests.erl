@@ -0,0 +1,69 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of the errors signaled by decoding functions.
+
+-module(decode_errors_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("decode_errors.hrl").
+
+truncated_varint_test() ->
+  ?assertEqual({error, {invalid_data, truncated_varint, 3, <<"book">>}},
+               decode_errors:decode_book(<<10, 1, $a, 128>>, [])),
+  ?assertEqual({error, {invalid_data, truncated_varint, 0,
+                        <<"book.count">>}},
+               decode_errors:decode_book(<<40, 128>>, [])).
+
+invalid_varint_test() ->
+  Data = <<40, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 1>>,
+  ?assertEqual({error, {invalid_data, invalid_varint, 0, <<"book.count">>}},
+               decode_errors:decode_book(Data, [])).
+
+truncated_data_test() ->
+  ?assertEqual({error, {invalid_data, truncated_data, 0, <<"book.id">>}},
+               decode_errors:decode_book(<<29, 1, 0>>, [])),
+  ?assertEqual({error, {invalid_data, truncated_data, 3, <<"book.cover">>}},
+               decode_errors:decode_book(<<10, 1, $a, 34, 5, 1, 2>>, [])).
+
+invalid_wire_type_test() ->
+  ?assertEqual({error, {invalid_data, {invalid_wire_type, 0}, 0,
+                        <<"book.title">>}},
+               decode_errors:decode_book(<<8, 1>>, [])).
+
+invalid_utf8_string_test() ->
+  ?assertEqual({error, {invalid_data, {invalid_utf8_string, <<255>>}, 0,
+                        <<"book.title">>}},
+               decode_errors:decode_book(<<10, 1, 255>>, [])).
+
+nested_error_test() ->
+  %% Errors are located in the innermost message
+  Data = <<18, 0, 18, 0, 18, 2, 18, 0>>,
+  ?assertEqual({error, {invalid_data, {invalid_wire_type, 2}, 6,
+                        <<"book.publications[2].status">>}},
+               decode_errors:decode_book(Data, [])),
+  ?assertEqual({error, {invalid_data, truncated_data, 4,
+                        <<"book.publications[1].publisher">>}},
+               decode_errors:decode_book(<<18, 0, 18, 3, 10, 2, $a>>, [])).
+
+decode_test() ->
+  ?assertEqual({#book{title = <<"a">>}, <<>>},
+               decode_errors:decode_book(<<10, 1, $a>>)),
+  ?assertError({decode_error, {invalid_data, {invalid_wire_type, 0}, 0,
+                               <<"book.title">>}},
+               decode_errors:decode_book(<<8, 1>>)),
+  ?assertError({decode_error, {invalid_data, truncated_varint, 3,
+                               <<"book">>}},
+               decode_errors:decode_book([<<10, 1>>, $a, <<128>>])).
@@ -0,0 +1,117 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of decoding limits and of the representation of unknown enum
+%%% values.
+
+-module(decode_limits_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("decode_limits.hrl").
+
+max_size_test() ->
+  Data = <<10, 3, "abc">>,
+  ?assertEqual({error, {limit_exceeded, max_size, <<"book">>}},
+               decode_limits:decode_book(Data, [{max_size, 4}])),
+  ?assertEqual({ok, #book{title = <<"abc">>}},
+               decode_limits:decode_book(Data, [{max_size, 5}])).
+
+max_depth_test() ->
+  Data = <<34, 2, 34, 0>>,
+  ?assertEqual({error, {limit_exceeded, max_depth, <<"book.sequel.sequel">>}},
+               decode_limits:decode_book(Data, [{max_depth, 1}])),
+  ?assertEqual({ok, #book{sequel = #book{sequel = #book{}}}},
+               decode_limits:decode_book(Data, [{max_depth, 2}])).
+
+max_repeated_test() ->
+  Data = <<18, 0, 18, 0, 18, 0>>,
+  ?assertEqual({error, {limit_exceeded, max_repeated,
+                        <<"book.publications[2]">>}},
+               decode_limits:decode_book(Data, [{max_repeated, 2}])),
+  ?assertEqual({ok, #book{publications = [#publication{}, #publication{},
+                                          #publication{}]}},
+               decode_limits:decode_book(Data, [{max_repeated, 3}])).
+
+max_repeated_packed_test() ->
+  Data = <<58, 3, 0, 1, 2>>,
+  ?assertEqual({error, {limit_exceeded, max_repeated, <<"book.statuses[2]">>}},
+               decode_limits:decode_book(Data, [{max_repeated, 2}])).
+
+max_repeated_map_test() ->
+  Data = <<26, 5, 10, 1, $a, 16, 1, 26, 5, 10, 1, $b, 16, 2>>,
+  ?assertEqual({error, {limit_exceeded, max_repeated, <<"book.ratings[1]">>}},
+               decode_limits:decode_book(Data, [{max_repeated, 1}])),
+  ?assertEqual({ok, #book{ratings = [#book_ratings_entry{key = <<"a">>,
+                                                         value = 1},
+                                     #book_ratings_entry{key = <<"b">>,
+                                                         value = 2}]}},
+               decode_limits:decode_book(Data, [{max_repeated, 2}])).
+
+max_string_length_test() ->
+  ?assertEqual({error, {limit_exceeded, max_string_length, <<"book.title">>}},
+               decode_limits:decode_book(<<10, 3, "abc">>,
+                                         [{max_string_length, 2}])),
+  ?assertEqual({error, {limit_exceeded, max_string_length, <<"book.cover">>}},
+               decode_limits:decode_book(<<42, 3, 1, 2, 3>>,
+                                         [{max_string_length, 2}])),
+  ?assertEqual({error, {limit_exceeded, max_string_length,
+                        <<"book.publications[0].publisher">>}},
+               decode_limits:decode_book(<<18, 5, 10, 3, "abc">>,
+                                         [{max_string_length, 2}])),
+  ?assertEqual({ok, #book{title = <<"abc">>}},
+               decode_limits:decode_book(<<10, 3, "abc">>,
+                                         [{max_string_length, 3}])).
+
+unknown_enum_value_test() ->
+  ?assertEqual({ok, #book{status = 5}},
+               decode_limits:decode_book(<<48, 5>>, [])),
+  ?assertEqual({ok, #book{status = -1}},
+               decode_limits:decode_book(
+                 <<48, 255, 255, 255, 255, 255, 255, 255, 255, 255, 1>>, [])),
+  ?assertEqual({ok, #book{statuses = [status_draft, 7]}},
+               decode_limits:decode_book(<<58, 2, 1, 7>>, [])),
+  ?assertEqual({ok, #book{publications = [#publication{status = 9}]}},
+               decode_limits:decode_book(<<18, 2, 16, 9>>, [])).
+
+unknown_enum_value_encode_test() ->
+  ?assertEqual(<<48, 5>>, decode_limits:encode_book(#book{status = 5})),
+  ?assertEqual(<<58, 2, 1, 7>>,
+               decode_limits:encode_book(
+                 #book{statuses = [status_draft, 7]})),
+  ?assertEqual(2, decode_limits:encoded_size_book(#book{status = 5})).
+
+closed_enums_test() ->
+  ?assertEqual({ok, #book{status = status_draft}},
+               decode_limits:decode_book(<<48, 1>>, [closed_enums])),
+  ?assertEqual({error, {invalid_data, {unknown_enum_value, 5}, 0,
+                        <<"book.status">>}},
+               decode_limits:decode_book(<<48, 5>>, [closed_enums])),
+  ?assertEqual({error, {invalid_data, {unknown_enum_value, 9}, 2,
+                        <<"book.publications[0].status">>}},
+               decode_limits:decode_book(<<18, 2, 16, 9>>, [closed_enums])).
+
+unknown_enum_value_verify_test() ->
+  ?assertEqual(ok, decode_limits:verify_book(#book{status = 5})),
+  ?assertEqual(ok, decode_limits:verify_book(#book{statuses = [-1]})),
+  ?assertMatch({error, {[status], {invalid_value, 16#80000000, _}}},
+               decode_limits:verify_book(#book{status = 16#80000000})).
+
+unknown_enum_value_json_test() ->
+  ?assertEqual(#{<<"status">> => 5},
+               decode_limits:to_json_book(#book{status = 5})),
+  ?assertEqual(#book{status = 5},
+               decode_limits:from_json_book(#{<<"status">> => 5})),
+  ?assertEqual(#book{status = status_draft},
+               decode_limits:from_json_book(#{<<"status">> => 1})).
@@ -0,0 +1,65 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of serialized file descriptors embedded in generated modules.
+%%%
+%%% Descriptors are decoded with the module generated for the google.protobuf
+%%% package.
+
+-module(descriptors_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("google_protobuf.hrl").
+
+descriptor_test() ->
+  {ok, Set} = google_protobuf:decode_file_descriptor_set(
+                descriptors:descriptor(), []),
+  Files = Set#file_descriptor_set.file,
+  %% Dependencies come before the files which import them
+  ?assertEqual([<<"google/protobuf/descriptor.proto">>,
+                <<"descriptors.proto">>],
+               [File#file_descriptor_proto.name || File <- Files]),
+  File = lists:last(Files),
+  ?assertEqual(<<"descriptors">>, File#file_descriptor_proto.package),
+  ?assertEqual([<<"google/protobuf/descriptor.proto">>],
+               File#file_descriptor_proto.dependency),
+  ?assertEqual([<<"Library">>, <<"Book">>],
+               [Message#descriptor_proto.name
+                || Message <- File#file_descriptor_proto.message_type]),
+  %% Source code information is not embedded
+  ?assertEqual(undefined, File#file_descriptor_proto.source_code_info).
+
+descriptor_entries_test() ->
+  {ok, Data1} = descriptors:file_descriptor_proto(
+                  <<"google/protobuf/descriptor.proto">>),
+  {ok, Data2} = descriptors:file_descriptor_proto(<<"descriptors.proto">>),
+  Entries = [[<<10>>, encode_varint(byte_size(Data)), Data]
+             || Data <- [Data1, Data2]],
+  ?assertEqual(iolist_to_binary(Entries), descriptors:descriptor()).
+
+file_descriptor_proto_test() ->
+  {ok, Data} = descriptors:file_descriptor_proto(<<"descriptors.proto">>),
+  ?assertEqual({ok, Data},
+               descriptors:file_descriptor_proto("descriptors.proto")),
+  ?assertEqual({ok, Data},
+               descriptors:file_descriptor_proto([<<"descriptors">>, ".proto"])),
+  {ok, File} = google_protobuf:decode_file_descriptor_proto(Data, []),
+  ?assertEqual(<<"descriptors.proto">>, File#file_descriptor_proto.name),
+  ?assertEqual(error, descriptors:file_descriptor_proto(<<"unknown.proto">>)).
+
+encode_varint(Value) when Value < 128 ->
+  <<Value>>;
+encode_varint(Value) ->
+  <<1:1, (Value band 127):7, (encode_varint(Value bsr 7))/binary>>.
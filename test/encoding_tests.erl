@@ -0,0 +1,120 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of generated encoding and size functions.
+
+-module(encoding_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("encoding.hrl").
+
+encode_test() ->
+  ?assertEqual(<<>>, encoding:encode_values(#values{})),
+  ?assertEqual(<<8, 150, 1>>, encoding:encode_value(#value{number = 150})),
+  ?assertEqual(<<8, 255, 255, 255, 255, 255, 255, 255, 255, 255, 1>>,
+               encoding:encode_value(#value{number = -1})),
+  ?assertEqual(<<16, 1, 29, 1, 0, 0, 0, 33, 0, 0, 0, 0, 0, 0, 240, 63, 40, 1>>,
+               encoding:encode_values(#values{sint64 = -1, fixed32 = 1,
+                                              double = 1.0, bool = true})).
+
+encode_keys_test() ->
+  %% Keys of fields 16 and 2048 are encoded on two and three bytes
+  ?assertEqual(<<130, 1, 1, $a>>,
+               encoding:encode_values(#values{text = <<"a">>})),
+  ?assertEqual(<<130, 128, 1, 2, 1, 2>>,
+               encoding:encode_values(#values{data = [1, <<2>>]})).
+
+encode_nested_test() ->
+  ?assertEqual(<<50, 3, 8, 150, 1>>,
+               encoding:encode_values(
+                 #values{value = #value{number = 150}})),
+  ?assertEqual(<<58, 0, 58, 2, 8, 1>>,
+               encoding:encode_values(
+                 #values{values = [#value{}, #value{number = 1}]})),
+  %% Length prefixes of nested messages can span several bytes
+  Text = binary:copy(<<"x">>, 200),
+  Message = #values{value = #value{text = Text}},
+  Data = encoding:encode_values(Message),
+  ?assertEqual(<<50, 203, 1, 18, 200, 1, Text/binary>>, Data),
+  ?assertEqual(206, encoding:encoded_size_values(Message)).
+
+encode_packed_test() ->
+  ?assertEqual(<<66, 3, 1, 172, 2>>,
+               encoding:encode_values(#values{numbers = [1, 300]})).
+
+encoded_size_test() ->
+  Value = #value{number = -42, text = <<"abc">>},
+  Messages = [#values{},
+              #values{int32 = -1, sint64 = -9223372036854775808,
+                      fixed32 = 4294967295, double = -0.5, bool = true,
+                      text = <<"héllo"/utf8>>,
+                      data = binary:copy(<<0>>, 300),
+                      value = Value,
+                      values = lists:duplicate(100, Value),
+                      numbers = lists:seq(-100, 100000, 1000)}],
+  lists:foreach(fun (Message) ->
+                    Data = encoding:encode_values(Message),
+                    ?assertEqual(byte_size(Data),
+                                 encoding:encoded_size_values(Message)),
+                    ?assertEqual({ok, Message},
+                                 encoding:decode_values(Data, []))
+                end, Messages),
+  %% Sizes do not depend on the representation of strings and bytes
+  Value2 = Value#value{text = ["a", <<"bc">>]},
+  ?assertEqual(encoding:encoded_size_value(Value),
+               encoding:encoded_size_value(Value2)).
+
+unordered() ->
+  Entry = fun (Key, Value) ->
+              #unordered_counts_entry{key = Key, value = Value}
+          end,
+  #unordered{c = <<"c">>, choice = {e, 5}, a = 1,
+             counts = [Entry(<<"b">>, 2), Entry(<<"a">>, 1)],
+             values = #{2 => #value{number = 1}, 1 => #value{}}}.
+
+deterministic_test() ->
+  ?assertEqual(<<8, 1,
+                 26, 1, $c,
+                 34, 5, 10, 1, $a, 16, 1,
+                 34, 5, 10, 1, $b, 16, 2,
+                 40, 5,
+                 50, 4, 8, 1, 18, 0,
+                 50, 6, 8, 2, 18, 2, 8, 1>>,
+               encoding:encode_unordered(unordered(), [deterministic])),
+  %% Oneofs are ordered by the number of the field which is set
+  ?assertEqual(<<8, 1, 16, 2, 26, 1, $c>>,
+               encoding:encode_unordered(
+                 #unordered{c = <<"c">>, choice = {b, 2}, a = 1},
+                 [deterministic])).
+
+non_deterministic_test() ->
+  %% Fields are written in the order of the record
+  ?assertEqual(<<26, 1, $c, 16, 2, 8, 1>>,
+               encoding:encode_unordered(
+                 #unordered{c = <<"c">>, choice = {b, 2}, a = 1})),
+  Message = unordered(),
+  Data = encoding:encode_unordered(Message),
+  ?assertEqual(encoding:encoded_size_unordered(Message), byte_size(Data)).
+
+%% Map entries are sorted by the content of string keys, whatever their
+%% representation.
+deterministic_equal_messages_test() ->
+  Message = unordered(),
+  Entries = [Entry#unordered_counts_entry{key = [Key]}
+             || Entry = #unordered_counts_entry{key = Key}
+                  <- lists:reverse(Message#unordered.counts)],
+  Message2 = Message#unordered{counts = Entries},
+  ?assertEqual(encoding:encode_unordered(Message, [deterministic]),
+               encoding:encode_unordered(Message2, [deterministic])).
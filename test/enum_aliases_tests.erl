@@ -0,0 +1,71 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of generated enum functions for enums with aliases.
+
+-module(enum_aliases_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("enum_aliases.hrl").
+
+to_int_test() ->
+  ?assertEqual(0, enum_aliases:status_to_int(status_unknown)),
+  ?assertEqual(1, enum_aliases:status_to_int(status_started)),
+  ?assertEqual(1, enum_aliases:status_to_int(status_running)),
+  ?assertEqual(2, enum_aliases:status_to_int(status_stopped)),
+  ?assertEqual(2, enum_aliases:status_to_int(status_done)),
+  ?assertEqual(2, enum_aliases:status_to_int(status_finished)).
+
+int_to_test() ->
+  ?assertEqual(status_unknown, enum_aliases:int_to_status(0)),
+  ?assertEqual(status_started, enum_aliases:int_to_status(1)),
+  ?assertEqual(status_stopped, enum_aliases:int_to_status(2)),
+  ?assertError(function_clause, enum_aliases:int_to_status(3)).
+
+values_test() ->
+  ?assertEqual([status_unknown, status_started, status_running,
+                status_stopped, status_done, status_finished],
+               enum_aliases:status_values()).
+
+name_test() ->
+  ?assertEqual(<<"STATUS_RUNNING">>,
+               enum_aliases:status_name(status_running)),
+  ?assertEqual(<<"STATUS_FINISHED">>,
+               enum_aliases:status_name(status_finished)).
+
+aliases_test() ->
+  ?assertEqual([status_unknown], enum_aliases:status_aliases(status_unknown)),
+  ?assertEqual([status_started, status_running],
+               enum_aliases:status_aliases(status_started)),
+  ?assertEqual([status_started, status_running],
+               enum_aliases:status_aliases(status_running)),
+  ?assertEqual([status_stopped, status_done, status_finished],
+               enum_aliases:status_aliases(status_finished)).
+
+encode_alias_test() ->
+  Canonical = #job{status = status_stopped,
+                   history = [status_started, status_stopped]},
+  Aliased = #job{status = status_finished,
+                 history = [status_running, status_done]},
+  Data = <<8, 2, 18, 2, 1, 2>>,
+  ?assertEqual(Data, iolist_to_binary(enum_aliases:encode_job(Canonical))),
+  ?assertEqual(Data, iolist_to_binary(enum_aliases:encode_job(Aliased))).
+
+decode_alias_test() ->
+  Data = <<8, 1, 18, 3, 0, 1, 2>>,
+  ?assertEqual({ok, #job{status = status_started,
+                         history = [status_unknown, status_started,
+                                    status_stopped]}},
+               enum_aliases:decode_job(Data, [])).
@@ -0,0 +1,61 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of enum value atoms generated with the strip_enum_prefix option.
+
+-module(enum_prefixes_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("enum_prefixes.hrl").
+
+values_test() ->
+  ?assertEqual([unspecified, fiction, non_fiction],
+               enum_prefixes:book_type_values()),
+  ?assertEqual([unspecified, paper, ebook],
+               enum_prefixes:book_format_values()),
+  ?assertEqual([unspecified, read, write], enum_prefixes:mode_values()).
+
+invalid_atoms_test() ->
+  ?assertEqual([unspecified, operator_and, operator_not, operator_1, xor_all],
+               enum_prefixes:operator_values()).
+
+duplicate_atoms_test() ->
+  ?assertEqual([shape_unspecified, shape_circle, circle],
+               enum_prefixes:shape_values()).
+
+names_test() ->
+  ?assertEqual(<<"BOOK_TYPE_NON_FICTION">>,
+               enum_prefixes:book_type_name(non_fiction)),
+  ?assertEqual(<<"OPERATOR_AND">>, enum_prefixes:operator_name(operator_and)),
+  ?assertEqual(<<"READ">>, enum_prefixes:mode_name(read)),
+  ?assertEqual(<<"CIRCLE">>, enum_prefixes:shape_name(circle)).
+
+codec_test() ->
+  Book = #book{type = non_fiction,
+               format = ebook,
+               operators = [operator_and, xor_all],
+               mode = write,
+               shape = circle},
+  Data = <<8, 2, 16, 2, 24, 1, 24, 4, 32, 2, 40, 2>>,
+  ?assertEqual(Data, iolist_to_binary(enum_prefixes:encode_book(Book))),
+  ?assertEqual({ok, Book}, enum_prefixes:decode_book(Data, [])).
+
+defaults_test() ->
+  ?assertEqual(#book{type = unspecified,
+                     format = unspecified,
+                     operators = [],
+                     mode = unspecified,
+                     shape = shape_unspecified},
+               #book{}).
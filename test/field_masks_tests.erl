@@ -0,0 +1,105 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of generated field mask functions.
+
+-module(field_masks_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("field_masks.hrl").
+
+book() ->
+  #book{title = <<"Dune">>,
+        author = #book_author{name = <<"Frank">>, email = <<"f@h.net">>},
+        tags = [<<"sf">>],
+        source = {editor, #book_author{name = <<"John">>,
+                                       email = <<"j@c.com">>}}}.
+
+mask_test() ->
+  ?assertEqual(#book{}, field_masks:mask_book(book(), [])),
+  ?assertEqual(#book{title = <<"Dune">>, tags = [<<"sf">>]},
+               field_masks:mask_book(book(), [<<"title">>, "tags"])),
+  ?assertEqual(#book{title = <<"Dune">>},
+               field_masks:mask_book(book(), {field_mask, [<<"title">>], []})),
+  ?assertEqual(book(),
+               field_masks:mask_book(book(), [<<"title">>, <<"author">>,
+                                              <<"tags">>, <<"editor">>])).
+
+mask_sub_paths_test() ->
+  ?assertEqual(#book{author = #book_author{name = <<"Frank">>}},
+               field_masks:mask_book(book(), [<<"author.name">>])),
+  %% A whole field includes its sub-paths
+  ?assertEqual(#book{author = (book())#book.author},
+               field_masks:mask_book(book(), [<<"author.name">>,
+                                              <<"author">>])),
+  ?assertEqual(#book{},
+               field_masks:mask_book(#book{}, [<<"author.name">>])).
+
+mask_oneof_test() ->
+  ?assertEqual(#book{source = {editor, #book_author{name = <<"John">>}}},
+               field_masks:mask_book(book(), [<<"editor.name">>])),
+  ?assertEqual(#book{}, field_masks:mask_book(book(), [<<"url">>])).
+
+mask_invalid_paths_test() ->
+  ?assertError({invalid_field_mask, {invalid_path, <<"unknown">>}},
+               field_masks:mask_book(book(), [<<"unknown">>])),
+  ?assertError({invalid_field_mask, {invalid_path, <<"author.unknown">>}},
+               field_masks:mask_book(book(), [<<"author.unknown">>])).
+
+validate_mask_test() ->
+  ?assertEqual(ok, field_masks:validate_mask_book([])),
+  ?assertEqual(ok, field_masks:validate_mask_book(
+                     [<<"title">>, <<"author.email">>, <<"editor.name">>])),
+  ?assertEqual({error, {invalid_path, <<"author.unknown">>}},
+               field_masks:validate_mask_book([<<"author.unknown">>])),
+  %% Only singular message fields have sub-paths
+  ?assertEqual({error, {invalid_path, <<"title.length">>}},
+               field_masks:validate_mask_book([<<"title.length">>])),
+  ?assertEqual({error, {invalid_path, <<"tags.value">>}},
+               field_masks:validate_mask_book([<<"tags.value">>])),
+  ?assertEqual({error, {invalid_path, <<"url.host">>}},
+               field_masks:validate_mask_book([<<"url.host">>])).
+
+merge_masked_test() ->
+  Source = #book{title = <<"Children of Dune">>, tags = [<<"classic">>]},
+  ?assertEqual((book())#book{title = <<"Children of Dune">>},
+               field_masks:merge_masked_book(book(), Source, [<<"title">>])),
+  %% Fields set to their default value in the source are cleared
+  ?assertEqual((book())#book{author = undefined, tags = [<<"classic">>]},
+               field_masks:merge_masked_book(book(), Source,
+                                             [<<"author">>, <<"tags">>])).
+
+merge_masked_sub_paths_test() ->
+  Source = #book{author = #book_author{name = <<"Brian">>,
+                                       email = <<"b@h.net">>}},
+  ?assertEqual((book())#book{author = #book_author{name = <<"Brian">>,
+                                                   email = <<"f@h.net">>}},
+               field_masks:merge_masked_book(book(), Source,
+                                             [<<"author.name">>])),
+  ?assertEqual(#book{author = #book_author{name = <<"Brian">>}},
+               field_masks:merge_masked_book(#book{}, Source,
+                                             [<<"author.name">>])).
+
+merge_masked_oneof_test() ->
+  Source = #book{source = {url, <<"https://example.com">>}},
+  ?assertEqual((book())#book{source = {url, <<"https://example.com">>}},
+               field_masks:merge_masked_book(book(), Source, [<<"url">>])),
+  %% The case of the target is cleared if it is not set in the source
+  ?assertEqual((book())#book{source = undefined},
+               field_masks:merge_masked_book(book(), Source, [<<"editor">>])),
+  ?assertEqual(Source#book{source = {editor,
+                                     #book_author{name = <<"John">>}}},
+               field_masks:merge_masked_book(Source, book(),
+                                             [<<"editor.name">>])).
@@ -0,0 +1,113 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Round-trip tests of generated encoding and decoding functions.
+%%%
+%%% Each golden/<name>.bin file is the encoding of the message described by
+%%% golden/<name>.txtpb, produced by the Go protobuf implementation with
+%%% deterministic marshaling. Messages must decode to the expected record,
+%%% and expected records must encode in deterministic mode to the exact same
+%%% data.
+
+-module(golden_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("golden.hrl").
+
+scalars_test() ->
+  check("scalars", scalars,
+        #scalars{f_int32 = -42,
+                 f_int64 = -9000000000,
+                 f_uint32 = 4294967295,
+                 f_uint64 = 18446744073709551615,
+                 f_sint32 = -2147483648,
+                 f_sint64 = 9223372036854775807,
+                 f_fixed32 = 305419896,
+                 f_fixed64 = 1234605616436508552,
+                 f_sfixed32 = -1,
+                 f_sfixed64 = -9223372036854775808,
+                 f_bool = true,
+                 f_float = 1.5,
+                 f_double = -0.25,
+                 f_string = <<"héllo"/utf8>>,
+                 f_bytes = <<0, 1, 255>>,
+                 f_color = color_blue}).
+
+scalars_default_test() ->
+  check("scalars_default", scalars, #scalars{}).
+
+nested_test() ->
+  check("nested", tree,
+        #tree{name = <<"a">>,
+              child = #tree{name = <<"b">>,
+                            child = #tree{name = <<"c">>}}}).
+
+repeated_test() ->
+  check("repeated", repeated,
+        #repeated{int32s = [1, -1, 300],
+                  sint64s = [-2, 2],
+                  doubles = [0.5, 2.0],
+                  colors = [color_red, color_unspecified, color_blue],
+                  strings = [<<"x">>, <<>>, <<"yz">>],
+                  nodes = [#tree{name = <<"n1">>}, #tree{}]}).
+
+maps_test() ->
+  Counts = [#maps_counts_entry{key = <<"a">>, value = 1},
+            #maps_counts_entry{key = <<"b">>, value = 2}],
+  Nodes = [#maps_nodes_entry{key = -1, value = #tree{name = <<"minus one">>}},
+           #maps_nodes_entry{key = 7, value = #tree{name = <<"seven">>}}],
+  check("maps", maps, #maps{counts = Counts, nodes = Nodes}).
+
+oneof_text_test() ->
+  check("oneof_text", oneofs, #oneofs{value = {text, <<"hello">>}}).
+
+oneof_node_test() ->
+  check("oneof_node", oneofs, #oneofs{value = {node, #tree{name = <<"n">>}}}).
+
+oneof_empty_number_test() ->
+  check("oneof_empty_number", oneofs, #oneofs{value = {number, 0}}).
+
+floats_infinity_test() ->
+  check("floats_infinity", floats,
+        #floats{f_float = infinity,
+                f_double = '-infinity',
+                doubles = [infinity, 1.0, '-infinity']}).
+
+%% NaN can be encoded with different bit patterns, so only decoding is
+%% checked.
+floats_nan_test() ->
+  Expected = #floats{f_float = nan, f_double = nan},
+  ?assertEqual({ok, Expected}, golden:decode_floats(data("floats_nan"), [])),
+  ?assertEqual(ok, golden:verify_floats(Expected)).
+
+check(Name, Type, Expected) ->
+  Data = data(Name),
+  Decode = function(decode, Type),
+  Encode = function(encode, Type),
+  EncodedSize = function(encoded_size, Type),
+  Verify = function(verify, Type),
+  ?assertEqual({ok, Expected}, golden:Decode(Data, [])),
+  ?assertEqual(ok, golden:Verify(Expected)),
+  Encoded = golden:Encode(Expected, [deterministic]),
+  ?assertEqual(Data, iolist_to_binary(Encoded)),
+  ?assertEqual(byte_size(Data), golden:EncodedSize(Expected)).
+
+function(Prefix, Type) ->
+  list_to_atom(atom_to_list(Prefix) ++ "_" ++ atom_to_list(Type)).
+
+data(Name) ->
+  Path = filename:join([filename:dirname(?FILE), "golden", Name ++ ".bin"]),
+  {ok, Data} = file:read_file(Path),
+  Data.
@@ -0,0 +1,177 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of generated JSON functions.
+%%%
+%%% Messages are converted to JSON terms, checked against the expected proto3
+%%% JSON representation, then encoded and decoded with the json module and
+%%% converted back to the original record.
+
+-module(json_mapping_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("json_mapping.hrl").
+-include("google_protobuf.hrl").
+
+scalars_test() ->
+  Message = #scalars{f_int32 = -42,
+                     f_int64 = -9000000000,
+                     f_uint64 = 18446744073709551615,
+                     f_sint32 = 7,
+                     f_fixed64 = 1,
+                     f_bool = true,
+                     f_float = 1.5,
+                     f_double = infinity,
+                     f_string = <<"héllo"/utf8>>,
+                     f_bytes = <<0, 1, 255>>,
+                     f_color = color_blue,
+                     custom_name = <<"x">>},
+  Json = #{<<"fInt32">> => -42,
+           <<"fInt64">> => <<"-9000000000">>,
+           <<"fUint64">> => <<"18446744073709551615">>,
+           <<"fSint32">> => 7,
+           <<"fFixed64">> => <<"1">>,
+           <<"fBool">> => true,
+           <<"fFloat">> => 1.5,
+           <<"fDouble">> => <<"Infinity">>,
+           <<"fString">> => <<"héllo"/utf8>>,
+           <<"fBytes">> => <<"AAH/">>,
+           <<"fColor">> => <<"COLOR_BLUE">>,
+           <<"renamed\"Field">> => <<"x">>},
+  check(scalars, Message, Json).
+
+scalars_default_test() ->
+  check(scalars, #scalars{}, #{}).
+
+scalars_input_test() ->
+  Json = #{<<"f_int32">> => <<"12">>,
+           <<"fInt64">> => 5,
+           <<"fUint64">> => 1.0e3,
+           <<"fSint32">> => null,
+           <<"fFloat">> => <<"1e2">>,
+           <<"fDouble">> => <<"-Infinity">>,
+           <<"fColor">> => 1,
+           <<"custom_name">> => <<"y">>},
+  ?assertEqual(#scalars{f_int32 = 12,
+                        f_int64 = 5,
+                        f_uint64 = 1000,
+                        f_float = 100.0,
+                        f_double = '-infinity',
+                        f_color = color_red,
+                        custom_name = <<"y">>},
+               json_mapping:from_json_scalars(Json)).
+
+bytes_test() ->
+  Decode = fun (Value) ->
+               Json = #{<<"fBytes">> => Value},
+               (json_mapping:from_json_scalars(Json))#scalars.f_bytes
+           end,
+  ?assertEqual(<<0, 1, 255>>, Decode(<<"AAH/">>)),
+  ?assertEqual(<<0, 1, 255>>, Decode(<<"AAH_">>)),
+  ?assertEqual(<<1>>, Decode(<<"AQ==">>)),
+  ?assertEqual(<<1>>, Decode(<<"AQ">>)),
+  ?assertEqual(<<1, 2>>, Decode(<<"AQI">>)),
+  ?assertEqual(<<>>, Decode(<<>>)),
+  ?assertError({invalid_json_value, <<"AAAAA">>, bytes},
+               Decode(<<"AAAAA">>)),
+  ?assertError({invalid_json_value, <<"A*==">>, bytes},
+               Decode(<<"A*==">>)).
+
+invalid_values_test() ->
+  ?assertError({invalid_json_value, <<"COLOR_GREEN">>, color},
+               json_mapping:from_json_scalars(
+                 #{<<"fColor">> => <<"COLOR_GREEN">>})),
+  ?assertError({invalid_json_value, 1.5, integer},
+               json_mapping:from_json_scalars(#{<<"fInt32">> => 1.5})),
+  ?assertError({invalid_json_value, <<"x">>, float},
+               json_mapping:from_json_scalars(#{<<"fFloat">> => <<"x">>})),
+  ?assertError({invalid_json_value, [], scalars},
+               json_mapping:from_json_scalars([])).
+
+collections_test() ->
+  Message =
+    #collections{
+       int64s = [1, -2],
+       colors = [color_red, color_unspecified],
+       items = [#item{name = <<"a">>, child = #item{name = <<"b">>}}],
+       counts = [#collections_counts_entry{key = <<"a">>, value = 1},
+                 #collections_counts_entry{key = <<"b">>, value = 2}],
+       %% Entries are decoded in the order of their JSON keys
+       items_by_id = [#collections_items_by_id_entry{
+                         key = 10, value = #item{name = <<"ten">>}},
+                      #collections_items_by_id_entry{
+                         key = 7, value = #item{name = <<"seven">>}}],
+       flags = [#collections_flags_entry{key = false, value = <<"no">>},
+                #collections_flags_entry{key = true, value = <<"yes">>}]},
+  Json = #{<<"int64s">> => [<<"1">>, <<"-2">>],
+           <<"colors">> => [<<"COLOR_RED">>, <<"COLOR_UNSPECIFIED">>],
+           <<"items">> => [#{<<"name">> => <<"a">>,
+                             <<"child">> => #{<<"name">> => <<"b">>}}],
+           <<"counts">> => #{<<"a">> => 1, <<"b">> => 2},
+           <<"itemsById">> => #{<<"10">> => #{<<"name">> => <<"ten">>},
+                                <<"7">> => #{<<"name">> => <<"seven">>}},
+           <<"flags">> => #{<<"false">> => <<"no">>,
+                            <<"true">> => <<"yes">>}},
+  check(collections, Message, Json).
+
+oneofs_test() ->
+  check(oneofs, #oneofs{}, #{}),
+  check(oneofs, #oneofs{value = {text, <<"hello">>}},
+        #{<<"text">> => <<"hello">>}),
+  %% Oneof values are written even if they are default values
+  check(oneofs, #oneofs{value = {number, 0}}, #{<<"number">> => <<"0">>}),
+  check(oneofs, #oneofs{value = {item, #item{}}}, #{<<"item">> => #{}}),
+  ?assertEqual(#oneofs{},
+               json_mapping:from_json_oneofs(#{<<"text">> => null})).
+
+well_known_types_test() ->
+  Message =
+    #well_known_types{
+       timestamp = #timestamp{seconds = 1, nanos = 500000000},
+       duration = #duration{seconds = -1, nanos = -500000000},
+       field_mask = #field_mask{paths = [<<"f_int32">>, <<"custom_name">>]},
+       int64_value = #int_64_value{value = 5},
+       string_value = #string_value{value = <<"s">>},
+       value = #value{kind = {number_value, 1.5}},
+       struct = #struct{fields = [#struct_fields_entry{
+                                     key = <<"k">>,
+                                     value = #value{kind = {bool_value,
+                                                            true}}}]}},
+  Json = #{<<"timestamp">> => <<"1970-01-01T00:00:01.500000000Z">>,
+           <<"duration">> => <<"-1.500000000s">>,
+           <<"fieldMask">> => <<"fInt32,customName">>,
+           <<"int64Value">> => <<"5">>,
+           <<"stringValue">> => <<"s">>,
+           <<"value">> => 1.5,
+           <<"struct">> => #{<<"k">> => true}},
+  check(well_known_types, Message, Json).
+
+well_known_types_input_test() ->
+  Json = #{<<"timestamp">> => <<"1970-01-01T00:00:01.5Z">>,
+           <<"duration">> => <<"3s">>},
+  ?assertEqual(#well_known_types{
+                  timestamp = #timestamp{seconds = 1, nanos = 500000000},
+                  duration = #duration{seconds = 3, nanos = 0}},
+               json_mapping:from_json_well_known_types(Json)).
+
+check(Type, Message, Json) ->
+  ToJson = function(to_json, Type),
+  FromJson = function(from_json, Type),
+  ?assertEqual(Json, json_mapping:ToJson(Message)),
+  Data = iolist_to_binary(json:encode(json_mapping:ToJson(Message))),
+  ?assertEqual(Message, json_mapping:FromJson(json:decode(Data))).
+
+function(Prefix, Type) ->
+  list_to_atom(atom_to_list(Prefix) ++ "_" ++ atom_to_list(Type)).
@@ -0,0 +1,48 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of generated JSON functions for strings represented as lists.
+
+-module(json_string_lists_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("json_string_lists.hrl").
+
+json_test() ->
+  Message = #labels{name = "héllo",
+                    tags = ["a", "é"],
+                    counts = [#labels_counts_entry{key = "a", value = 1},
+                              #labels_counts_entry{key = "é", value = 2}],
+                    labels = [#labels_labels_entry{key = "k", value = "v"}],
+                    attributes = #{<<"x">> => "y"}},
+  Json = #{<<"name">> => <<"héllo"/utf8>>,
+           <<"tags">> => [<<"a">>, <<"é"/utf8>>],
+           <<"counts">> => #{<<"a">> => 1, <<"é"/utf8>> => 2},
+           <<"labels">> => #{<<"k">> => <<"v">>},
+           <<"attributes">> => #{<<"x">> => <<"y">>}},
+  ?assertEqual(Json, json_string_lists:to_json_labels(Message)),
+  Data = iolist_to_binary(json:encode(Json)),
+  ?assertEqual(Message, json_string_lists:from_json_labels(json:decode(Data))).
+
+json_default_test() ->
+  ?assertEqual(#{}, json_string_lists:to_json_labels(#labels{})),
+  ?assertEqual(#labels{}, json_string_lists:from_json_labels(#{})).
+
+codec_test() ->
+  Message = #labels{name = "héllo",
+                    counts = [#labels_counts_entry{key = "é", value = 2}],
+                    attributes = #{<<"x">> => "y"}},
+  Data = json_string_lists:encode_labels(Message),
+  ?assertEqual({ok, Message}, json_string_lists:decode_labels(Data, [])).
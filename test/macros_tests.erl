@@ -0,0 +1,48 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of macros generated with the hrl_macros option.
+
+-module(macros_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("macros.hrl").
+
+enum_value_macros_test() ->
+  ?assertEqual(0, ?COLOR_COLOR_UNSPECIFIED),
+  ?assertEqual(1, ?COLOR_COLOR_RED),
+  ?assertEqual(-1, ?COLOR_COLOR_INVALID),
+  ?assertEqual(0, ?BOOK_FORMAT_FORMAT_UNSPECIFIED),
+  ?assertEqual(5, ?BOOK_FORMAT_FORMAT_PAPER),
+  ?assertEqual(macros:color_to_int(color_invalid), ?COLOR_COLOR_INVALID),
+  ?assertEqual(macros:book_format_to_int(format_paper),
+               ?BOOK_FORMAT_FORMAT_PAPER).
+
+field_number_macros_test() ->
+  ?assertEqual(1, ?BOOK_ISBN_FIELD_NUMBER),
+  ?assertEqual(2, ?BOOK_FORMAT_FIELD_NUMBER),
+  ?assertEqual(16, ?BOOK_AUTHOR_FIELD_NUMBER),
+  ?assertEqual(300, ?BOOK_COLOR_FIELD_NUMBER),
+  ?assertEqual(20, ?BOOK_CENTS_FIELD_NUMBER),
+  ?assertEqual(21, ?BOOK_FREE_FIELD_NUMBER),
+  ?assertEqual(3, ?BOOK_AUTHOR_NAME_FIELD_NUMBER).
+
+wire_data_test() ->
+  Data = iolist_to_binary(macros:encode_book(#book{isbn = <<"x">>,
+                                                   format = format_paper})),
+  IsbnKey = (?BOOK_ISBN_FIELD_NUMBER bsl 3) bor 2,
+  FormatKey = ?BOOK_FORMAT_FIELD_NUMBER bsl 3,
+  ?assertEqual(<<IsbnKey, 1, "x", FormatKey, ?BOOK_FORMAT_FORMAT_PAPER>>,
+               Data).
@@ -0,0 +1,99 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of generated message merge functions.
+
+-module(merge_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("merge.hrl").
+
+scalars_test() ->
+  Target = #settings{name = <<"a">>, level = 1},
+  ?assertEqual(#settings{name = <<"b">>, level = 1},
+               merge:merge_settings(Target, #settings{name = <<"b">>})),
+  %% Default values of the source do not overwrite the target
+  ?assertEqual(Target, merge:merge_settings(Target, #settings{})),
+  ?assertEqual(Target, merge:merge_settings(Target, #settings{name = [[]]})).
+
+repeated_test() ->
+  ?assertEqual(#settings{tags = [<<"a">>, <<"b">>, <<"c">>]},
+               merge:merge_settings(#settings{tags = [<<"a">>]},
+                                    #settings{tags = [<<"b">>, <<"c">>]})).
+
+messages_test() ->
+  Target = #settings{limits = #settings_limits{max_items = 10, max_size = 5}},
+  Source = #settings{limits = #settings_limits{max_size = 20}},
+  ?assertEqual(#settings{limits = #settings_limits{max_items = 10,
+                                                   max_size = 20}},
+               merge:merge_settings(Target, Source)),
+  ?assertEqual(Target, merge:merge_settings(Target, #settings{})),
+  ?assertEqual(Source, merge:merge_settings(#settings{}, Source)).
+
+map_entries_test() ->
+  Entry = fun (Key, Value) ->
+              #settings_counts_entry{key = Key, value = Value}
+          end,
+  Target = #settings{counts = [Entry(<<"a">>, 1), Entry(<<"b">>, 2)]},
+  Source = #settings{counts = [Entry(<<"b">>, 3), Entry(<<"c">>, 4)]},
+  ?assertEqual(#settings{counts = [Entry(<<"a">>, 1), Entry(<<"b">>, 3),
+                                   Entry(<<"c">>, 4)]},
+               merge:merge_settings(Target, Source)).
+
+maps_test() ->
+  ?assertEqual(#settings{labels = #{<<"a">> => <<"1">>, <<"b">> => <<"3">>}},
+               merge:merge_settings(
+                 #settings{labels = #{<<"a">> => <<"1">>, <<"b">> => <<"2">>}},
+                 #settings{labels = #{<<"b">> => <<"3">>}})).
+
+oneofs_test() ->
+  Defaults = #settings_limits{max_items = 10},
+  Path = #settings{source = {path, <<"/etc">>}},
+  ?assertEqual(Path, merge:merge_settings(Path, #settings{})),
+  ?assertEqual(Path, merge:merge_settings(#settings{}, Path)),
+  %% Another case replaces the current one
+  ?assertEqual(#settings{source = {defaults, Defaults}},
+               merge:merge_settings(Path,
+                                    #settings{source = {defaults, Defaults}})),
+  %% Messages of the same case are merged
+  ?assertEqual(#settings{source = {defaults,
+                                   #settings_limits{max_items = 10,
+                                                    max_size = 5}}},
+               merge:merge_settings(
+                 #settings{source = {defaults, Defaults}},
+                 #settings{source = {defaults,
+                                     #settings_limits{max_size = 5}}})).
+
+%% Merging messages is equivalent to decoding the concatenation of their
+%% encodings.
+encoding_test() ->
+  Target = #settings{name = <<"a">>,
+                     tags = [<<"x">>],
+                     limits = #settings_limits{max_items = 10},
+                     counts = [#settings_counts_entry{key = <<"a">>,
+                                                      value = 1}],
+                     labels = #{<<"a">> => <<"1">>, <<"b">> => <<"2">>},
+                     source = {defaults, #settings_limits{max_size = 1}}},
+  Source = #settings{level = 2,
+                     tags = [<<"y">>],
+                     limits = #settings_limits{max_size = 20},
+                     counts = [#settings_counts_entry{key = <<"b">>,
+                                                      value = 2}],
+                     labels = #{<<"b">> => <<"3">>},
+                     source = {defaults, #settings_limits{max_items = 3}}},
+  Data = iolist_to_binary([merge:encode_settings(Target),
+                           merge:encode_settings(Source)]),
+  ?assertEqual({ok, merge:merge_settings(Target, Source)},
+               merge:decode_settings(Data, [])).
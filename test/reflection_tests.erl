@@ -0,0 +1,75 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of generated reflection functions.
+
+-module(reflection_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+message_names_test() ->
+  ?assertEqual([book, book_author, book_counts_entry, empty],
+               reflection:message_names()).
+
+enum_names_test() ->
+  ?assertEqual([status], reflection:enum_names()).
+
+fields_test() ->
+  Fields = reflection:fields(book),
+  ?assertEqual([1, 2, 3, 4, 5, 6, 7, 9, 10],
+               [maps:get(number, Field) || Field <- Fields]),
+  ?assertEqual([isbn, title, authors, status, counts, cents, free, id, cover],
+               [maps:get(name, Field) || Field <- Fields]),
+  ?assertEqual([], reflection:fields(empty)),
+  ?assertEqual([#{number => 1, name => name,
+                  proto_name => <<"name">>, json_name => <<"name">>,
+                  type => string, label => optional, oneof => undefined}],
+               reflection:fields(book_author)),
+  ?assertError({unknown_message, unknown}, reflection:fields(unknown)).
+
+field_types_test() ->
+  Types = [{maps:get(name, Field), maps:get(type, Field),
+            maps:get(label, Field)}
+           || Field <- reflection:fields(book)],
+  ?assertEqual([{isbn, string, optional},
+                {title, string, optional},
+                {authors, {message, reflection, book_author}, repeated},
+                {status, {enum, reflection, status}, optional},
+                {counts, {message, reflection, book_counts_entry}, repeated},
+                {cents, int64, optional},
+                {free, bool, optional},
+                {id, sfixed64, optional},
+                {cover, bytes, optional}],
+               Types).
+
+oneof_test() ->
+  ?assertEqual([undefined, undefined, undefined, undefined, undefined,
+                price, price, undefined, undefined],
+               [maps:get(oneof, Field) || Field <- reflection:fields(book)]).
+
+json_name_test() ->
+  {ok, Field} = reflection:find_field(book, title),
+  ?assertEqual(<<"title">>, maps:get(proto_name, Field)),
+  ?assertEqual(<<"book\"Title">>, maps:get(json_name, Field)).
+
+find_field_test() ->
+  {ok, Field} = reflection:find_field(book, status),
+  ?assertEqual({ok, Field}, reflection:find_field(book, <<"status">>)),
+  ?assertEqual({ok, Field}, reflection:find_field(book, 4)),
+  ?assertEqual(4, maps:get(number, Field)),
+  ?assertEqual(error, reflection:find_field(book, unknown)),
+  ?assertEqual(error, reflection:find_field(book, <<"book\"Title">>)),
+  ?assertEqual(error, reflection:find_field(book, 8)),
+  ?assertError({unknown_message, unknown},
+               reflection:find_field(unknown, 1)).
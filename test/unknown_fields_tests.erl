@@ -0,0 +1,78 @@
+%%% Copyright (c) 2019 Nicolas Martyanoff <khaelin@gmail.com>
+%%%
+%%% Permission to use, copy, modify, and distribute this software for any
+%%% purpose with or without fee is hereby granted, provided that the above
+%%% copyright notice and this permission notice appear in all copies.
+%%%
+%%% THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+%%% WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+%%% MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+%%% ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+%%% WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+%%% ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+%%% OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+%%% Tests of the preservation of unknown fields.
+
+-module(unknown_fields_tests).
+
+-include_lib("eunit/include/eunit.hrl").
+
+-include("unknown_fields.hrl").
+
+book_v2() ->
+  #book_v2{title = <<"t">>,
+           ratings = [5, 4],
+           id = 1,
+           author = #author_v2{name = <<"n">>, email = <<"e">>},
+           isbn = <<"i">>}.
+
+book_v1() ->
+  #book_v1{title = <<"t">>,
+           author = #author_v1{name = <<"n">>,
+                               '$unknown' = [{2, <<18, 1, $e>>}]},
+           '$unknown' = [{2, <<18, 2, 5, 4>>},
+                         {3, <<25, 1, 0, 0, 0, 0, 0, 0, 0>>},
+                         {5, <<42, 1, $i>>}]}.
+
+decode_test() ->
+  Data = unknown_fields:encode_book_v2(book_v2()),
+  ?assertEqual({ok, book_v1()}, unknown_fields:decode_book_v1(Data, [])).
+
+encode_test() ->
+  %% Unknown fields are written after known fields
+  Data = <<10, 1, $t,
+           34, 6, 10, 1, $n, 18, 1, $e,
+           18, 2, 5, 4,
+           25, 1, 0, 0, 0, 0, 0, 0, 0,
+           42, 1, $i>>,
+  ?assertEqual(Data, unknown_fields:encode_book_v1(book_v1())),
+  ?assertEqual(Data, unknown_fields:encode_book_v1(book_v1(), [deterministic])),
+  ?assertEqual(byte_size(Data), unknown_fields:encoded_size_book_v1(book_v1())),
+  ?assertEqual({ok, book_v2()}, unknown_fields:decode_book_v2(Data, [])).
+
+encode_order_test() ->
+  Message = #book_v1{title = <<"t">>,
+                     '$unknown' = [{5, <<42, 1, $a>>},
+                                   {2, <<16, 1>>},
+                                   {5, <<42, 1, $b>>}]},
+  ?assertEqual(<<10, 1, $t, 42, 1, $a, 16, 1, 42, 1, $b>>,
+               unknown_fields:encode_book_v1(Message)),
+  %% Fields with the same number keep their order in deterministic mode
+  ?assertEqual(<<10, 1, $t, 16, 1, 42, 1, $a, 42, 1, $b>>,
+               unknown_fields:encode_book_v1(Message, [deterministic])).
+
+merge_test() ->
+  ?assertEqual(#book_v1{'$unknown' = [{2, <<16, 1>>}, {3, <<24, 1>>}]},
+               unknown_fields:merge_book_v1(
+                 #book_v1{'$unknown' = [{2, <<16, 1>>}]},
+                 #book_v1{'$unknown' = [{3, <<24, 1>>}]})).
+
+verify_test() ->
+  ?assertEqual(ok, unknown_fields:verify_book_v1(book_v1())),
+  ?assertMatch({error, {['$unknown' | _], _}},
+               unknown_fields:verify_book_v1(
+                 #book_v1{'$unknown' = [{0, <<>>}]})),
+  ?assertMatch({error, {[author, '$unknown' | _], _}},
+               unknown_fields:verify_book_v1(
+                 #book_v1{author = #author_v1{'$unknown' = [invalid]}})).